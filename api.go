@@ -2,6 +2,9 @@ package arseeding
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -19,20 +22,14 @@ import (
 	"io"
 	"io/ioutil"
 	gLog "log"
+	"math"
 	"net/http"
-	"net/http/httputil"
 	"os"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 )
 
-var (
-	tmpFileMap     = make(map[string]int64) // key->v : fileName -> fileUsedCnt
-	tmpFileMapLock sync.Mutex
-)
-
 func (s *Arseeding) runAPI(port string) {
 	r := s.engine
 	r.Use(CORSMiddleware())
@@ -53,14 +50,18 @@ func (s *Arseeding) runAPI(port string) {
 		v1.GET("/tx/:arid", s.getTx)
 		v1.GET("chunk/:offset", s.getChunk)
 		v1.GET("tx/:arid/:field", s.getTxField)
+		v1.HEAD("tx/:arid/:field", s.getTxField)
 		v1.GET("/info", s.getInfo)
 		v1.GET("/tx_anchor", s.getAnchor)
 		v1.GET("/price/:size", s.getTxPrice)
 		v1.GET("/peers", s.getPeers)
+		v1.GET("/arns/resolve/:name", s.resolveArNs)
+		v1.GET("/arns/records/:rootName", s.getArNsRecords)
+		v1.POST("/graphql", s.graphql) // local index first, proxy to arweave gateway if missed
 		// proxy
 		v2 := r.Group("/")
 		{
-			v2.Use(proxyArweaveGateway)
+			v2.Use(s.proxyArweaveGateway)
 			v2.GET("/tx/:arid/status")
 			v2.GET("/price/:size/:target")
 			v2.GET("/block/hash/:hash")
@@ -69,7 +70,6 @@ func (s *Arseeding) runAPI(port string) {
 			v2.GET("/wallet/:address/balance")
 			v2.GET("/wallet/:address/last_tx")
 			v2.POST("/arql")
-			v2.POST("/graphql")
 			v2.GET("/tx/pending")
 			v2.GET("/unconfirmed_tx/:arId")
 		}
@@ -84,14 +84,19 @@ func (s *Arseeding) runAPI(port string) {
 		// ANS-104 bundle Data api
 		v1.GET("/bundle/bundler", s.getBundler)
 		v1.POST("/bundle/tx/:currency", s.submitItem)
+		v1.POST("/bundle/batch/:currency", s.submitBatch)
 		v1.POST("/bundle/tx/signData", s.getSignData)
 
 		v1.GET("/bundle/tx/:itemId", s.getItemMeta) // get item meta, without data
 		v1.GET("/bundle/tx/:itemId/:field", s.getItemField)
+		v1.HEAD("/bundle/tx/:itemId/:field", s.getItemField)
 		v1.GET("/bundle/itemIds/:arId", s.getItemIdsByArId)
+		v1.GET("/bundle/items", s.getIndexedItems) // query by owner, target, tag=Name:Value
 		v1.GET("/bundle/fees", s.bundleFees)
 		v1.GET("/bundle/fee/:size/:currency", s.bundleFee)
 		v1.GET("/bundle/orders/:signer", s.getOrders)
+		// list paths of manifest, query params: prefix, format=html
+		v1.GET("/manifest/:id/ls", s.listManifest)
 		v1.GET("/:id", s.dataRoute)  // get arTx data or bundleItem data
 		v1.HEAD("/:id", s.dataRoute) // get arTx data or bundleItem data
 
@@ -99,8 +104,22 @@ func (s *Arseeding) runAPI(port string) {
 			v1.POST("/manifest_url/:id", s.setManifestUrl)
 		}
 
+		// admin api, http header need X-ADMIN-KEY
+		if s.adminKey != "" {
+			admin := v1.Group("/admin", AdminAuthMiddleware(s.adminKey))
+			if s.EnableManifest {
+				admin.GET("/domains", s.getCustomDomains)
+				admin.PUT("/domains/:domain", s.setCustomDomain)
+				admin.DELETE("/domains/:domain", s.delCustomDomain)
+			}
+			admin.GET("/bundle/lost", s.getLostItems) // query params: cursorId, num
+			admin.POST("/bundle/requeue", s.requeueItems)
+		}
+
 		// submit native data with X-API-KEY
 		v1.POST("/bundle/data/:currency", s.submitNativeData)
+		// tar(.gz) or zip, one item per file and a manifest
+		v1.POST("/bundle/archive/:currency", s.submitArchive)
 		v1.GET("/bundle/orders", s.getOrdersByApiKey) // http header need X-API-KEY
 
 		// apikey
@@ -242,16 +261,42 @@ func (s *Arseeding) getTx(c *gin.Context) {
 
 	// get from arweave gateway
 	log.Debug("get from local failed, proxy to arweave gateway", "err", err, "arId", id)
-	proxyArweaveGateway(c)
+	s.proxyArweaveGateway(c)
+}
+
+// txDataFieldContentType return the content type of data field of /tx/:arid/:field, false if it is not a valid data field
+func txDataFieldContentType(field string) (string, bool) {
+	switch field {
+	case "data":
+		return "text/html; charset=utf-8", true
+	case "data.json", "data.txt", "data.pdf":
+		return fmt.Sprintf("application/%s; charset=utf-8", strings.Split(field, ".")[1]), true
+	case "data.png", "data.jpeg", "data.gif":
+		return fmt.Sprintf("image/%s; charset=utf-8", strings.Split(field, ".")[1]), true
+	case "data.mp4":
+		return "video/mpeg4; charset=utf-8", true
+	}
+	return "", false
 }
 
 func (s *Arseeding) getTxField(c *gin.Context) {
 	arid := c.Param("arid")
 	field := c.Param("field")
+	isDataField := strings.HasPrefix(field, "data") && field != "data_root" && field != "data_size"
+	if isDataField {
+		if _, ok := txDataFieldContentType(field); ok && c.Request.Method == http.MethodHead && s.dataHeadResponse(c, arid, field) {
+			return
+		}
+		s.setDataMetaHeaders(c, arid)
+	}
 	txMeta, err := s.store.LoadTxMeta(arid)
 	if err != nil {
 		log.Debug("get from local failed, proxy to arweave gateway", "err", err, "arId", arid, "field", field)
-		proxyArweaveGateway(c)
+		if isDataField {
+			s.proxyAndReadThrough(c, arid)
+		} else {
+			s.proxyArweaveGateway(c)
+		}
 		return
 	}
 
@@ -270,36 +315,29 @@ func (s *Arseeding) getTxField(c *gin.Context) {
 		c.Data(200, "text/html; charset=utf-8", []byte(txMeta.Quantity))
 	case "data":
 		data, err := txDataByMeta(txMeta, s.store)
-		if err != nil {
-			c.JSON(400, err.Error())
+		if err == schema.ErrDataCorrupted {
+			s.repairAndProxy(c, arid)
 			return
 		}
-		c.Data(200, "text/html; charset=utf-8", []byte(utils.Base64Encode(data)))
-
-	case "data.json", "data.txt", "data.pdf":
-		data, err := txDataByMeta(txMeta, s.store)
 		if err != nil {
-			errorResponse(c, err.Error())
+			c.JSON(400, err.Error())
 			return
 		}
-		typ := strings.Split(field, ".")[1]
-		c.Data(200, fmt.Sprintf("application/%s; charset=utf-8", typ), data)
+		contentType, _ := txDataFieldContentType(field)
+		dataRangeResponse(c, strings.NewReader(utils.Base64Encode(data)), contentType, arid)
 
-	case "data.png", "data.jpeg", "data.gif":
+	case "data.json", "data.txt", "data.pdf", "data.png", "data.jpeg", "data.gif", "data.mp4":
 		data, err := txDataByMeta(txMeta, s.store)
-		if err != nil {
-			errorResponse(c, err.Error())
+		if err == schema.ErrDataCorrupted {
+			s.repairAndProxy(c, arid)
 			return
 		}
-		typ := strings.Split(field, ".")[1]
-		c.Data(200, fmt.Sprintf("image/%s; charset=utf-8", typ), data)
-	case "data.mp4":
-		data, err := txDataByMeta(txMeta, s.store)
 		if err != nil {
 			errorResponse(c, err.Error())
 			return
 		}
-		c.Data(200, "video/mpeg4; charset=utf-8", data)
+		contentType, _ := txDataFieldContentType(field)
+		dataRangeResponse(c, bytes.NewReader(data), contentType, arid)
 	case "data_root":
 		c.Data(200, "text/html; charset=utf-8", []byte(txMeta.DataRoot))
 	case "data_size":
@@ -358,6 +396,11 @@ func txDataByMeta(txMeta *types.Transaction, db *Store) ([]byte, error) {
 
 // todo need stream
 func getArTxData(dataRoot, dataSize string, db *Store) ([]byte, error) {
+	return getArTxDataPrefix(dataRoot, dataSize, db, math.MaxUint64)
+}
+
+// getArTxDataPrefix load the chunks of arTx data until at least n bytes are read, the whole data if it is shorter than n
+func getArTxDataPrefix(dataRoot, dataSize string, db *Store, n uint64) ([]byte, error) {
 	size, err := strconv.ParseUint(dataSize, 10, 64)
 	if err != nil {
 		return nil, err
@@ -366,13 +409,17 @@ func getArTxData(dataRoot, dataSize string, db *Store) ([]byte, error) {
 		return []byte{}, nil
 	}
 
-	data := make([]byte, 0, size)
+	capacity := size
+	if n < size {
+		capacity = n + types.MAX_CHUNK_SIZE
+	}
+	data := make([]byte, 0, capacity)
 	txDataEndOffset, err := db.LoadTxDataEndOffSet(dataRoot, dataSize)
 	if err != nil {
 		return nil, err
 	}
 	startOffset := txDataEndOffset - size + 1
-	for i := 0; uint64(i)+startOffset < txDataEndOffset; {
+	for i := 0; uint64(i)+startOffset < txDataEndOffset && uint64(i) < n; {
 		chunkStartOffset := startOffset + uint64(i)
 		chunk, err := db.LoadChunk(chunkStartOffset)
 		if err != nil {
@@ -382,25 +429,17 @@ func getArTxData(dataRoot, dataSize string, db *Store) ([]byte, error) {
 		if err != nil {
 			return nil, err
 		}
+		if db.VerifyOnRead && (chunk.DataRoot != dataRoot || verifyChunkData(*chunk, chunkData) != nil) {
+			metricDataCorrupted("chunk")
+			log.Error("stored chunk corrupted", "dataRoot", dataRoot, "chunkStartOffset", chunkStartOffset)
+			return nil, schema.ErrDataCorrupted
+		}
 		data = append(data, chunkData...)
 		i += len(chunkData)
 	}
 	return data, nil
 }
 
-func proxyArweaveGateway(c *gin.Context) {
-	c.Writer.Header().Del("Access-Control-Allow-Origin")
-	directer := func(req *http.Request) {
-		req.URL.Scheme = "https"
-		req.URL.Host = "arweave.net"
-		req.Host = "arweave.net"
-	}
-	proxy := &httputil.ReverseProxy{Director: directer}
-
-	proxy.ServeHTTP(c.Writer, c.Request)
-	c.Abort()
-}
-
 func calculatePrice(fee schema.ArFee, dataSize int64) int64 {
 	count := int64(0)
 	if dataSize > 0 {
@@ -503,10 +542,11 @@ func (s *Arseeding) registerTask(arId, tktype string) error {
 }
 
 func (s *Arseeding) getBundler(c *gin.Context) {
-	c.JSON(http.StatusOK, schema.ResBundler{Bundler: s.bundler.Signer.Address})
+	c.JSON(http.StatusOK, schema.ResBundler{Bundler: s.bundler.Signer.Address(), Bundlers: s.bundlers.infos()})
 }
 
-func (s *Arseeding) processApikeySpendBal(currency, apikey string, dataSize int64) error {
+// processApikeySpendBal charge the fee of all items with dataSizes at once
+func (s *Arseeding) processApikeySpendBal(currency, apikey string, dataSizes ...int64) error {
 	apikeyDetail, err := s.wdb.GetApiKeyDetail(apikey)
 	if err != nil {
 		return err
@@ -522,13 +562,17 @@ func (s *Arseeding) processApikeySpendBal(currency, apikey string, dataSize int6
 		return err
 	}
 	// calc fee
-	fee, err := s.CalcItemFee(currency, dataSize)
-	if err != nil {
-		return err
-	}
-	feeDe, err := decimal.NewFromString(fee.FinalFee)
-	if err != nil {
-		return err
+	feeDe := decimal.Zero
+	for _, dataSize := range dataSizes {
+		fee, err := s.CalcItemFee(currency, dataSize)
+		if err != nil {
+			return err
+		}
+		itemFeeDe, err := decimal.NewFromString(fee.FinalFee)
+		if err != nil {
+			return err
+		}
+		feeDe = feeDe.Add(itemFeeDe)
 	}
 	endBalDe := curBalDe.Sub(feeDe)
 	if endBalDe.LessThan(decimal.NewFromInt(0)) {
@@ -627,7 +671,7 @@ func (s *Arseeding) submitItem(c *gin.Context) {
 	c.JSON(http.StatusOK, schema.RespOrder{
 		ItemId:             ord.ItemId,
 		Size:               ord.Size,
-		Bundler:            s.bundler.Signer.Address,
+		Bundler:            s.bundler.Signer.Address(),
 		Currency:           ord.Currency,
 		Decimals:           ord.Decimals,
 		Fee:                ord.Fee,
@@ -832,7 +876,7 @@ func (s *Arseeding) getOrdersByApiKey(c *gin.Context) {
 			RespOrder: schema.RespOrder{
 				ItemId:             od.ItemId,
 				Size:               od.Size,
-				Bundler:            s.bundler.Signer.Address,
+				Bundler:            s.bundler.Signer.Address(),
 				Currency:           od.Currency,
 				Decimals:           od.Decimals,
 				Fee:                od.Fee,
@@ -883,7 +927,15 @@ func (s *Arseeding) getItemField(c *gin.Context) {
 	case "signatureType":
 		c.Data(200, "text/html; charset=utf-8", []byte(strconv.Itoa(txMeta.SignatureType)))
 	case "data", "data.json", "data.txt", "data.pdf", "data.png", "data.jpeg", "data.gif", "data.mp4":
+		if c.Request.Method == http.MethodHead && s.dataHeadResponse(c, id, "") {
+			return
+		}
+		s.setDataMetaHeaders(c, id)
 		tags, dataReader, data, err := getBundleItemData(id, s.store)
+		if err == schema.ErrDataCorrupted {
+			s.repairAndProxy(c, id)
+			return
+		}
 		if err != nil {
 			internalErrorResponse(c, err.Error())
 			return
@@ -905,6 +957,49 @@ func (s *Arseeding) getItemIdsByArId(c *gin.Context) {
 	c.JSON(http.StatusOK, itemIds)
 }
 
+func (s *Arseeding) getIndexedItems(c *gin.Context) {
+	filter := schema.ItemIndexFilter{
+		Owner:  c.Query("owner"),
+		Target: c.Query("target"),
+	}
+	for _, tag := range c.QueryArray("tag") {
+		kv := strings.SplitN(tag, ":", 2)
+		if len(kv) != 2 {
+			errorResponse(c, "tag must be Name:Value")
+			return
+		}
+		filter.Tags = append(filter.Tags, types.Tag{Name: kv[0], Value: kv[1]})
+	}
+	cursor, err := strconv.ParseInt(c.DefaultQuery("cursor", "0"), 10, 64)
+	if err != nil {
+		errorResponse(c, err.Error())
+		return
+	}
+	num, err := strconv.Atoi(c.DefaultQuery("num", strconv.Itoa(schema.IndexQueryDefaultNum)))
+	if err != nil {
+		errorResponse(c, err.Error())
+		return
+	}
+	if num <= 0 || num > schema.IndexQueryMaxNum {
+		num = schema.IndexQueryMaxNum
+	}
+
+	items, nextCursor, err := s.store.QueryIndexedItems(filter, cursor, num)
+	if err != nil {
+		if err == schema.ErrNilIndexFilter {
+			errorResponse(c, err.Error())
+		} else {
+			internalErrorResponse(c, err.Error())
+		}
+		return
+	}
+	resp := schema.RespIndexedItems{Items: items}
+	if nextCursor > 0 {
+		resp.Cursor = strconv.FormatInt(nextCursor, 10)
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
 func (s *Arseeding) bundleFee(c *gin.Context) {
 	size := c.Param("size")
 	symbol := c.Param("currency")
@@ -953,7 +1048,7 @@ func (s *Arseeding) getOrders(c *gin.Context) {
 			RespOrder: schema.RespOrder{
 				ItemId:             od.ItemId,
 				Size:               od.Size,
-				Bundler:            s.bundler.Signer.Address,
+				Bundler:            s.bundler.Signer.Address(),
 				Currency:           od.Currency,
 				Decimals:           od.Decimals,
 				Fee:                od.Fee,
@@ -975,22 +1070,15 @@ func (s *Arseeding) bundleFees(c *gin.Context) {
 
 func (s *Arseeding) dataRoute(c *gin.Context) {
 	txId := c.Param("id")
-	tmpFileName := genTmpFileName(c.ClientIP(), txId)
-	if existTmpFile(tmpFileName) {
-		dataReader, err := os.Open(tmpFileName)
-		if err != nil {
-			internalErrorResponse(c, err.Error())
-			return
-		}
-		item, err := s.store.LoadItemMeta(txId)
-		if err != nil {
-			internalErrorResponse(c, err.Error())
-			return
-		}
-		dataResponse(c, dataReader, nil, item.Tags, txId)
+	// HEAD goes through the same routing as GET, only the data response is answered from local index
+	if c.Request.Method == http.MethodHead && !s.isManifestRoute(txId) && s.dataHeadResponse(c, txId, "") {
 		return
 	}
+	s.setDataMetaHeaders(c, txId)
 	tags, dataReader, data, err := getArTxOrItemData(txId, s.store)
+	if err == nil && data != nil {
+		s.saveTxDataSha256(c, txId, data)
+	}
 	switch err {
 	case nil:
 		// process manifest
@@ -1019,12 +1107,125 @@ func (s *Arseeding) dataRoute(c *gin.Context) {
 		}
 
 	case schema.ErrLocalNotExist:
-		proxyArweaveGateway(c)
+		s.proxyAndReadThrough(c, txId)
+	case schema.ErrDataCorrupted:
+		s.repairAndProxy(c, txId)
 	default:
 		internalErrorResponse(c, err.Error())
 	}
 }
 
+// isManifestRoute the manifest is redirected to its sandbox domain instead of data response
+func (s *Arseeding) isManifestRoute(id string) bool {
+	if !s.EnableManifest {
+		return false
+	}
+	record, err := s.store.LoadIndexRecord(id)
+	return err == nil && record.ContentType == schema.ManifestType
+}
+
+// dataHeadResponse answer HEAD request from local index without loading data, return false if the id can not be answered.
+// txField is the data field of /tx/:arid/:field, its content type is the same as GET, "" for the data response of tx or item
+func (s *Arseeding) dataHeadResponse(c *gin.Context, id, txField string) bool {
+	record, ok := s.setDataMetaHeaders(c, id)
+	if !ok {
+		return false
+	}
+	size := record.DataSize
+	contentType := dataContentType(record.ContentType, !record.IsItem)
+	if txField != "" {
+		if record.IsItem {
+			return false
+		}
+		contentType, _ = txDataFieldContentType(txField)
+		if txField == "data" {
+			size = int64(base64.RawURLEncoding.EncodedLen(int(size)))
+		}
+	}
+	c.Header("ETag", dataEtag(id))
+	c.Header("Cache-Control", schema.DataCacheControl)
+	c.Header("Accept-Ranges", "bytes")
+	if contentType != "" {
+		c.Header("Content-Type", contentType)
+	}
+	if c.GetHeader("If-None-Match") == dataEtag(id) {
+		c.Status(http.StatusNotModified)
+		return true
+	}
+	c.Header("Content-Length", strconv.FormatInt(size, 10))
+	c.Status(http.StatusOK)
+	return true
+}
+
+// setDataMetaHeaders set X-Arweave-* metadata headers of local tx or item, the data is not loaded.
+// return false if the id is not indexed or the data of tx is not in local
+func (s *Arseeding) setDataMetaHeaders(c *gin.Context, id string) (record schema.IndexRecord, ok bool) {
+	record, err := s.store.LoadIndexRecord(id)
+	if err != nil {
+		return
+	}
+	if !record.IsItem {
+		txMeta, err := s.store.LoadTxMeta(id)
+		if err != nil || !s.store.IsExistTxData(txMeta.DataRoot, txMeta.DataSize) {
+			return record, false
+		}
+	}
+	tags, err := getArTxOrItemTags(id, s.store)
+	if err != nil {
+		return record, false
+	}
+
+	c.Header("X-Arweave-Owner", record.Owner)
+	for _, tg := range tags {
+		if validHeaderField(tg.Name, tg.Value) {
+			c.Writer.Header().Add("X-Arweave-Tag-"+tg.Name, tg.Value)
+		}
+	}
+	if record.BundledIn != "" {
+		c.Header("X-Bundled-In", record.BundledIn)
+	}
+	// tx data is synced by chunks, its digest is saved when the data is loaded by GET
+	if record.DataSha256 != "" {
+		c.Header("X-Data-Sha256", record.DataSha256)
+	}
+	return record, true
+}
+
+// saveTxDataSha256 save the digest of tx data loaded in memory, so that it can be answered without loading data later
+func (s *Arseeding) saveTxDataSha256(c *gin.Context, id string, data []byte) {
+	if c.Writer.Header().Get("X-Data-Sha256") != "" {
+		return
+	}
+	record, err := s.store.LoadIndexRecord(id)
+	if err != nil || record.IsItem {
+		return
+	}
+	if err = s.store.SaveIndexDataSha256(id, data); err != nil {
+		log.Error("s.store.SaveIndexDataSha256(id,data)", "err", err, "id", id)
+		return
+	}
+	hash := sha256.Sum256(data)
+	c.Header("X-Data-Sha256", hex.EncodeToString(hash[:]))
+}
+
+// validHeaderField tag name must be a http token and value must not contain control characters
+func validHeaderField(name, value string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("!#$%&'*+-.^_`|~", r)) {
+			return false
+		}
+	}
+	for _, r := range value {
+		if r < ' ' && r != '\t' || r == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
 func (s *Arseeding) setManifestUrl(c *gin.Context) {
 	txId := c.Param("id")
 	mfUrl := expectedTxSandbox(txId)
@@ -1114,152 +1315,44 @@ func internalErrorResponse(c *gin.Context, err string) {
 	})
 }
 
-func dataResponse(c *gin.Context, dataReader *os.File, data []byte, tags []types.Tag, id string) {
-	defer func() {
-		if dataReader != nil {
-			decFileCnt(dataReader.Name())
-			dataReader.Close()
-		}
-	}()
-
+func dataResponse(c *gin.Context, dataReader io.ReadSeekCloser, data []byte, tags []types.Tag, id string) {
 	contentType := getTagValue(tags, schema.ContentType)
 	if dataReader != nil {
-		tmpFileName := genTmpFileName(c.ClientIP(), id)
-		if dataReader.Name() != tmpFileName {
-			err := os.Rename(dataReader.Name(), tmpFileName)
-			if err != nil {
-				internalErrorResponse(c, "data is replied")
-				return
-			}
-			dataReader.Close()
-			dataReader, err = os.Open(tmpFileName)
-			if err != nil {
-				internalErrorResponse(c, "data is replied")
-				return
-			}
-		}
-
-		incFileCnt(tmpFileName)
-		err := dataRangeResponse(c, dataReader, contentType)
-		if err != nil {
-			internalErrorResponse(c, err.Error())
-		}
+		defer dataReader.Close()
+		dataRangeResponse(c, dataReader, dataContentType(contentType, false), id)
 	} else {
-		c.Data(200, fmt.Sprintf("%s; charset=utf-8", contentType), data)
+		dataRangeResponse(c, bytes.NewReader(data), dataContentType(contentType, true), id)
 	}
 }
 
-func dataRangeResponse(c *gin.Context, dataReader *os.File, contentType string) (err error) {
-
-	// get fileInfo
-	fileInfo, err := dataReader.Stat()
-	if err != nil {
-		return fmt.Errorf("Error getting file info")
-	}
-
-	// fetch Range header
-	rangeHeader := c.Request.Header.Get("Range")
-	if rangeHeader == "" {
-		c.Header("Accept-Ranges", "bytes")
-		c.Header("Content-Type", contentType)
-		c.Header("Content-Length", strconv.FormatInt(fileInfo.Size(), 10))
-		c.File(dataReader.Name())
-		return
-	}
-
-	// parse Range header
-	parts := strings.Split(rangeHeader, "=")
-	if len(parts) != 2 || parts[0] != "bytes" {
-		return fmt.Errorf("Invalid Range header")
+// dataContentType return the content type of data response, tx data is loaded in memory and served as utf-8
+func dataContentType(contentType string, inMemory bool) string {
+	if inMemory {
+		return fmt.Sprintf("%s; charset=utf-8", contentType)
 	}
+	return contentType
+}
 
-	ranges := strings.Split(parts[1], "-")
-	if len(ranges) != 2 {
-		return fmt.Errorf("Invalid Range header")
-	}
-
-	// parse Range start-end
-	var start, end int64
-
-	if ranges[0] == "" {
-		end, err = strconv.ParseInt(ranges[1], 10, 64)
-		if err != nil {
-			end = fileInfo.Size() - 1
-		}
-		start = fileInfo.Size() - end
-		end = fileInfo.Size() - 1
-	} else if ranges[1] == "" {
-		start, err = strconv.ParseInt(ranges[0], 10, 64)
-		if err != nil {
-			start = 0
-		}
-		end = fileInfo.Size() - 1
-	} else {
-		start, err = strconv.ParseInt(ranges[0], 10, 64)
-		if err != nil {
-			start = 0
-		}
-		end, err = strconv.ParseInt(ranges[1], 10, 64)
-		if err != nil {
-			end = fileInfo.Size() - 1
+// dataRangeResponse serve content with RFC 7233 range semantics: suffix and multiple ranges, If-Range and 416.
+// data of an id is immutable, so it is served with strong id etag, long cache time and 304 for If-None-Match
+func dataRangeResponse(c *gin.Context, content io.ReadSeeker, contentType, id string) {
+	// If-Range with date can not be compared by http.ServeContent without Last-Modified, immutable data match any date
+	if ifRange := c.GetHeader("If-Range"); ifRange != "" {
+		c.Request.Header.Del("If-Range")
+		if strings.HasPrefix(ifRange, "W/") || (strings.HasPrefix(ifRange, `"`) && ifRange != dataEtag(id)) {
+			c.Request.Header.Del("Range")
 		}
 	}
-
-	// calculate Range size
-	contentLength := end - start + 1
-
-	// set header
-	c.Header("Content-Type", contentType)
-	c.Header("Accept-Ranges", "bytes")
-	c.Header("Content-Length", strconv.FormatInt(contentLength, 10))
-	c.Header("Content-Range", "bytes "+strconv.FormatInt(start, 10)+"-"+strconv.FormatInt(end, 10)+"/"+strconv.FormatInt(fileInfo.Size(), 10))
-	c.Status(http.StatusPartialContent)
-
-	// send Range data
-	_, err = dataReader.Seek(start, 0)
-	if err != nil {
-		return fmt.Errorf("error seeking file, err: %v", err)
-	}
-
-	buffer := make([]byte, 1024*1024)
-	for contentLength > 0 {
-		size := int64(len(buffer))
-		if size > contentLength {
-			size = contentLength
-		}
-
-		n, err := dataReader.Read(buffer[:size])
-		if err != nil {
-			return fmt.Errorf("Error reading file")
-		}
-
-		c.Writer.Write(buffer[:n])
-		contentLength -= int64(n)
+	c.Header("ETag", dataEtag(id))
+	if c.Writer.Header().Get("Cache-Control") == "" { // e.g. manifest path is cached shortly
+		c.Header("Cache-Control", schema.DataCacheControl)
 	}
-	return nil
-}
-
-func genTmpFileName(ip, itemId string) string {
-	return fmt.Sprintf("%s/%s-read-%s", schema.TmpFileDir, ip, itemId)
-}
-
-func existTmpFile(tmpFileName string) bool {
-	tmpFileMapLock.Lock()
-	defer tmpFileMapLock.Unlock()
-	_, ok := tmpFileMap[tmpFileName]
-	return ok
-}
-
-func incFileCnt(tmpFileName string) {
-	tmpFileMapLock.Lock()
-	defer tmpFileMapLock.Unlock()
-	tmpFileMap[tmpFileName] += 1
+	c.Header("Content-Type", contentType)
+	http.ServeContent(c.Writer, c.Request, "", time.Time{}, content)
 }
 
-func decFileCnt(tmpFileName string) {
-	tmpFileMapLock.Lock()
-	defer tmpFileMapLock.Unlock()
-	tmpFileMap[tmpFileName] -= 1
+func dataEtag(id string) string {
+	return `"` + id + `"`
 }
 
 func (s *Arseeding) getApiKeyInfo(c *gin.Context) {
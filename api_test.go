@@ -0,0 +1,209 @@
+package arseeding
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/everFinance/arseeding/schema"
+	"github.com/everFinance/goar"
+	"github.com/everFinance/goar/types"
+	"github.com/everFinance/goar/utils"
+	"github.com/everFinance/goether"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestDataRangeResponse(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	data := []byte("0123456789")
+	id := "test-id"
+	serve := func(header map[string]string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/"+id, nil)
+		for k, v := range header {
+			c.Request.Header.Set(k, v)
+		}
+		dataRangeResponse(c, bytes.NewReader(data), "text/plain", id)
+		c.Writer.WriteHeaderNow()
+		return w
+	}
+
+	w := serve(nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "bytes", w.Header().Get("Accept-Ranges"))
+	assert.Equal(t, string(data), w.Body.String())
+	assert.Equal(t, dataEtag(id), w.Header().Get("ETag"))
+	assert.Equal(t, schema.DataCacheControl, w.Header().Get("Cache-Control"))
+
+	// conditional get
+	w = serve(map[string]string{"If-None-Match": dataEtag(id)})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Equal(t, 0, w.Body.Len())
+	w = serve(map[string]string{"If-None-Match": `"other"`})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// single and suffix range
+	w = serve(map[string]string{"Range": "bytes=2-4"})
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, "bytes 2-4/10", w.Header().Get("Content-Range"))
+	assert.Equal(t, "234", w.Body.String())
+	w = serve(map[string]string{"Range": "bytes=-3"})
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, "789", w.Body.String())
+
+	// multiple ranges
+	w = serve(map[string]string{"Range": "bytes=0-1,5-6"})
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	assert.True(t, strings.HasPrefix(w.Header().Get("Content-Type"), "multipart/byteranges; boundary="))
+	assert.Contains(t, w.Body.String(), "Content-Range: bytes 0-1/10")
+	assert.Contains(t, w.Body.String(), "Content-Range: bytes 5-6/10")
+
+	// unsatisfiable
+	w = serve(map[string]string{"Range": "bytes=20-30"})
+	assert.Equal(t, http.StatusRequestedRangeNotSatisfiable, w.Code)
+	assert.Equal(t, "bytes */10", w.Header().Get("Content-Range"))
+
+	// If-Range
+	w = serve(map[string]string{"Range": "bytes=2-4", "If-Range": dataEtag(id)})
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	w = serve(map[string]string{"Range": "bytes=2-4", "If-Range": `"other"`})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, string(data), w.Body.String())
+}
+
+func TestDataHeadResponse(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dbPath := "./data/tmp.db"
+	eccSigner, err := goether.NewSigner("4c3f9a1e5b234ce8f1ab58d82f849c0f70a4d5ceaf2b6e2d9a6c58b1f897ef0a")
+	assert.NoError(t, err)
+	itemSigner, err := goar.NewItemSigner(eccSigner)
+	assert.NoError(t, err)
+	s, err := NewBoltStore(dbPath)
+	assert.NoError(t, err)
+	defer os.RemoveAll(dbPath)
+	aa := &Arseeding{store: s}
+
+	data := []byte("hello arseeding")
+	item, err := itemSigner.CreateAndSignItem(data, "", "", []types.Tag{
+		{Name: "Content-Type", Value: "text/plain"},
+		{Name: "App-Name", Value: "arseeding"},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, aa.saveItem(item))
+	assert.NoError(t, s.SaveArIdToItemIds("bundle-arId", []string{item.Id}))
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodHead, "/"+item.Id, nil)
+	c.Params = gin.Params{{Key: "id", Value: item.Id}}
+	aa.dataRoute(c)
+	c.Writer.WriteHeaderNow()
+
+	hash := sha256.Sum256(data)
+	owner, err := Base64Address(item.Owner)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 0, w.Body.Len())
+	assert.Equal(t, strconv.Itoa(len(data)), w.Header().Get("Content-Length"))
+	assert.Equal(t, "text/plain", w.Header().Get("Content-Type"))
+	assert.Equal(t, owner, w.Header().Get("X-Arweave-Owner"))
+	assert.Equal(t, "arseeding", w.Header().Get("X-Arweave-Tag-App-Name"))
+	assert.Equal(t, "bundle-arId", w.Header().Get("X-Bundled-In"))
+	assert.Equal(t, hex.EncodeToString(hash[:]), w.Header().Get("X-Data-Sha256"))
+}
+
+func TestDataHeadResponseTx(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dbPath := "./data/tmp.db"
+	eccSigner, err := goether.NewSigner("4c3f9a1e5b234ce8f1ab58d82f849c0f70a4d5ceaf2b6e2d9a6c58b1f897ef0a")
+	assert.NoError(t, err)
+	itemSigner, err := goar.NewItemSigner(eccSigner)
+	assert.NoError(t, err)
+	owner, err := itemSigner.CreateAndSignItem([]byte("owner"), "", "", nil)
+	assert.NoError(t, err)
+	s, err := NewBoltStore(dbPath)
+	assert.NoError(t, err)
+	defer os.RemoveAll(dbPath)
+	wdb := NewSqliteDb("./data/sqlite")
+	defer os.RemoveAll("./data/sqlite")
+	assert.NoError(t, wdb.Migrate(false, true))
+	aa := &Arseeding{store: s, wdb: wdb, EnableManifest: true}
+
+	saveTx := func(id, contentType string, data []byte) {
+		chunks, err := utils.GenerateChunks(data)
+		assert.NoError(t, err)
+		arTx := types.Transaction{
+			ID:       id,
+			Owner:    owner.Owner,
+			Tags:     utils.TagsEncode([]types.Tag{{Name: "Content-Type", Value: contentType}}),
+			DataRoot: utils.Base64Encode(chunks.DataRoot),
+			DataSize: strconv.Itoa(len(data)),
+		}
+		assert.NoError(t, s.SaveTxMeta(arTx))
+		assert.NoError(t, s.SaveTxIndex(arTx))
+		assert.NoError(t, aa.syncAddTxDataEndOffset(arTx.DataRoot, arTx.DataSize))
+		assert.NoError(t, setTxDataChunks(arTx, data, s))
+	}
+	serve := func(method, id string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(method, "/"+id, nil)
+		c.Params = gin.Params{{Key: "id", Value: id}}
+		aa.dataRoute(c)
+		c.Writer.WriteHeaderNow()
+		return w
+	}
+
+	// the digest of tx data is not calculated by HEAD
+	data := make([]byte, 2*types.MAX_CHUNK_SIZE+100)
+	rand.Read(data)
+	saveTx("tx-id", "text/plain", data)
+	w := serve(http.MethodHead, "tx-id")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, strconv.Itoa(len(data)), w.Header().Get("Content-Length"))
+	assert.Equal(t, "", w.Header().Get("X-Data-Sha256"))
+
+	// it is saved once the data is loaded by GET
+	hash := sha256.Sum256(data)
+	w = serve(http.MethodGet, "tx-id")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, hex.EncodeToString(hash[:]), w.Header().Get("X-Data-Sha256"))
+	w = serve(http.MethodHead, "tx-id")
+	assert.Equal(t, hex.EncodeToString(hash[:]), w.Header().Get("X-Data-Sha256"))
+
+	// HEAD is answered with the same status and headers as GET
+	serveField := func(method, id, field string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(method, "/tx/"+id+"/"+field, nil)
+		c.Params = gin.Params{{Key: "arid", Value: id}, {Key: "field", Value: field}}
+		aa.getTxField(c)
+		c.Writer.WriteHeaderNow()
+		return w
+	}
+	get, head := serve(http.MethodGet, "tx-id"), serve(http.MethodHead, "tx-id")
+	assert.Equal(t, "text/plain; charset=utf-8", get.Header().Get("Content-Type"))
+	assert.Equal(t, get.Header().Get("Content-Type"), head.Header().Get("Content-Type"))
+	for _, field := range []string{"data", "data.json", "data.txt", "data.pdf", "data.png", "data.jpeg", "data.gif", "data.mp4", "data.xyz"} {
+		get, head = serveField(http.MethodGet, "tx-id", field), serveField(http.MethodHead, "tx-id", field)
+		assert.Equal(t, get.Code, head.Code, field)
+		assert.Equal(t, get.Header().Get("Content-Type"), head.Header().Get("Content-Type"), field)
+		assert.Equal(t, get.Header().Get("Content-Length"), head.Header().Get("Content-Length"), field)
+	}
+	assert.Equal(t, http.StatusBadRequest, head.Code)
+
+	// manifest is redirected by HEAD as GET
+	saveTx("manifest-id", schema.ManifestType, []byte(`{"manifest":"arweave/paths","version":"0.1.0","paths":{}}`))
+	for _, method := range []string{http.MethodGet, http.MethodHead} {
+		w = serve(method, "manifest-id")
+		assert.Equal(t, http.StatusFound, w.Code)
+	}
+}
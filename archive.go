@@ -0,0 +1,294 @@
+package arseeding
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/everFinance/arseeding/schema"
+	"github.com/everFinance/goar/types"
+	"github.com/gin-gonic/gin"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+)
+
+// archiveItem is a file of the uploaded archive, signed as a bundle item
+type archiveItem struct {
+	path string
+	size int64
+	item types.BundleItem // signed item without DataReader, so files are not kept open or in memory
+	file string           // the tmp file of item data
+}
+
+// open the signed item with its data in tmp file, DataReader must be closed by caller
+func (it *archiveItem) open() (types.BundleItem, error) {
+	f, err := os.Open(it.file)
+	if err != nil {
+		return types.BundleItem{}, err
+	}
+	item := it.item
+	item.DataReader = f
+	return item, nil
+}
+
+// submitArchive upload a tar(.gz) or zip archive with X-API-KEY, every file is submitted as a bundle item,
+// then a manifest of all files is submitted. query params: index (default index.html), fallback
+func (s *Arseeding) submitArchive(c *gin.Context) {
+	apiKey := c.GetHeader("X-API-KEY")
+	if len(apiKey) == 0 {
+		errorResponse(c, "Wrong X-API-KEY")
+		return
+	}
+	if _, err := s.wdb.GetApiKeyDetail(apiKey); err != nil {
+		errorResponse(c, fmt.Sprintf("Wrong X-API-KEY: %s", err.Error()))
+		return
+	}
+	if c.Request.Body == nil {
+		errorResponse(c, "can not submit null archive")
+		return
+	}
+	defer c.Request.Body.Close()
+
+	archiveFile, err := os.CreateTemp(schema.TmpFileDir, "arseed-archive-")
+	if err != nil {
+		internalErrorResponse(c, err.Error())
+		return
+	}
+	defer func() {
+		archiveFile.Close()
+		os.Remove(archiveFile.Name())
+	}()
+	size, err := io.Copy(archiveFile, io.LimitReader(c.Request.Body, schema.SubmitMaxSize+1))
+	if err != nil {
+		errorResponse(c, err.Error())
+		return
+	}
+	if size > schema.SubmitMaxSize {
+		errorResponse(c, schema.ErrDataTooBig.Error())
+		return
+	}
+
+	items, err := readArchiveItems(archiveFile, size, s.bundlerItemSigner)
+	defer func() {
+		for _, it := range items {
+			if it.file != "" {
+				os.Remove(it.file)
+			}
+		}
+	}()
+	if err != nil {
+		errorResponse(c, err.Error())
+		return
+	}
+
+	manifest, err := archiveManifest(items, c.Query("index"), c.Query("fallback"))
+	if err != nil {
+		errorResponse(c, err.Error())
+		return
+	}
+	manifestBy, err := json.Marshal(manifest)
+	if err != nil {
+		internalErrorResponse(c, err.Error())
+		return
+	}
+	manifestItem, err := s.bundlerItemSigner.CreateAndSignItem(manifestBy, "", "", []types.Tag{
+		{Name: "Type", Value: "manifest"},
+		{Name: schema.ContentType, Value: schema.ManifestType},
+	})
+	if err != nil {
+		internalErrorResponse(c, err.Error())
+		return
+	}
+
+	// charge all items at once, so no item is submitted if balance is insufficient
+	currency := c.Param("currency")
+	sizes := make([]int64, 0, len(items)+1)
+	for _, it := range items {
+		sizes = append(sizes, it.size)
+	}
+	sizes = append(sizes, int64(len(manifestBy)))
+	if err = s.processApikeySpendBal(currency, apiKey, sizes...); err != nil {
+		errorResponse(c, err.Error())
+		return
+	}
+
+	needSort := isSortItems(c)
+	resp := schema.RespArchive{Items: make([]schema.RespArchiveItem, 0, len(items))}
+	for _, it := range items {
+		item, err := it.open()
+		if err != nil {
+			internalErrorResponse(c, err.Error())
+			return
+		}
+		order, err := s.ProcessSubmitItem(item, currency, true, apiKey, needSort, it.size)
+		item.DataReader.Close()
+		if err != nil {
+			log.Error("s.ProcessSubmitItem(archive item)", "err", err, "path", it.path)
+			internalErrorResponse(c, err.Error())
+			return
+		}
+		resp.Items = append(resp.Items, schema.RespArchiveItem{Path: it.path, ItemId: order.ItemId, Size: order.Size})
+	}
+	order, err := s.ProcessSubmitItem(manifestItem, currency, true, apiKey, needSort, int64(len(manifestBy)))
+	if err != nil {
+		log.Error("s.ProcessSubmitItem(archive manifest)", "err", err)
+		internalErrorResponse(c, err.Error())
+		return
+	}
+	resp.ManifestId = order.ItemId
+	c.JSON(http.StatusOK, resp)
+}
+
+// readArchiveItems walk regular files of zip, tar or tar.gz archive and sign them as bundle items
+func readArchiveItems(archiveFile *os.File, size int64, signer ItemSigner) (items []*archiveItem, err error) {
+	magic := make([]byte, 4)
+	if _, err = archiveFile.ReadAt(magic, 0); err != nil {
+		return nil, errors.New("invalid archive")
+	}
+	if _, err = archiveFile.Seek(0, io.SeekStart); err != nil {
+		return
+	}
+
+	total := int64(0)
+	seen := make(map[string]struct{})
+	addFile := func(name string, r io.Reader, fileSize int64) error {
+		p := path.Clean("/" + name)[1:] // never out of root
+		if p == "" {
+			return nil
+		}
+		// e.g. a/./b and a/b are the same path of manifest
+		if _, ok := seen[p]; ok {
+			return fmt.Errorf("duplicate file in archive: %s", p)
+		}
+		seen[p] = struct{}{}
+		if len(items) >= schema.ArchiveMaxFiles {
+			return fmt.Errorf("archive contain more than %d files", schema.ArchiveMaxFiles)
+		}
+		if total += fileSize; total > schema.SubmitMaxSize {
+			return schema.ErrDataTooBig
+		}
+		it, err := newArchiveItem(p, r, fileSize, signer)
+		if it != nil {
+			items = append(items, it)
+		}
+		return err
+	}
+
+	switch {
+	case bytes.Equal(magic, []byte("PK\x03\x04")) || bytes.Equal(magic, []byte("PK\x05\x06")):
+		zr, err := zip.NewReader(archiveFile, size)
+		if err != nil {
+			return items, err
+		}
+		for _, f := range zr.File {
+			if !f.Mode().IsRegular() {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return items, err
+			}
+			err = addFile(f.Name, rc, int64(f.UncompressedSize64))
+			rc.Close()
+			if err != nil {
+				return items, err
+			}
+		}
+	default:
+		var r io.Reader = archiveFile
+		if magic[0] == 0x1f && magic[1] == 0x8b {
+			gr, err := gzip.NewReader(archiveFile)
+			if err != nil {
+				return items, err
+			}
+			defer gr.Close()
+			r = gr
+		}
+		tr := tar.NewReader(r)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return items, err
+			}
+			if hdr.Typeflag != tar.TypeReg {
+				continue
+			}
+			if err = addFile(hdr.Name, tr, hdr.Size); err != nil {
+				return items, err
+			}
+		}
+	}
+	if len(items) == 0 {
+		return nil, errors.New("archive has no file")
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].path < items[j].path
+	})
+	return
+}
+
+func newArchiveItem(p string, r io.Reader, size int64, signer ItemSigner) (*archiveItem, error) {
+	contentType := mime.TypeByExtension(path.Ext(p))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	tags := []types.Tag{{Name: schema.ContentType, Value: contentType}}
+	it := &archiveItem{path: p, size: size}
+
+	// all files are written to tmp files, an archive may contain ArchiveMaxFiles small files
+	f, err := os.CreateTemp(schema.TmpFileDir, "arseed-")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	it.file = f.Name()
+	if _, err = io.CopyN(f, r, size); err != nil {
+		return it, err
+	}
+	if _, err = f.Seek(0, io.SeekStart); err != nil {
+		return it, err
+	}
+	it.item, err = signer.CreateAndSignItemStream(f, "", "", tags)
+	it.item.DataReader = nil
+	return it, err
+}
+
+// archiveManifest assemble manifest of all archive items, v0.2.0 is used when fallback is set
+func archiveManifest(items []*archiveItem, index, fallback string) (*schema.ManifestData, error) {
+	manifest := &schema.ManifestData{
+		Manifest: "arweave/paths",
+		Version:  "0.1.0",
+		Paths:    make(map[string]schema.Resource, len(items)),
+	}
+	for _, it := range items {
+		manifest.Paths[it.path] = schema.Resource{TxId: it.item.Id}
+	}
+
+	if index != "" {
+		if _, ok := manifest.Paths[index]; !ok {
+			return nil, fmt.Errorf("index file not found in archive: %s", index)
+		}
+		manifest.Index.Path = index
+	} else if _, ok := manifest.Paths["index.html"]; ok {
+		manifest.Index.Path = "index.html"
+	}
+	if fallback != "" {
+		res, ok := manifest.Paths[fallback]
+		if !ok {
+			return nil, fmt.Errorf("fallback file not found in archive: %s", fallback)
+		}
+		manifest.Version = "0.2.0"
+		manifest.Fallback = &res
+	}
+	return manifest, nil
+}
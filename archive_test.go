@@ -0,0 +1,111 @@
+package arseeding
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"github.com/everFinance/arseeding/schema"
+	"github.com/everFinance/goar"
+	"github.com/everFinance/goar/utils"
+	"github.com/everFinance/goether"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"os"
+	"testing"
+)
+
+func TestReadArchiveItems(t *testing.T) {
+	assert.NoError(t, os.MkdirAll(schema.TmpFileDir, os.ModePerm))
+	defer os.RemoveAll(schema.TmpFileDir)
+	eccSigner, err := goether.NewSigner("4c3f9a1e5b234ce8f1ab58d82f849c0f70a4d5ceaf2b6e2d9a6c58b1f897ef0a")
+	assert.NoError(t, err)
+	itemSigner, err := goar.NewItemSigner(eccSigner)
+	assert.NoError(t, err)
+
+	files := map[string]string{
+		"index.html":    "<html></html>",
+		"js/app.js":     "console.log(1)",
+		"../escape.txt": "escape",
+	}
+	zipBuf := &bytes.Buffer{}
+	zw := zip.NewWriter(zipBuf)
+	_, err = zw.Create("js/")
+	assert.NoError(t, err)
+	tarBuf := &bytes.Buffer{}
+	gw := gzip.NewWriter(tarBuf)
+	tw := tar.NewWriter(gw)
+	assert.NoError(t, tw.WriteHeader(&tar.Header{Name: "js/", Typeflag: tar.TypeDir, Mode: 0755}))
+	for name, content := range files {
+		w, err := zw.Create(name)
+		assert.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		assert.NoError(t, err)
+		assert.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(content))}))
+		_, err = tw.Write([]byte(content))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, zw.Close())
+	assert.NoError(t, tw.Close())
+	assert.NoError(t, gw.Close())
+
+	for _, archive := range [][]byte{zipBuf.Bytes(), tarBuf.Bytes()} {
+		f, err := os.CreateTemp("", "archive-")
+		assert.NoError(t, err)
+		_, err = f.Write(archive)
+		assert.NoError(t, err)
+		items, err := readArchiveItems(f, int64(len(archive)), itemSigner)
+		f.Close()
+		os.Remove(f.Name())
+		assert.NoError(t, err)
+
+		assert.Equal(t, 3, len(items))
+		assert.Equal(t, "escape.txt", items[0].path)
+		assert.Equal(t, "index.html", items[1].path)
+		assert.Equal(t, "js/app.js", items[2].path)
+		assert.Equal(t, "text/html; charset=utf-8", items[1].item.Tags[0].Value)
+		item, err := items[2].open()
+		assert.NoError(t, err)
+		data, err := io.ReadAll(item.DataReader)
+		assert.NoError(t, err)
+		assert.Equal(t, "console.log(1)", string(data))
+		_, err = item.DataReader.Seek(0, io.SeekStart)
+		assert.NoError(t, err)
+		assert.NoError(t, utils.VerifyBundleItem(item))
+		item.DataReader.Close()
+		for _, it := range items {
+			os.Remove(it.file)
+		}
+
+		manifest, err := archiveManifest(items, "", "index.html")
+		assert.NoError(t, err)
+		assert.Equal(t, "index.html", manifest.Index.Path)
+		assert.Equal(t, "0.2.0", manifest.Version)
+		assert.Equal(t, items[1].item.Id, manifest.Fallback.TxId)
+		assert.Equal(t, items[2].item.Id, manifest.Paths["js/app.js"].TxId)
+		_, err = archiveManifest(items, "home.html", "")
+		assert.Error(t, err)
+	}
+
+	// duplicate paths after clean are rejected
+	dupBuf := &bytes.Buffer{}
+	zw = zip.NewWriter(dupBuf)
+	for _, name := range []string{"a/b", "a/./b"} {
+		w, err := zw.Create(name)
+		assert.NoError(t, err)
+		_, err = w.Write([]byte(name))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, zw.Close())
+	f, err := os.CreateTemp("", "archive-")
+	assert.NoError(t, err)
+	defer os.Remove(f.Name())
+	defer f.Close()
+	_, err = f.Write(dupBuf.Bytes())
+	assert.NoError(t, err)
+	items, err := readArchiveItems(f, int64(dupBuf.Len()), itemSigner)
+	assert.EqualError(t, err, "duplicate file in archive: a/b")
+	for _, it := range items {
+		os.Remove(it.file)
+	}
+}
@@ -6,8 +6,11 @@ import (
 	"fmt"
 	"github.com/everFinance/arseeding/schema"
 	"github.com/everFinance/goether"
+	"github.com/gin-gonic/gin"
 	"github.com/permadao/goao"
 	goarSchema "github.com/permadao/goar/schema"
+	"net/http"
+	"sort"
 	"strings"
 )
 
@@ -17,6 +20,7 @@ const (
 
 type ArNs struct {
 	aoCli *goao.Client
+	cache *ttlCache
 }
 
 func NewArNs(cuUrl, muUrl string) *ArNs {
@@ -25,7 +29,7 @@ func NewArNs(cuUrl, muUrl string) *ArNs {
 	if err != nil {
 		panic(err)
 	}
-	return &ArNs{aoCli: aoCli}
+	return &ArNs{aoCli: aoCli, cache: newTtlCache(schema.ArNsMaxCachedNames)}
 }
 
 func (a *ArNs) GetRootDomainRecord(rootDomain string) (record *schema.DomainRecord, err error) {
@@ -60,31 +64,145 @@ func (a *ArNs) GetDomainProcessState(pid string) (state *schema.DomainState, err
 	return
 }
 
+// QueryDomainTxId return txId of ArNS name, undername of any level is supported
 func (a *ArNs) QueryDomainTxId(domain string) (string, error) {
-	spliteDomains := strings.Split(domain, "_")
-	if len(spliteDomains) > 2 { // todo now only support level-2 subdomain
-		return "", errors.New("current arseeding gw not support over level-2 subdomain")
+	res, err := a.Resolve(domain)
+	if err != nil {
+		return "", err
+	}
+	return res.TxId, nil
+}
+
+// Resolve ArNS name with cache, the result is cached for ttlSeconds of the record,
+// not found name is cached for schema.ArNsNotFoundTtlSeconds
+func (a *ArNs) Resolve(name string) (*schema.ArNsResolved, error) {
+	name = strings.ToLower(name)
+	if val, err, ttl, ok := a.cache.get(name); ok {
+		if err != nil {
+			return nil, err
+		}
+		res := *val.(*schema.ArNsResolved)
+		res.TtlSeconds = int64(ttl.Seconds()) // remaining ttl
+		res.Cached = true
+		return &res, nil
+	}
+	res, err := a.resolve(name)
+	switch {
+	case err == nil:
+		a.cache.set(name, res, nil, res.TtlSeconds)
+	case errors.Is(err, schema.ErrArNsNotFound):
+		a.cache.set(name, nil, err, schema.ArNsNotFoundTtlSeconds)
+	}
+	return res, err
+}
+
+func (a *ArNs) resolve(name string) (*schema.ArNsResolved, error) {
+	rootName, undername := splitArNsName(name)
+	if rootName == "" {
+		return nil, schema.ErrArNsNotFound
 	}
-	rootDomain := spliteDomains[len(spliteDomains)-1]
 	// step1 query root domain process state
-	record, err := a.GetRootDomainRecord(rootDomain)
+	processId, state, err := a.GetRootDomainState(rootName)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	state, err := a.GetDomainProcessState(record.ProcessId)
+
+	// step2 query record of undername
+	return resolveArNsRecord(name, rootName, undername, processId, state)
+}
+
+// GetRootDomainState return process id and state of root name
+func (a *ArNs) GetRootDomainState(rootName string) (processId string, state *schema.DomainState, err error) {
+	record, err := a.GetRootDomainRecord(rootName)
 	if err != nil {
-		return "", err
+		return
+	}
+	if record == nil || record.ProcessId == "" {
+		return "", nil, schema.ErrArNsNotFound
 	}
+	state, err = a.GetDomainProcessState(record.ProcessId)
+	return record.ProcessId, state, err
+}
 
-	// step2 query latest txId
-	// Currently, only level-1 domain name resolution is queried
-	subdomain := spliteDomains[0]
-	if subdomain == rootDomain {
-		subdomain = "@"
+// resolveArNs return resolved record and cache status of ArNS name
+func (s *Arseeding) resolveArNs(c *gin.Context) {
+	res, err := s.arnsCli.Resolve(c.Param("name"))
+	if err != nil {
+		arnsErrorResponse(c, err)
+		return
 	}
-	res, ok := state.Records[subdomain]
-	if !ok {
-		return "", errors.New("not exist record")
+	setArNsHeaders(c, res)
+	c.JSON(http.StatusOK, res)
+}
+
+// getArNsRecords list undername records of root name
+func (s *Arseeding) getArNsRecords(c *gin.Context) {
+	rootName := strings.ToLower(c.Param("rootName"))
+	processId, state, err := s.arnsCli.GetRootDomainState(rootName)
+	if err != nil {
+		arnsErrorResponse(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, arnsRecords(rootName, processId, state))
+}
+
+func arnsRecords(rootName, processId string, state *schema.DomainState) schema.RespArNsRecords {
+	resp := schema.RespArNsRecords{RootName: rootName, ProcessId: processId, Records: make([]schema.ArNsRecord, 0)}
+	if state == nil {
+		return resp
+	}
+	for undername, rec := range state.Records {
+		resp.Records = append(resp.Records, schema.ArNsRecord{
+			Undername:  undername,
+			TxId:       rec.TransactionId,
+			TtlSeconds: rec.TtlSeconds,
+		})
+	}
+	sort.Slice(resp.Records, func(i, j int) bool {
+		return resp.Records[i].Undername < resp.Records[j].Undername
+	})
+	return resp
+}
+
+func arnsErrorResponse(c *gin.Context, err error) {
+	if errors.Is(err, schema.ErrArNsNotFound) {
+		notFoundResponse(c, err.Error())
+		return
 	}
-	return res.TransactionId, nil
+	internalErrorResponse(c, err.Error())
+}
+
+func resolveArNsRecord(name, rootName, undername, processId string, state *schema.DomainState) (*schema.ArNsResolved, error) {
+	if state == nil {
+		return nil, schema.ErrArNsNotFound
+	}
+	rec, ok := state.Records[undername]
+	if !ok || rec.TransactionId == "" {
+		return nil, schema.ErrArNsNotFound
+	}
+	ttl := rec.TtlSeconds
+	if ttl <= 0 {
+		ttl = schema.DefaultArNsTtlSeconds
+	}
+	return &schema.ArNsResolved{
+		Name:       name,
+		RootName:   rootName,
+		Undername:  undername,
+		ProcessId:  processId,
+		TxId:       rec.TransactionId,
+		TtlSeconds: ttl,
+	}, nil
+}
+
+// splitArNsName split name by the last "_", root name can not contain "_", undername can be multi level
+func splitArNsName(name string) (rootName, undername string) {
+	i := strings.LastIndex(name, "_")
+	if i < 0 {
+		return name, "@"
+	}
+	rootName, undername = name[i+1:], name[:i]
+	if undername == "" {
+		undername = "@"
+	}
+	return
 }
@@ -1,7 +1,12 @@
 package arseeding
 
 import (
+	"encoding/json"
+	"github.com/everFinance/arseeding/schema"
+	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 )
 
@@ -30,3 +35,84 @@ func TestArNs_GetDomainRecord(t *testing.T) {
 	assert.NoError(t, err)
 	t.Log(txId)
 }
+
+func TestSplitArNsName(t *testing.T) {
+	for name, expected := range map[string][2]string{
+		"ardrive":         {"ardrive", "@"},
+		"docs_ardrive":    {"ardrive", "docs"},
+		"v1_docs_ardrive": {"ardrive", "v1_docs"},
+		"_ardrive":        {"ardrive", "@"},
+	} {
+		rootName, undername := splitArNsName(name)
+		assert.Equal(t, expected, [2]string{rootName, undername}, name)
+	}
+}
+
+func TestArNsResolveCache(t *testing.T) {
+	state := &schema.DomainState{}
+	assert.NoError(t, json.Unmarshal([]byte(`{"Records":{"@":{"transactionId":"root-tx"},"v1_docs":{"transactionId":"docs-tx","ttlSeconds":120}}}`), state))
+
+	res, err := resolveArNsRecord("v1_docs_ardrive", "ardrive", "v1_docs", "pid", state)
+	assert.NoError(t, err)
+	assert.Equal(t, "docs-tx", res.TxId)
+	assert.Equal(t, int64(120), res.TtlSeconds)
+	res, err = resolveArNsRecord("ardrive", "ardrive", "@", "pid", state)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(schema.DefaultArNsTtlSeconds), res.TtlSeconds)
+	_, err = resolveArNsRecord("missing_ardrive", "ardrive", "missing", "pid", state)
+	assert.Equal(t, schema.ErrArNsNotFound, err)
+
+	// resolved from cache without querying ao
+	arns := &ArNs{cache: newTtlCache(schema.ArNsMaxCachedNames)}
+	arns.cache.set("v1_docs_ardrive", &schema.ArNsResolved{Name: "v1_docs_ardrive", TxId: "docs-tx", TtlSeconds: 120}, nil, 120)
+	arns.cache.set("missing_ardrive", nil, schema.ErrArNsNotFound, schema.ArNsNotFoundTtlSeconds)
+	res, err = arns.Resolve("V1_Docs_ArDrive")
+	assert.NoError(t, err)
+	assert.Equal(t, "docs-tx", res.TxId)
+	assert.True(t, res.TtlSeconds > 0 && res.TtlSeconds <= 120)
+	_, err = arns.Resolve("missing_ardrive")
+	assert.Equal(t, schema.ErrArNsNotFound, err)
+
+	// expired
+	arns.cache.set("expired_ardrive", &schema.ArNsResolved{TxId: "old"}, nil, -1)
+	_, _, _, ok := arns.cache.get("expired_ardrive")
+	assert.False(t, ok)
+}
+
+func TestArNsApi(t *testing.T) {
+	state := &schema.DomainState{}
+	assert.NoError(t, json.Unmarshal([]byte(`{"Records":{"docs":{"transactionId":"docs-tx","ttlSeconds":120},"@":{"transactionId":"root-tx","ttlSeconds":3600}}}`), state))
+	assert.Equal(t, schema.RespArNsRecords{
+		RootName:  "ardrive",
+		ProcessId: "pid",
+		Records: []schema.ArNsRecord{
+			{Undername: "@", TxId: "root-tx", TtlSeconds: 3600},
+			{Undername: "docs", TxId: "docs-tx", TtlSeconds: 120},
+		},
+	}, arnsRecords("ardrive", "pid", state))
+
+	s := &Arseeding{arnsCli: &ArNs{cache: newTtlCache(schema.ArNsMaxCachedNames)}}
+	s.arnsCli.cache.set("docs_ardrive", &schema.ArNsResolved{Name: "docs_ardrive", RootName: "ardrive", Undername: "docs", ProcessId: "pid", TxId: "docs-tx", TtlSeconds: 120}, nil, 120)
+	s.arnsCli.cache.set("missing_ardrive", nil, schema.ErrArNsNotFound, schema.ArNsNotFoundTtlSeconds)
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Params = gin.Params{{Key: "name", Value: "docs_ardrive"}}
+	c.Request = httptest.NewRequest(http.MethodGet, "/arns/resolve/docs_ardrive", nil)
+	s.resolveArNs(c)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "docs-tx", w.Header().Get("X-ArNS-Resolved-Id"))
+	res := schema.ArNsResolved{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	assert.Equal(t, "docs", res.Undername)
+	assert.Equal(t, "pid", res.ProcessId)
+	assert.True(t, res.Cached)
+
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Params = gin.Params{{Key: "name", Value: "missing_ardrive"}}
+	c.Request = httptest.NewRequest(http.MethodGet, "/arns/resolve/missing_ardrive", nil)
+	s.resolveArNs(c)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -2,7 +2,6 @@ package arseeding
 
 import (
 	"context"
-	"github.com/everFinance/arseeding/cache"
 	"github.com/everFinance/arseeding/config"
 	"github.com/everFinance/arseeding/rawdb"
 	"github.com/everFinance/arseeding/schema"
@@ -40,8 +39,9 @@ type Arseeding struct {
 	arseedCli           *sdk.ArSeedCli
 	everpaySdk          *paySdk.SDK
 	wdb                 *Wdb
-	bundler             *goar.Wallet
-	bundlerItemSigner   *goar.ItemSigner
+	bundler             *Bundler     // receives fees and signs items
+	bundlers            *BundlerPool // post bundle arTxs, bundler is the first one
+	bundlerItemSigner   ItemSigner
 	NoFee               bool // if true, means no bundle fee; default false
 	EnableManifest      bool
 	bundlePerFeeMap     map[string]schema.Fee // key: tokenSymbol, val: fee per chunk_size(256KB)
@@ -49,7 +49,16 @@ type Arseeding struct {
 	expectedRange       int64                 // default 50 block
 	customTags          []types.Tag
 	locker              sync.RWMutex
-	localCache          *cache.Cache
+	readThrough         *ReadThrough // nil means disabled
+	repairer            dataRepairer
+	gateways            *Gateways
+	apiHosts            map[string]struct{}
+	customDomains       customDomains
+	adminKey            string
+	nameResolvers       []NameResolver // consulted in order
+	bundlePolicy        schema.BundlePolicy
+	bundleTags          *bundleTags
+	resubmitPolicy      schema.ResubmitPolicy
 }
 
 func New(
@@ -59,6 +68,12 @@ func New(
 	use4EVER bool, useAliyun bool, aliyunEndpoint, aliyunAccKey, aliyunSecretKey, aliyunPrefix string,
 	useMongoDb bool, mongodbUri string,
 	port string, customTags []types.Tag, useKafka bool, kafkaUri string,
+	readThrough schema.ReadThrough, verifyOnRead bool, gateways []schema.Gateway,
+	apiHosts []string, adminKey string,
+	nameResolvers []string, dnsResolver string,
+	bundlePolicy schema.BundlePolicy, bundleTagsCfg schema.BundleTags,
+	bundlerWallets schema.BundlerWallets, bundlerSignerCfg schema.BundlerSigner,
+	resubmitPolicy schema.ResubmitPolicy,
 ) *Arseeding {
 	var err error
 	KVDb := &Store{}
@@ -82,6 +97,7 @@ func New(
 	if err != nil {
 		panic(err)
 	}
+	KVDb.VerifyOnRead = verifyOnRead
 
 	jobmg := NewTaskMg()
 	if err := jobmg.InitTaskMg(KVDb); err != nil {
@@ -96,16 +112,35 @@ func New(
 	if err = wdb.Migrate(noFee, enableManifest); err != nil {
 		panic(err)
 	}
-	bundler, err := goar.NewWalletFromPath(arWalletKeyPath, arNode)
+	bundlerSigner, err := newBundlerSigner(bundlerSignerCfg, arWalletKeyPath)
 	if err != nil {
 		panic(err)
 	}
+	bundler := NewBundler(bundlerSigner, arNode)
 
-	itemSigner, err := goar.NewItemSigner(bundler.Signer)
+	bundlers, err := NewBundlerPool(bundler, arNode, bundlerWallets)
 	if err != nil {
 		panic(err)
 	}
-	everpaySdk, err := paySdk.New(bundler.Signer, payUrl)
+
+	paySigner, _ := everpaySigner(bundlerSigner)
+	everpaySdk, err := paySdk.New(paySigner, payUrl)
+	if err != nil {
+		panic(err)
+	}
+
+	gws, err := NewGateways(gateways)
+	if err != nil {
+		panic(err)
+	}
+
+	arnsCli := NewArNs(cuUrl, "")
+	resolvers, err := newNameResolvers(nameResolvers, arnsCli, dnsResolver)
+	if err != nil {
+		panic(err)
+	}
+
+	bundleTags, err := newBundleTags(bundleTagsCfg)
 	if err != nil {
 		panic(err)
 	}
@@ -118,20 +153,29 @@ func New(
 		submitLocker:        sync.Mutex{},
 		endOffsetLocker:     sync.Mutex{},
 		arCli:               goar.NewClient(arNode),
-		arnsCli:             NewArNs(cuUrl, ""),
+		arnsCli:             arnsCli,
 		taskMg:              jobmg,
 		scheduler:           gocron.NewScheduler(time.UTC),
 		arseedCli:           sdk.New(localArseedUrl),
 		everpaySdk:          everpaySdk,
 		wdb:                 wdb,
 		bundler:             bundler,
-		bundlerItemSigner:   itemSigner,
+		bundlers:            bundlers,
+		bundlerItemSigner:   &bundlerItemSigner{signer: bundlerSigner},
 		NoFee:               noFee,
 		EnableManifest:      enableManifest,
 		bundlePerFeeMap:     make(map[string]schema.Fee),
 		paymentExpiredRange: schema.DefaultPaymentExpiredRange,
 		expectedRange:       schema.DefaultExpectedRange,
 		customTags:          customTags,
+		readThrough:         NewReadThrough(readThrough),
+		gateways:            gws,
+		apiHosts:            newApiHosts(apiHosts),
+		adminKey:            adminKey,
+		nameResolvers:       resolvers,
+		bundlePolicy:        newBundlePolicy(bundlePolicy),
+		bundleTags:          bundleTags,
+		resubmitPolicy:      newResubmitPolicy(resubmitPolicy),
 	}
 
 	// init cache
@@ -153,11 +197,6 @@ func New(
 		a.KWriters = kwriters
 	}
 
-	localCache, err := cache.NewLocalCache(60 * time.Minute)
-	if err != nil {
-		log.Error("NewLocalCache", "err", err)
-	}
-	a.localCache = localCache
 	return a
 }
 
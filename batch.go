@@ -0,0 +1,330 @@
+package arseeding
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/everFinance/arseeding/schema"
+	"github.com/everFinance/goar/types"
+	"github.com/everFinance/goar/utils"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
+	"os"
+)
+
+// bundleHeader is the header of an item in ANS-104 bundle binary
+type bundleHeader struct {
+	id     string
+	offset int64
+	size   int64
+}
+
+// batchItem is a verified item of submitted bundle
+type batchItem struct {
+	item types.BundleItem
+	size int64
+}
+
+func closeBatchItems(items []batchItem) {
+	for _, it := range items {
+		if it.item.DataReader != nil {
+			it.item.DataReader.Close()
+			os.Remove(it.item.DataReader.Name())
+		}
+	}
+}
+
+// submitBatch accept an ANS-104 bundle, or a bundle item with "Bundle-Format: binary" tag whose data is the bundle.
+// all inner items are verified before any of them is stored, and their orders are inserted in one db transaction.
+// query param nest=true keeps the nesting on chain: the bundle item is the only order,
+// a raw bundle is signed by bundler only after it is paid by X-API-KEY, so bundler never signs unpaid client content
+func (s *Arseeding) submitBatch(c *gin.Context) {
+	if c.GetHeader("Content-Type") != "application/octet-stream" {
+		errorResponse(c, "Wrong body type")
+		return
+	}
+	if c.Request.Body == nil {
+		errorResponse(c, "can not submit null bundle")
+		return
+	}
+	defer c.Request.Body.Close()
+
+	bodyFile, err := os.CreateTemp(schema.TmpFileDir, "arseed-batch-")
+	if err != nil {
+		internalErrorResponse(c, err.Error())
+		return
+	}
+	defer func() {
+		bodyFile.Close()
+		os.Remove(bodyFile.Name())
+	}()
+	size, err := io.Copy(bodyFile, io.LimitReader(c.Request.Body, schema.SubmitMaxSize+1))
+	if err != nil {
+		errorResponse(c, err.Error())
+		return
+	}
+	if size > schema.SubmitMaxSize {
+		errorResponse(c, schema.ErrDataTooBig.Error())
+		return
+	}
+
+	// the body is a raw bundle or a bundle item
+	var bundleItem *types.BundleItem
+	bundleReader, bundleSize := io.ReaderAt(bodyFile), size
+	headers, err := parseBundleHeaders(bodyFile, size)
+	if err != nil {
+		if bundleItem, bundleReader, bundleSize, err = decodeNestedBundleItem(bodyFile, size); err != nil {
+			errorResponse(c, err.Error())
+			return
+		}
+		defer closeBatchItems([]batchItem{{item: *bundleItem}})
+		if headers, err = parseBundleHeaders(bundleReader, bundleSize); err != nil {
+			errorResponse(c, err.Error())
+			return
+		}
+	}
+
+	items, err := decodeBatchItems(bundleReader, headers)
+	defer closeBatchItems(items)
+	if err != nil {
+		errorResponse(c, err.Error())
+		return
+	}
+
+	nest := c.Query("nest") == "true"
+	apikey := c.GetHeader("X-API-KEY")
+	if nest && bundleItem == nil && len(apikey) == 0 {
+		errorResponse(c, "raw bundle can only be nested with X-API-KEY, submit a signed bundle item instead")
+		return
+	}
+
+	// charge all items at once, so no item is submitted if balance is insufficient
+	currency := c.Param("currency")
+	sizes := make([]int64, 0, len(items))
+	if nest {
+		sizes = append(sizes, size)
+	} else {
+		for _, it := range items {
+			sizes = append(sizes, it.size)
+		}
+	}
+	if len(apikey) > 0 {
+		if err = s.processApikeySpendBal(currency, apikey, sizes...); err != nil {
+			errorResponse(c, err.Error())
+			return
+		}
+	}
+	noFee := s.NoFee || len(apikey) > 0
+	needSort := isSortItems(c)
+
+	if nest && bundleItem == nil {
+		// sign the paid raw bundle by bundler, so that it can be posted as a nested bundle
+		item, err := s.signNestedBundle(bodyFile, size)
+		if err != nil {
+			internalErrorResponse(c, err.Error())
+			return
+		}
+		bundleItem = &item
+	}
+
+	for _, it := range items {
+		if err = s.saveBatchItem(it.item); err != nil {
+			log.Error("s.saveBatchItem(it.item)", "err", err, "itemId", it.item.Id)
+			internalErrorResponse(c, err.Error())
+			return
+		}
+	}
+	orderItems := items
+	if nest {
+		if err = s.saveBatchItem(*bundleItem); err != nil {
+			log.Error("s.saveBatchItem(bundleItem)", "err", err, "itemId", bundleItem.Id)
+			internalErrorResponse(c, err.Error())
+			return
+		}
+		orderItems = []batchItem{{item: *bundleItem, size: size}}
+	}
+
+	orders := make([]schema.Order, 0, len(orderItems))
+	for _, it := range orderItems {
+		order, err := s.newItemOrder(it.item, currency, noFee, apikey, needSort, it.size)
+		if err != nil {
+			errorResponse(c, err.Error())
+			return
+		}
+		orders = append(orders, order)
+	}
+	if err = s.wdb.InsertOrders(orders); err != nil {
+		log.Error("s.wdb.InsertOrders(orders)", "err", err)
+		internalErrorResponse(c, err.Error())
+		return
+	}
+
+	resp := schema.RespBatch{Orders: make([]schema.RespOrder, 0, len(orders))}
+	if nest {
+		resp.NestItemId = bundleItem.Id
+	}
+	for _, ord := range orders {
+		resp.Orders = append(resp.Orders, schema.RespOrder{
+			ItemId:             ord.ItemId,
+			Size:               ord.Size,
+			Bundler:            s.bundler.Signer.Address(),
+			Currency:           ord.Currency,
+			Decimals:           ord.Decimals,
+			Fee:                ord.Fee,
+			PaymentExpiredTime: ord.PaymentExpiredTime,
+			ExpectedBlock:      ord.ExpectedBlock,
+		})
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (s *Arseeding) signNestedBundle(bundleFile *os.File, size int64) (types.BundleItem, error) {
+	tags := []types.Tag{
+		{Name: "Bundle-Format", Value: "binary"},
+		{Name: "Bundle-Version", Value: "2.0.0"},
+	}
+	if _, err := bundleFile.Seek(0, io.SeekStart); err != nil {
+		return types.BundleItem{}, err
+	}
+	if size > schema.AllowStreamMinItemSize {
+		return s.bundlerItemSigner.CreateAndSignItemStream(bundleFile, "", "", tags)
+	}
+	data, err := io.ReadAll(bundleFile)
+	if err != nil {
+		return types.BundleItem{}, err
+	}
+	return s.bundlerItemSigner.CreateAndSignItem(data, "", "", tags)
+}
+
+func (s *Arseeding) saveBatchItem(item types.BundleItem) error {
+	if item.DataReader != nil { // reset io stream to origin of the file
+		if _, err := item.DataReader.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+	}
+	return s.saveItem(item)
+}
+
+// parseBundleHeaders read and check the headers of ANS-104 bundle binary, the item binaries must fill the bundle exactly
+func parseBundleHeaders(r io.ReaderAt, size int64) ([]bundleHeader, error) {
+	errInvalid := errors.New("invalid bundle binary")
+	if size < 32 {
+		return nil, errInvalid
+	}
+	countBy := make([]byte, 32)
+	if _, err := r.ReadAt(countBy, 0); err != nil {
+		return nil, err
+	}
+	count, ok := bundleLong(countBy)
+	if !ok || count == 0 || count > (size-32)/64 {
+		return nil, errInvalid
+	}
+	if count > schema.BatchMaxItems {
+		return nil, fmt.Errorf("bundle items can not more than %d", schema.BatchMaxItems)
+	}
+
+	headerBy := make([]byte, 64*count)
+	if _, err := r.ReadAt(headerBy, 32); err != nil {
+		return nil, err
+	}
+	headers := make([]bundleHeader, 0, count)
+	offset := 32 + 64*count
+	for i := int64(0); i < count; i++ {
+		h := headerBy[i*64 : (i+1)*64]
+		itemSize, ok := bundleLong(h[:32])
+		if !ok || itemSize == 0 || itemSize > size-offset {
+			return nil, errInvalid
+		}
+		headers = append(headers, bundleHeader{id: utils.Base64Encode(h[32:]), offset: offset, size: itemSize})
+		offset += itemSize
+	}
+	if offset != size {
+		return nil, errInvalid
+	}
+	return headers, nil
+}
+
+// bundleLong decode the 32 bytes little endian number, false if it is too big
+func bundleLong(b []byte) (int64, bool) {
+	for _, by := range b[7:] {
+		if by != 0 {
+			return 0, false
+		}
+	}
+	return int64(utils.ByteArrayToLong(b[:7])), true
+}
+
+// decodeNestedBundleItem decode the item whose data is a bundle, big item data is written to tmp file
+func decodeNestedBundleItem(f *os.File, size int64) (item *types.BundleItem, bundleReader io.ReaderAt, bundleSize int64, err error) {
+	if _, err = f.Seek(0, io.SeekStart); err != nil {
+		return
+	}
+	if size > schema.AllowStreamMinItemSize {
+		item, err = utils.DecodeBundleItemStream(f)
+	} else {
+		var itemBinary []byte
+		if itemBinary, err = io.ReadAll(f); err != nil {
+			return
+		}
+		item, err = utils.DecodeBundleItem(itemBinary)
+	}
+	if err != nil {
+		return nil, nil, 0, errors.New("body is neither a bundle nor a bundle item")
+	}
+	decoded := *item
+	defer func() {
+		if err != nil {
+			closeBatchItems([]batchItem{{item: decoded}})
+		}
+	}()
+	if getTagValue(item.Tags, "Bundle-Format") != "binary" {
+		return nil, nil, 0, errors.New("bundle item must have tag Bundle-Format: binary")
+	}
+	if err = utils.VerifyBundleItem(*item); err != nil {
+		return nil, nil, 0, fmt.Errorf("verify bundle item failed: %v", err)
+	}
+
+	if item.DataReader != nil {
+		fileInfo, err := item.DataReader.Stat()
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		return item, item.DataReader, fileInfo.Size(), nil
+	}
+	data, err := utils.Base64Decode(item.Data)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	return item, bytes.NewReader(data), int64(len(data)), nil
+}
+
+// decodeBatchItems decode and verify all items of bundle, big items are decoded to tmp file
+func decodeBatchItems(r io.ReaderAt, headers []bundleHeader) ([]batchItem, error) {
+	items := make([]batchItem, 0, len(headers))
+	for _, h := range headers {
+		var item *types.BundleItem
+		var err error
+		section := io.NewSectionReader(r, h.offset, h.size)
+		if h.size > schema.AllowStreamMinItemSize {
+			item, err = utils.DecodeBundleItemStream(section)
+		} else {
+			buf := &bytes.Buffer{}
+			if _, err = io.Copy(buf, section); err != nil {
+				return items, err
+			}
+			item, err = utils.DecodeBundleItem(buf.Bytes())
+		}
+		if err != nil {
+			return items, fmt.Errorf("decode item %s failed: %v", h.id, err)
+		}
+		items = append(items, batchItem{item: *item, size: h.size})
+		if item.Id != h.id {
+			return items, fmt.Errorf("item id not match the bundle header: %s", h.id)
+		}
+		if err = utils.VerifyBundleItem(*item); err != nil {
+			return items, fmt.Errorf("verify item %s failed: %v", h.id, err)
+		}
+	}
+	return items, nil
+}
@@ -0,0 +1,146 @@
+package arseeding
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/everFinance/arseeding/schema"
+	"github.com/everFinance/goar"
+	"github.com/everFinance/goar/types"
+	"github.com/everFinance/goar/utils"
+	"github.com/everFinance/goether"
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestSubmitBatch(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dbPath := "./data/tmp.db"
+	store, err := NewBoltStore(dbPath)
+	assert.NoError(t, err)
+	defer os.RemoveAll(dbPath)
+	wdb := NewSqliteDb("./data/sqlite")
+	defer os.RemoveAll("./data/sqlite")
+	assert.NoError(t, wdb.Migrate(false, true))
+	assert.NoError(t, os.MkdirAll(schema.TmpFileDir, os.ModePerm))
+	defer os.RemoveAll(schema.TmpFileDir)
+
+	eccSigner, err := goether.NewSigner("4c3f9a1e5b234ce8f1ab58d82f849c0f70a4d5ceaf2b6e2d9a6c58b1f897ef0a")
+	assert.NoError(t, err)
+	itemSigner, err := goar.NewItemSigner(eccSigner)
+	assert.NoError(t, err)
+	s := &Arseeding{
+		store:             store,
+		wdb:               wdb,
+		cache:             &Cache{},
+		NoFee:             true,
+		bundler:           &Bundler{Signer: &keyfileSigner{signer: &goar.Signer{Address: "bundler-addr"}}},
+		bundlerItemSigner: itemSigner,
+		bundlePerFeeMap: map[string]schema.Fee{
+			"AR": {Currency: "AR", Decimals: 12, Base: decimal.New(5, 0), PerChunk: decimal.New(1, 0)},
+		},
+	}
+	apiKey := ""
+	submit := func(body []byte, nest bool) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		target := "/bundle/batch/ar"
+		if nest {
+			target += "?nest=true"
+		}
+		c.Request = httptest.NewRequest(http.MethodPost, target, bytes.NewReader(body))
+		c.Request.Header.Set("Content-Type", "application/octet-stream")
+		if apiKey != "" {
+			c.Request.Header.Set("X-API-KEY", apiKey)
+		}
+		c.Params = gin.Params{{Key: "currency", Value: "ar"}}
+		s.submitBatch(c)
+		return w
+	}
+
+	randData := make([]byte, 100*1024)
+	rand.Read(randData)
+	items := make([]types.BundleItem, 0)
+	for _, data := range [][]byte{[]byte("item 1"), []byte("item 2"), randData} {
+		item, err := itemSigner.CreateAndSignItem(data, "", "", []types.Tag{{Name: "Content-Type", Value: "text/plain"}})
+		assert.NoError(t, err)
+		items = append(items, item)
+	}
+	bundle, err := utils.NewBundle(items...)
+	assert.NoError(t, err)
+
+	// tampered item, nothing is stored
+	tampered := append([]byte(nil), bundle.BundleBinary...)
+	tampered[len(tampered)-1] ^= 0xff
+	w := submit(tampered, false)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.False(t, store.IsExistItemBinary(items[0].Id))
+	w = submit([]byte("not a bundle"), false)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// raw bundle, one order per item
+	w = submit(bundle.BundleBinary, false)
+	assert.Equal(t, http.StatusOK, w.Code)
+	resp := schema.RespBatch{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "", resp.NestItemId)
+	assert.Equal(t, 3, len(resp.Orders))
+	for i, item := range items {
+		assert.Equal(t, item.Id, resp.Orders[i].ItemId)
+		assert.Equal(t, "bundler-addr", resp.Orders[i].Bundler)
+		assert.True(t, store.IsExistItemBinary(item.Id))
+	}
+	assert.Equal(t, int64(len(items[0].ItemBinary)), resp.Orders[0].Size)
+	ords, err := wdb.GetNeedOnChainOrders()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(ords))
+
+	// bundle item keeps nesting, only the bundle item has order
+	nestItem, err := itemSigner.CreateAndSignNestedItem("", "", nil, items[:2]...)
+	assert.NoError(t, err)
+	w = submit(nestItem.ItemBinary, true)
+	assert.Equal(t, http.StatusOK, w.Code)
+	resp = schema.RespBatch{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, nestItem.Id, resp.NestItemId)
+	assert.Equal(t, 1, len(resp.Orders))
+	assert.Equal(t, nestItem.Id, resp.Orders[0].ItemId)
+	assert.True(t, store.IsExistItemBinary(nestItem.Id))
+
+	plainItem, err := itemSigner.CreateAndSignItem([]byte("not bundle"), "", "", nil)
+	assert.NoError(t, err)
+	w = submit(plainItem.ItemBinary, true)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// raw bundle is signed by bundler to keep nesting, only when it is paid by apikey
+	w = submit(bundle.BundleBinary, true)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.NoError(t, wdb.InsertApiKey(schema.AutoApiKey{ApiKey: "key1", Address: "addr1", TokenBalance: map[string]interface{}{"AR": "0"}}))
+	apiKey = "key1"
+	w = submit(bundle.BundleBinary, true)
+	assert.Equal(t, http.StatusBadRequest, w.Code) // balance is insufficient
+	assert.NoError(t, wdb.UpdateApikeyTokenBal("addr1", map[string]interface{}{"AR": "1000"}))
+	w = submit(bundle.BundleBinary, true)
+	assert.Equal(t, http.StatusOK, w.Code)
+	resp = schema.RespBatch{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.NotEqual(t, "", resp.NestItemId)
+	_, itemBinary, err := store.LoadItemBinary(resp.NestItemId)
+	assert.NoError(t, err)
+	signed, err := utils.DecodeBundleItem(itemBinary)
+	assert.NoError(t, err)
+	assert.NoError(t, utils.VerifyBundleItem(*signed))
+	assert.Equal(t, "binary", getTagValue(signed.Tags, "Bundle-Format"))
+	data, err := utils.Base64Decode(signed.Data)
+	assert.NoError(t, err)
+	assert.Equal(t, bundle.BundleBinary, data)
+
+	ords, err = wdb.GetNeedOnChainOrders()
+	assert.NoError(t, err)
+	assert.Equal(t, 5, len(ords))
+}
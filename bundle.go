@@ -27,6 +27,19 @@ func (s *Arseeding) ProcessSubmitItem(item types.BundleItem, currency string, is
 		return schema.Order{}, err
 	}
 
+	order, err := s.newItemOrder(item, currency, isNoFeeMode, apiKey, isSort, size)
+	if err != nil {
+		return schema.Order{}, err
+	}
+	// insert to mysql
+	if err = s.wdb.InsertOrder(order); err != nil {
+		return schema.Order{}, err
+	}
+	return order, nil
+}
+
+// newItemOrder assemble the order of item with fee, it is not inserted
+func (s *Arseeding) newItemOrder(item types.BundleItem, currency string, isNoFeeMode bool, apiKey string, isSort bool, size int64) (schema.Order, error) {
 	signerAddr, err := utils.ItemSignerAddr(item)
 	if err != nil {
 		return schema.Order{}, err
@@ -60,11 +73,6 @@ func (s *Arseeding) ProcessSubmitItem(item types.BundleItem, currency string, is
 		order.PaymentExpiredTime = time.Now().Unix() + s.paymentExpiredRange
 		order.PaymentStatus = schema.UnPayment
 	}
-
-	// insert to mysql
-	if err = s.wdb.InsertOrder(order); err != nil {
-		return schema.Order{}, err
-	}
 	return order, nil
 }
 
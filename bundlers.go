@@ -0,0 +1,214 @@
+package arseeding
+
+import (
+	"fmt"
+	"github.com/everFinance/arseeding/schema"
+	"github.com/everFinance/goar/types"
+	"math/big"
+	"sync"
+)
+
+type bundlerWallet struct {
+	*Bundler
+	balance  *big.Float // AR, nil means unknown
+	pending  int        // pending bundle arTxs in db
+	inFlight int        // bundle arTxs being sent
+}
+
+// BundlerPool choose the wallet which posts the bundle arTx
+type BundlerPool struct {
+	wallets    []*bundlerWallet // the first is the primary bundler
+	strategy   string
+	minBalance *big.Float
+	apiKeyPins map[string]int // apikey -> index of wallets
+	tagRoutes  []bundlerTagRoute
+	next       int
+	lock       sync.Mutex
+}
+
+type bundlerTagRoute struct {
+	name, value string
+	idx         int
+}
+
+func NewBundlerPool(primary *Bundler, arNode string, cfg schema.BundlerWallets) (*BundlerPool, error) {
+	wallets := []*Bundler{primary}
+	signerCfgs := make([]schema.BundlerSigner, 0, len(cfg.KeyPaths)+len(cfg.Signers))
+	for _, keyPath := range cfg.KeyPaths {
+		signerCfgs = append(signerCfgs, schema.BundlerSigner{Type: schema.BundlerSignerKeyfile, KeyPath: keyPath})
+	}
+	signerCfgs = append(signerCfgs, cfg.Signers...)
+	for _, signerCfg := range signerCfgs {
+		signer, err := newBundlerSigner(signerCfg, signerCfg.KeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("load bundler wallet %s failed: %v", signerCfg.KeyPath+signerCfg.Url, err)
+		}
+		wallets = append(wallets, NewBundler(signer, arNode))
+	}
+	return newBundlerPool(wallets, cfg)
+}
+
+func newBundlerPool(wallets []*Bundler, cfg schema.BundlerWallets) (*BundlerPool, error) {
+	strategy := cfg.Strategy
+	switch strategy {
+	case "":
+		strategy = schema.BundlerStrategyRoundRobin
+	case schema.BundlerStrategyRoundRobin, schema.BundlerStrategyLeastPending, schema.BundlerStrategyApiKey, schema.BundlerStrategyTag:
+	default:
+		return nil, fmt.Errorf("unknown bundler strategy: %s", cfg.Strategy)
+	}
+	minBalance := cfg.MinBalance
+	if minBalance <= 0 {
+		minBalance = schema.DefaultBundlerMinBalance
+	}
+	p := &BundlerPool{
+		wallets:    make([]*bundlerWallet, 0, len(wallets)),
+		strategy:   strategy,
+		minBalance: big.NewFloat(minBalance),
+		apiKeyPins: make(map[string]int, len(cfg.ApiKeyPins)),
+		tagRoutes:  make([]bundlerTagRoute, 0, len(cfg.TagRoutes)),
+	}
+	walletIdx := make(map[string]int, len(wallets))
+	for _, w := range wallets {
+		addr := w.Signer.Address()
+		if _, ok := walletIdx[addr]; ok {
+			continue
+		}
+		walletIdx[addr] = len(p.wallets)
+		p.wallets = append(p.wallets, &bundlerWallet{Bundler: w})
+	}
+
+	for apiKey, addr := range cfg.ApiKeyPins {
+		idx, ok := walletIdx[addr]
+		if !ok {
+			return nil, fmt.Errorf("apikey pinned bundler %s not found", addr)
+		}
+		p.apiKeyPins[apiKey] = idx
+	}
+	for _, route := range cfg.TagRoutes {
+		idx, ok := walletIdx[route.Bundler]
+		if !ok {
+			return nil, fmt.Errorf("tag routed bundler %s not found", route.Bundler)
+		}
+		p.tagRoutes = append(p.tagRoutes, bundlerTagRoute{name: route.Name, value: route.Value, idx: idx})
+	}
+	return p, nil
+}
+
+func (p *BundlerPool) available(w *bundlerWallet) bool {
+	return w.balance == nil || w.balance.Cmp(p.minBalance) >= 0
+}
+
+// pick choose the wallet to post the bundle of apiKey with arTx tags, and mark it in flight.
+// release must be called after the arTx is sent
+func (p *BundlerPool) pick(apiKey string, tags []types.Tag) (*Bundler, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	idx := -1
+	switch p.strategy {
+	case schema.BundlerStrategyApiKey:
+		if i, ok := p.apiKeyPins[apiKey]; ok && apiKey != "" {
+			idx = i
+		}
+	case schema.BundlerStrategyTag:
+		for _, route := range p.tagRoutes {
+			if getTagValue(tags, route.name) == route.value {
+				idx = route.idx
+				break
+			}
+		}
+	case schema.BundlerStrategyLeastPending:
+		for i, w := range p.wallets {
+			if !p.available(w) {
+				continue
+			}
+			if idx < 0 || w.pending+w.inFlight < p.wallets[idx].pending+p.wallets[idx].inFlight {
+				idx = i
+			}
+		}
+	}
+
+	if idx < 0 || !p.available(p.wallets[idx]) {
+		// round robin, also used if the chosen wallet has not enough balance
+		idx = -1
+		for n := 0; n < len(p.wallets); n++ {
+			i := (p.next + n) % len(p.wallets)
+			if p.available(p.wallets[i]) {
+				idx = i
+				p.next = i + 1
+				break
+			}
+		}
+	}
+	if idx < 0 {
+		return nil, schema.ErrNoBundlerAvailable
+	}
+	w := p.wallets[idx]
+	w.inFlight++
+	return w.Bundler, nil
+}
+
+// pinned the orders of apiKey are posted by its pinned wallet, so they are bundled separately
+func (p *BundlerPool) pinned(apiKey string) bool {
+	if p.strategy != schema.BundlerStrategyApiKey {
+		return false
+	}
+	_, ok := p.apiKeyPins[apiKey]
+	return ok
+}
+
+func (p *BundlerPool) release(w *Bundler) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for _, bw := range p.wallets {
+		if bw.Bundler == w && bw.inFlight > 0 {
+			bw.inFlight--
+		}
+	}
+}
+
+func (p *BundlerPool) addresses() []string {
+	addrs := make([]string, 0, len(p.wallets))
+	for _, w := range p.wallets {
+		addrs = append(addrs, w.Signer.Address())
+	}
+	return addrs
+}
+
+func (p *BundlerPool) setBalance(addr string, bal *big.Float) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for _, w := range p.wallets {
+		if w.Signer.Address() == addr {
+			w.balance = bal
+		}
+	}
+}
+
+// setPending update pending arTx number of all wallets, key: bundler address
+func (p *BundlerPool) setPending(pending map[string]int) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for _, w := range p.wallets {
+		w.pending = pending[w.Signer.Address()]
+	}
+}
+
+func (p *BundlerPool) infos() []schema.BundlerInfo {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	infos := make([]schema.BundlerInfo, 0, len(p.wallets))
+	for _, w := range p.wallets {
+		info := schema.BundlerInfo{
+			Address:   w.Signer.Address(),
+			Pending:   w.pending + w.inFlight,
+			Available: p.available(w),
+		}
+		if w.balance != nil {
+			info.Balance = w.balance.Text('f', 12)
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
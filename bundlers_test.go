@@ -0,0 +1,92 @@
+package arseeding
+
+import (
+	"github.com/everFinance/arseeding/schema"
+	"github.com/everFinance/goar"
+	"github.com/everFinance/goar/types"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"testing"
+)
+
+func TestBundlerPool(t *testing.T) {
+	wallets := make([]*Bundler, 0)
+	for _, addr := range []string{"addr0", "addr1", "addr2", "addr0"} { // addr0 is duplicated
+		wallets = append(wallets, &Bundler{Signer: &keyfileSigner{signer: &goar.Signer{Address: addr}}})
+	}
+	pickAddr := func(p *BundlerPool, apiKey string, tags []types.Tag) string {
+		w, err := p.pick(apiKey, tags)
+		assert.NoError(t, err)
+		p.release(w)
+		return w.Signer.Address()
+	}
+
+	// round robin skips the wallet with low balance
+	p, err := newBundlerPool(wallets, schema.BundlerWallets{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"addr0", "addr1", "addr2"}, p.addresses())
+	p.setBalance("addr1", big.NewFloat(0.01))
+	p.setBalance("addr2", big.NewFloat(1))
+	addrs := make([]string, 0)
+	for i := 0; i < 4; i++ {
+		addrs = append(addrs, pickAddr(p, "", nil))
+	}
+	assert.Equal(t, []string{"addr0", "addr2", "addr0", "addr2"}, addrs)
+	infos := p.infos()
+	assert.False(t, infos[1].Available)
+	assert.Equal(t, "1.000000000000", infos[2].Balance)
+
+	p.setBalance("addr0", big.NewFloat(0))
+	p.setBalance("addr2", big.NewFloat(0))
+	_, err = p.pick("", nil)
+	assert.Equal(t, schema.ErrNoBundlerAvailable, err)
+
+	// least pending counts the arTxs being sent
+	p, err = newBundlerPool(wallets, schema.BundlerWallets{Strategy: schema.BundlerStrategyLeastPending})
+	assert.NoError(t, err)
+	p.setPending(map[string]int{"addr0": 3, "addr1": 1, "addr2": 2})
+	w, err := p.pick("", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "addr1", w.Signer.Address())
+	w2, err := p.pick("", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "addr1", w2.Signer.Address())
+	assert.Equal(t, "addr2", pickAddr(p, "", nil))
+	p.release(w)
+	p.release(w2)
+	assert.Equal(t, 1, p.infos()[1].Pending)
+
+	// apikey pinning
+	p, err = newBundlerPool(wallets, schema.BundlerWallets{
+		Strategy:   schema.BundlerStrategyApiKey,
+		ApiKeyPins: map[string]string{"key1": "addr2"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "addr2", pickAddr(p, "key1", nil))
+	assert.Equal(t, "addr2", pickAddr(p, "key1", nil))
+	assert.Equal(t, "addr0", pickAddr(p, "key2", nil))
+	p.setBalance("addr2", big.NewFloat(0))
+	assert.NotEqual(t, "addr2", pickAddr(p, "key1", nil))
+	// orders of pinned apikey are bundled separately, so the bundle apikey is the pinned one
+	assert.True(t, p.pinned("key1"))
+	assert.False(t, p.pinned("key2"))
+	groups := groupOrds([]schema.Order{{ItemId: "a", ApiKey: "key1"}, {ItemId: "b", ApiKey: "key2"}, {ItemId: "c", ApiKey: "key1"}}, p.pinned)
+	assert.Equal(t, 2, len(groups))
+	assert.Equal(t, "key1", bundleApiKey(groups[0]))
+
+	// tag routing
+	p, err = newBundlerPool(wallets, schema.BundlerWallets{
+		Strategy:  schema.BundlerStrategyTag,
+		TagRoutes: []schema.BundlerTagRoute{{Name: "Tenant", Value: "t1", Bundler: "addr1"}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "addr1", pickAddr(p, "", []types.Tag{{Name: "Tenant", Value: "t1"}}))
+	assert.Equal(t, "addr1", pickAddr(p, "", []types.Tag{{Name: "Tenant", Value: "t1"}}))
+	assert.Equal(t, "addr0", pickAddr(p, "", []types.Tag{{Name: "Tenant", Value: "t2"}}))
+	assert.False(t, p.pinned("key1"))
+
+	_, err = newBundlerPool(wallets, schema.BundlerWallets{Strategy: "random"})
+	assert.Error(t, err)
+	_, err = newBundlerPool(wallets, schema.BundlerWallets{ApiKeyPins: map[string]string{"key1": "unknown"}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,122 @@
+package arseeding
+
+import (
+	"fmt"
+	"github.com/everFinance/arseeding/schema"
+	"github.com/everFinance/goar/types"
+	"io"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// reservedBundleTags are set by bundler to every bundle arTx, see bundleTxTags
+var reservedBundleTags = map[string]struct{}{
+	"Bundle-Format":  {},
+	"Bundle-Version": {},
+}
+
+type tagTemplate struct {
+	name  string
+	value *template.Template
+}
+
+// bundleTagData is the data of tag value template
+type bundleTagData struct {
+	Size     int64
+	ItemNum  int
+	NodeName string
+}
+
+// bundleTags render the tags of bundle arTx
+type bundleTags struct {
+	nodeName   string
+	tags       []tagTemplate
+	uMint      bool
+	apiKeyTags map[string][]tagTemplate
+}
+
+func newBundleTags(cfg schema.BundleTags) (*bundleTags, error) {
+	nodeName := cfg.NodeName
+	if nodeName == "" {
+		nodeName, _ = os.Hostname()
+	}
+	tags := cfg.Tags
+	if len(tags) == 0 {
+		tags = schema.DefaultBundleTags
+	}
+	b := &bundleTags{nodeName: nodeName, uMint: !cfg.DisableUMint, apiKeyTags: make(map[string][]tagTemplate, len(cfg.ApiKeyTags))}
+	var err error
+	if b.tags, err = parseTagTemplates(tags); err != nil {
+		return nil, err
+	}
+	for apiKey, tags := range cfg.ApiKeyTags {
+		if b.apiKeyTags[apiKey], err = parseTagTemplates(tags); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+func parseTagTemplates(tags []types.Tag) ([]tagTemplate, error) {
+	tts := make([]tagTemplate, 0, len(tags))
+	for _, tag := range tags {
+		if tag.Name == "" {
+			return nil, fmt.Errorf("bundle tag name can not be empty")
+		}
+		if _, ok := reservedBundleTags[tag.Name]; ok {
+			return nil, fmt.Errorf("bundle tag %s is reserved", tag.Name)
+		}
+		tmpl, err := template.New(tag.Name).Option("missingkey=error").Parse(tag.Value)
+		if err != nil {
+			return nil, fmt.Errorf("parse bundle tag %s failed: %v", tag.Name, err)
+		}
+		// fields not in bundleTagData only fail in execution, check them before bundles are sent
+		if err = tmpl.Execute(io.Discard, bundleTagData{}); err != nil {
+			return nil, fmt.Errorf("parse bundle tag %s failed: %v", tag.Name, err)
+		}
+		tts = append(tts, tagTemplate{name: tag.Name, value: tmpl})
+	}
+	return tts, nil
+}
+
+// hasApiKeyTags the orders of apiKey are bundled separately if it has own tags, see groupOrds
+func (b *bundleTags) hasApiKeyTags(apiKey string) bool {
+	_, ok := b.apiKeyTags[apiKey]
+	return ok
+}
+
+// render the tags of bundle, apiKey is the owner of all items in bundle, or "" if items belong to different apikeys
+func (b *bundleTags) render(apiKey string, size int64, itemNum int) ([]types.Tag, error) {
+	tts, ok := b.apiKeyTags[apiKey]
+	if !ok || apiKey == "" {
+		tts = b.tags
+	}
+	data := bundleTagData{Size: size, ItemNum: itemNum, NodeName: b.nodeName}
+	tags := make([]types.Tag, 0, len(tts)+len(schema.UMintBundleTags))
+	for _, tt := range tts {
+		value := &strings.Builder{}
+		if err := tt.value.Execute(value, data); err != nil {
+			return nil, fmt.Errorf("render bundle tag %s failed: %v", tt.name, err)
+		}
+		tags = append(tags, types.Tag{Name: tt.name, Value: value.String()})
+	}
+	if b.uMint {
+		tags = append(tags, schema.UMintBundleTags...)
+	}
+	return tags, nil
+}
+
+// bundleApiKey return the apikey if all orders belong to it, else ""
+func bundleApiKey(ords []schema.Order) string {
+	if len(ords) == 0 {
+		return ""
+	}
+	apiKey := ords[0].ApiKey
+	for _, ord := range ords[1:] {
+		if ord.ApiKey != apiKey {
+			return ""
+		}
+	}
+	return apiKey
+}
@@ -0,0 +1,55 @@
+package arseeding
+
+import (
+	"github.com/everFinance/arseeding/schema"
+	"github.com/everFinance/goar/types"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestBundleTags(t *testing.T) {
+	// default tags with U mint tags
+	b, err := newBundleTags(schema.BundleTags{})
+	assert.NoError(t, err)
+	tags, err := b.render("", 100, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, append(append([]types.Tag{}, schema.DefaultBundleTags...), schema.UMintBundleTags...), tags)
+
+	b, err = newBundleTags(schema.BundleTags{
+		NodeName: "node-1",
+		Tags: []types.Tag{
+			{Name: "App-Name", Value: "arseeding"},
+			{Name: "Bundle-Info", Value: "{{.NodeName}}:{{.ItemNum}}:{{.Size}}"},
+		},
+		DisableUMint: true,
+		ApiKeyTags: map[string][]types.Tag{
+			"key1": {{Name: "Tenant", Value: "t1-{{.ItemNum}}"}},
+		},
+	})
+	assert.NoError(t, err)
+	tags, err = b.render("", 1024, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, []types.Tag{{Name: "App-Name", Value: "arseeding"}, {Name: "Bundle-Info", Value: "node-1:3:1024"}}, tags)
+	tags, err = b.render("key2", 1024, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, "Bundle-Info", tags[1].Name)
+	tags, err = b.render("key1", 1024, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, []types.Tag{{Name: "Tenant", Value: "t1-3"}}, tags)
+
+	// orders of key1 are bundled separately
+	ords := []schema.Order{{ItemId: "a", ApiKey: "key1"}, {ItemId: "b", ApiKey: "key2"}, {ItemId: "c"}, {ItemId: "d", ApiKey: "key1"}}
+	groups := groupOrds(ords, b.hasApiKeyTags)
+	assert.Equal(t, 2, len(groups))
+	assert.Equal(t, []schema.Order{ords[0], ords[3]}, groups[0])
+	assert.Equal(t, []schema.Order{ords[1], ords[2]}, groups[1])
+	assert.Equal(t, "key1", bundleApiKey(groups[0]))
+	assert.Equal(t, "", bundleApiKey(groups[1]))
+
+	_, err = newBundleTags(schema.BundleTags{Tags: []types.Tag{{Name: "Bad", Value: "{{.Size"}}})
+	assert.Error(t, err)
+	_, err = newBundleTags(schema.BundleTags{Tags: []types.Tag{{Name: "Bad", Value: "{{.Unknown}}"}}})
+	assert.Error(t, err)
+	_, err = newBundleTags(schema.BundleTags{ApiKeyTags: map[string][]types.Tag{"key1": {{Name: "Bundle-Format", Value: "json"}}}})
+	assert.Error(t, err)
+}
@@ -112,7 +112,11 @@ func runServer() {
 		cfg.AliyunKV.UseAliyun, cfg.AliyunKV.Endpoint, cfg.AliyunKV.AccKey, cfg.AliyunKV.SecretKey, cfg.AliyunKV.Prefix,
 		cfg.MongoDBKV.UseMongoDB, cfg.MongoDBKV.Uri,
 		cfg.Port, customTags,
-		cfg.Kafka.Start, cfg.Kafka.Uri)
+		cfg.Kafka.Start, cfg.Kafka.Uri,
+		cfg.ReadThrough, cfg.VerifyOnRead, cfg.Gateways,
+		cfg.ApiHosts, cfg.AdminKey,
+		cfg.NameResolvers, cfg.DnsResolver,
+		cfg.BundlePolicy, cfg.BundleTags, cfg.BundlerWallets, cfg.BundlerSigner, cfg.ResubmitPolicy)
 
 	m.Run(cfg.Port, cfg.BundleInterval)
 
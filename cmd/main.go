@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"github.com/everFinance/arseeding"
 	"github.com/everFinance/arseeding/common"
+	"github.com/everFinance/arseeding/schema"
 	"github.com/everFinance/goar/types"
 	"log"
 	"os"
@@ -55,6 +56,39 @@ func main() {
 			&cli.BoolFlag{Name: "use_kafka", Value: false, Usage: "kafka used", EnvVars: []string{"USE_KAFKA"}},
 			&cli.StringFlag{Name: "kafka_uri", Value: "34.220.174.25:9092", Usage: "kafka uri", EnvVars: []string{"KAFKA_URI"}},
 			// &cli.StringFlag{Name: "kafka_uri", Value: "kafka.corp.knn3.xyz:19092", Usage: "kafka uri", EnvVars: []string{"KAFKA_URI"}},
+
+			// read through gateway data into local store
+			&cli.BoolFlag{Name: "read_through", Value: false, Usage: "store hot gateway data to local", EnvVars: []string{"READ_THROUGH"}},
+			&cli.Int64Flag{Name: "read_through_max_size", Value: schema.DefaultReadThroughMaxDataSize, Usage: "max data size(bytes) to store", EnvVars: []string{"READ_THROUGH_MAX_SIZE"}},
+			&cli.IntFlag{Name: "read_through_min_hits", Value: schema.DefaultReadThroughMinHits, Usage: "store data after requested min hits times", EnvVars: []string{"READ_THROUGH_MIN_HITS"}},
+			&cli.IntFlag{Name: "read_through_hit_window", Value: schema.DefaultReadThroughHitWindow, Usage: "hits counting window(seconds)", EnvVars: []string{"READ_THROUGH_HIT_WINDOW"}},
+
+			// verify data integrity
+			&cli.BoolFlag{Name: "verify_on_read", Value: false, Usage: "verify local data integrity when read", EnvVars: []string{"VERIFY_ON_READ"}},
+
+			// upstream gateways, tried in order of health and latency
+			&cli.StringFlag{Name: "gateways", Value: `[{"url":"https://arweave.net","timeout":30}]`, Usage: "upstream arweave gateways, timeout in seconds", EnvVars: []string{"GATEWAYS"}},
+
+			// permaweb domains
+			&cli.StringSliceFlag{Name: "api_hosts", Value: cli.NewStringSlice(schema.DefaultApiHosts...), Usage: "hosts of api, other hosts are custom domains or ArNS names", EnvVars: []string{"API_HOSTS"}},
+			&cli.StringFlag{Name: "admin_key", Value: "", Usage: "X-ADMIN-KEY of admin api, disabled if empty", EnvVars: []string{"ADMIN_KEY"}},
+			&cli.StringSliceFlag{Name: "name_resolvers", Value: cli.NewStringSlice(schema.NameResolverArNs), Usage: "arns, dns; consulted in order", EnvVars: []string{"NAME_RESOLVERS"}},
+			&cli.StringFlag{Name: "dns_resolver", Value: "", Usage: "dns server(host:port) of dns resolver, system resolver if empty", EnvVars: []string{"DNS_RESOLVER"}},
+
+			// bundle packing policy, e.g. {"enable":true,"maxBundleSize":209715200,"maxItems":5000,"maxWait":120,"minFillSize":10485760,"priority":"fee"}
+			&cli.StringFlag{Name: "bundle_policy", Value: `{"enable":false}`, Usage: "bundle packing policy", EnvVars: []string{"BUNDLE_POLICY"}},
+
+			// bundle arTx tags, value is template, e.g. {"nodeName":"node-1","tags":[{"name":"App-Name","value":"arseeding"},{"name":"Bundle-Items","value":"{{.ItemNum}}"}],"disableUMint":true}
+			&cli.StringFlag{Name: "bundle_tags", Value: `{}`, Usage: "tags of bundle arTx, default tags with U mint tags if empty", EnvVars: []string{"BUNDLE_TAGS"}},
+
+			// extra wallets posting bundle arTxs, e.g. {"keyPaths":["./key2.json"],"signers":[{"type":"remote","url":"https://signer.example","token":"xxx"}],"strategy":"least_pending","minBalance":0.5}
+			&cli.StringFlag{Name: "bundler_wallets", Value: `{}`, Usage: "extra bundler wallets and strategy: round_robin, least_pending, apikey, tag", EnvVars: []string{"BUNDLER_WALLETS"}},
+
+			// signer of key_path bundler, e.g. {"type":"remote","url":"https://signer.example","token":"xxx"}
+			&cli.StringFlag{Name: "bundler_signer", Value: `{}`, Usage: "bundler signer: keyfile or remote, default keyfile of key_path", EnvVars: []string{"BUNDLER_SIGNER"}},
+
+			// resubmit stuck bundle arTx, e.g. {"expiredBlocks":50,"speedSteps":[10,30,60,100],"maxSpend":0,"maxAttempts":10,"alertAttempts":3}
+			&cli.StringFlag{Name: "resubmit_policy", Value: `{}`, Usage: "reward escalation of resubmitted bundle arTx", EnvVars: []string{"RESUBMIT_POLICY"}},
 		},
 		Action: run,
 	}
@@ -82,6 +116,36 @@ func run(c *cli.Context) error {
 		})
 	}
 
+	gateways := make([]schema.Gateway, 0)
+	if err := json.Unmarshal([]byte(c.String("gateways")), &gateways); err != nil {
+		panic(err)
+	}
+
+	bundlePolicy := schema.BundlePolicy{}
+	if err := json.Unmarshal([]byte(c.String("bundle_policy")), &bundlePolicy); err != nil {
+		panic(err)
+	}
+
+	bundleTags := schema.BundleTags{}
+	if err := json.Unmarshal([]byte(c.String("bundle_tags")), &bundleTags); err != nil {
+		panic(err)
+	}
+
+	bundlerWallets := schema.BundlerWallets{}
+	if err := json.Unmarshal([]byte(c.String("bundler_wallets")), &bundlerWallets); err != nil {
+		panic(err)
+	}
+
+	bundlerSigner := schema.BundlerSigner{}
+	if err := json.Unmarshal([]byte(c.String("bundler_signer")), &bundlerSigner); err != nil {
+		panic(err)
+	}
+
+	resubmitPolicy := schema.ResubmitPolicy{}
+	if err := json.Unmarshal([]byte(c.String("resubmit_policy")), &resubmitPolicy); err != nil {
+		panic(err)
+	}
+
 	s := arseeding.New(
 		c.String("db_dir"), c.String("mysql"), c.String("sqlite_dir"), c.Bool("use_sqlite"),
 		c.String("key_path"), c.String("ar_node"), c.String("cu_url"), c.String("pay"), c.Bool("no_fee"), c.Bool("manifest"),
@@ -89,7 +153,17 @@ func run(c *cli.Context) error {
 		c.Bool("use_4ever"), c.Bool("use_aliyun"), c.String("aliyun_endpoint"), c.String("aliyun_acc_key"), c.String("aliyun_secret_key"), c.String("aliyun_prefix"),
 		c.Bool("use_mongodb"), c.String("mongodb_uri"),
 		c.String("port"), customTags,
-		c.Bool("use_kafka"), c.String("kafka_uri"))
+		c.Bool("use_kafka"), c.String("kafka_uri"),
+		schema.ReadThrough{
+			Enable:      c.Bool("read_through"),
+			MaxDataSize: c.Int64("read_through_max_size"),
+			MinHits:     c.Int("read_through_min_hits"),
+			HitWindow:   c.Int("read_through_hit_window"),
+		},
+		c.Bool("verify_on_read"), gateways,
+		c.StringSlice("api_hosts"), c.String("admin_key"),
+		c.StringSlice("name_resolvers"), c.String("dns_resolver"),
+		bundlePolicy, bundleTags, bundlerWallets, bundlerSigner, resubmitPolicy)
 	s.Run(c.String("port"), c.Int("bundle_interval"))
 
 	common.NewMetricServer()
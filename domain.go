@@ -0,0 +1,113 @@
+package arseeding
+
+import (
+	"github.com/everFinance/arseeding/schema"
+	"github.com/gin-gonic/gin"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// customDomains cache the custom domain table, it is reloaded by job so that every instance get the changes of admin api
+type customDomains struct {
+	domains map[string]string // domain -> targetId
+	lock    sync.RWMutex
+}
+
+func (d *customDomains) get(domain string) (string, bool) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	targetId, ok := d.domains[domain]
+	return targetId, ok
+}
+
+func (d *customDomains) set(list []schema.CustomDomain) {
+	domains := make(map[string]string, len(list))
+	for _, cd := range list {
+		domains[cd.Domain] = cd.TargetId
+	}
+	d.lock.Lock()
+	d.domains = domains
+	d.lock.Unlock()
+}
+
+func (s *Arseeding) loadCustomDomains() {
+	list, err := s.wdb.GetCustomDomains()
+	if err != nil {
+		log.Error("s.wdb.GetCustomDomains()", "err", err)
+		return
+	}
+	s.customDomains.set(list)
+}
+
+// normalizeHost lower case host without port and the trailing dot
+func normalizeHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+func newApiHosts(hosts []string) map[string]struct{} {
+	if len(hosts) == 0 {
+		hosts = schema.DefaultApiHosts
+	}
+	apiHosts := make(map[string]struct{}, len(hosts))
+	for _, h := range hosts {
+		apiHosts[normalizeHost(h)] = struct{}{}
+	}
+	return apiHosts
+}
+
+func (s *Arseeding) isApiHost(host string) bool {
+	_, ok := s.apiHosts[normalizeHost(host)]
+	return ok
+}
+
+func (s *Arseeding) getCustomDomains(c *gin.Context) {
+	list, err := s.wdb.GetCustomDomains()
+	if err != nil {
+		internalErrorResponse(c, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// setCustomDomain body: {"targetId": "<manifest, arTx or bundle item id>"}
+func (s *Arseeding) setCustomDomain(c *gin.Context) {
+	domain := normalizeHost(c.Param("domain"))
+	if domain == "" || strings.ContainsAny(domain, "/ ") {
+		errorResponse(c, "invalid domain")
+		return
+	}
+	if s.isApiHost(domain) {
+		errorResponse(c, "domain is api host")
+		return
+	}
+	cd := schema.CustomDomain{}
+	if err := c.ShouldBindJSON(&cd); err != nil {
+		errorResponse(c, err.Error())
+		return
+	}
+	if !isArId(cd.TargetId) {
+		errorResponse(c, "invalid targetId")
+		return
+	}
+	cd.Domain = domain
+	if err := s.wdb.SetCustomDomain(cd); err != nil {
+		internalErrorResponse(c, err.Error())
+		return
+	}
+	s.loadCustomDomains()
+	c.JSON(http.StatusOK, cd)
+}
+
+func (s *Arseeding) delCustomDomain(c *gin.Context) {
+	if err := s.wdb.DelCustomDomain(normalizeHost(c.Param("domain"))); err != nil {
+		internalErrorResponse(c, err.Error())
+		return
+	}
+	s.loadCustomDomains()
+	c.JSON(http.StatusOK, "ok")
+}
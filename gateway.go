@@ -0,0 +1,317 @@
+package arseeding
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/everFinance/arseeding/argraphql"
+	"github.com/everFinance/arseeding/schema"
+	"github.com/everFinance/goar/types"
+	"github.com/everFinance/goar/utils"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Gateway is an upstream arweave gateway, e.g. https://arweave.net
+type Gateway struct {
+	Url     string
+	host    *url.URL
+	timeout time.Duration
+	client  *http.Client // response header must be received in timeout
+
+	healthy bool
+	latency time.Duration // latency of health check
+}
+
+func (g *Gateway) GraphQL() *argraphql.ARGraphQL {
+	return argraphql.NewARGraphQL(g.Url+"/graphql", *g.client)
+}
+
+// getJSON get path of gateway by the client with gateway timeout
+func (g *Gateway) getJSON(path string, res interface{}) error {
+	resp, err := g.client.Get(g.Url + path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%w: get %s statuscode: %d", schema.ErrNotFound, path, resp.StatusCode)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("get %s statuscode: %d", path, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(res)
+}
+
+func (g *Gateway) getChunkData(offset int64) ([]byte, error) {
+	chunk := types.TransactionChunk{}
+	if err := g.getJSON(fmt.Sprintf("/chunk/%d", offset), &chunk); err != nil {
+		return nil, err
+	}
+	return utils.Base64Decode(chunk.Chunk)
+}
+
+// getBundleItems same as goar.Client.GetBundleItems, the header and the items of bundle are loaded by chunks
+func (g *Gateway) getBundleItems(bundleId string, itemIds []string) ([]*types.BundleItem, error) {
+	offset := types.TransactionOffset{}
+	if err := g.getJSON(fmt.Sprintf("/tx/%s/offset", bundleId), &offset); err != nil {
+		return nil, err
+	}
+	size, err := strconv.ParseInt(offset.Size, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	endOffset, err := strconv.ParseInt(offset.Offset, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	startOffset := endOffset - size + 1
+
+	// load bytes [start, end) of bundle data, chunks are split as utils.GenerateChunks
+	lastChunkStart := int64(lastChunkOffset(uint64(size)))
+	load := func(start, end int64) ([]byte, error) {
+		data := make([]byte, 0, end-start)
+		chunkStart := start / types.MAX_CHUNK_SIZE * types.MAX_CHUNK_SIZE
+		if chunkStart > lastChunkStart {
+			chunkStart = lastChunkStart
+		}
+		for pos := chunkStart; pos < end && pos < size; {
+			chunk, err := g.getChunkData(startOffset + pos)
+			if err != nil {
+				return nil, err
+			}
+			if len(chunk) == 0 {
+				return nil, errors.New("empty chunk")
+			}
+			data = append(data, chunk...)
+			pos += int64(len(chunk))
+		}
+		if int64(len(data)) < end-chunkStart {
+			return nil, errors.New("bundle data is incomplete")
+		}
+		return data[start-chunkStart : end-chunkStart], nil
+	}
+
+	numBy, err := load(0, 32)
+	if err != nil {
+		return nil, err
+	}
+	itemNum, ok := bundleLong(numBy)
+	if !ok || 32+itemNum*64 > size {
+		return nil, errors.New("invalid bundle header")
+	}
+	headers, err := load(32, 32+itemNum*64)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]*types.BundleItem, 0, len(itemIds))
+	itemStart := 32 + itemNum*64
+	for i := int64(0); i < itemNum; i++ {
+		itemSize, ok := bundleLong(headers[i*64 : i*64+32])
+		if !ok || itemStart+itemSize > size {
+			return nil, errors.New("invalid bundle header")
+		}
+		if utils.ContainsInSlice(itemIds, utils.Base64Encode(headers[i*64+32:(i+1)*64])) {
+			itemBinary, err := load(itemStart, itemStart+itemSize)
+			if err != nil {
+				return nil, err
+			}
+			item, err := utils.DecodeBundleItem(itemBinary)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		itemStart += itemSize
+	}
+	return items, nil
+}
+
+// Gateways is the configured upstream gateway list, requests are sent to healthy gateways with lower latency first,
+// and fail over to the next gateway on error
+type Gateways struct {
+	gateways []*Gateway // configured order
+	lock     sync.RWMutex
+}
+
+func NewGateways(cfgs []schema.Gateway) (*Gateways, error) {
+	if len(cfgs) == 0 {
+		cfgs = []schema.Gateway{{Url: schema.DefaultGateway}}
+	}
+	gs := &Gateways{gateways: make([]*Gateway, 0, len(cfgs))}
+	for _, cfg := range cfgs {
+		gwUrl := strings.TrimSuffix(cfg.Url, "/")
+		host, err := url.Parse(gwUrl)
+		if err != nil || host.Host == "" {
+			return nil, fmt.Errorf("invalid gateway url: %s", cfg.Url)
+		}
+		timeout := time.Duration(cfg.Timeout) * time.Second
+		if timeout <= 0 {
+			timeout = schema.DefaultGatewayTimeout * time.Second
+		}
+		gs.gateways = append(gs.gateways, &Gateway{
+			Url:     gwUrl,
+			host:    host,
+			timeout: timeout,
+			client: &http.Client{Transport: &http.Transport{
+				Proxy:                 http.ProxyFromEnvironment,
+				ResponseHeaderTimeout: timeout,
+			}},
+			healthy: true,
+		})
+	}
+	return gs, nil
+}
+
+// sorted return healthy gateways by latency, then unhealthy gateways; configured order is kept if latency is equal
+func (gs *Gateways) sorted() []*Gateway {
+	gs.lock.RLock()
+	defer gs.lock.RUnlock()
+	list := make([]*Gateway, len(gs.gateways))
+	copy(list, gs.gateways)
+	healthy := make(map[*Gateway]bool, len(list))
+	latency := make(map[*Gateway]time.Duration, len(list))
+	for _, g := range list {
+		healthy[g], latency[g] = g.healthy, g.latency
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		if healthy[list[i]] != healthy[list[j]] {
+			return healthy[list[i]]
+		}
+		return latency[list[i]] < latency[list[j]]
+	})
+	return list
+}
+
+func (gs *Gateways) setHealth(g *Gateway, healthy bool, latency time.Duration) {
+	gs.lock.Lock()
+	defer gs.lock.Unlock()
+	g.healthy = healthy
+	if latency > 0 {
+		if g.latency > 0 {
+			latency = (g.latency*7 + latency*3) / 10
+		}
+		g.latency = latency
+	}
+}
+
+// Do call fn with gateways one by one until success, the error of last gateway is returned if all failed.
+// error wrapping schema.ErrNotFound means the gateway is available but has no the data, it takes precedence
+func (gs *Gateways) Do(fn func(g *Gateway) error) (err error) {
+	var notFoundErr error
+	for _, g := range gs.sorted() {
+		if err = fn(g); err == nil {
+			return nil
+		}
+		if errors.Is(err, schema.ErrNotFound) {
+			notFoundErr = err
+			continue
+		}
+		log.Warn("gateway request failed, try next gateway", "gateway", g.Url, "err", err)
+		gs.setHealth(g, false, 0)
+	}
+	if notFoundErr != nil {
+		return notFoundErr
+	}
+	return
+}
+
+// HealthCheck request /info of all gateways, and update health and latency
+func (gs *Gateways) HealthCheck() {
+	for _, g := range gs.sorted() {
+		start := time.Now()
+		err := g.checkInfo()
+		if err != nil {
+			log.Warn("gateway health check failed", "gateway", g.Url, "err", err)
+			gs.setHealth(g, false, 0)
+			continue
+		}
+		gs.setHealth(g, true, time.Since(start))
+	}
+}
+
+func (g *Gateway) checkInfo() error {
+	ctx, cancel := context.WithTimeout(context.Background(), g.timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, g.Url+"/info", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status code: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// proxy the request to gateway with body of size (-1 if unknown), error is returned before any response is written, so it can fail over
+func (g *Gateway) proxy(w http.ResponseWriter, req *http.Request, body io.Reader, size int64) (err error) {
+	proxy := &httputil.ReverseProxy{
+		Director: func(r *http.Request) {
+			r.URL.Scheme = g.host.Scheme
+			r.URL.Host = g.host.Host
+			r.Host = g.host.Host
+			r.Body = io.NopCloser(body)
+			r.ContentLength = size
+		},
+		Transport: g.client.Transport,
+		ModifyResponse: func(resp *http.Response) error {
+			if resp.StatusCode >= http.StatusInternalServerError {
+				return fmt.Errorf("status code: %d", resp.StatusCode)
+			}
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, e error) {
+			err = e
+		},
+	}
+	proxy.ServeHTTP(w, req)
+	return
+}
+
+func (s *Arseeding) proxyArweaveGateway(c *gin.Context) {
+	c.Writer.Header().Del("Access-Control-Allow-Origin")
+	var body []byte
+	if c.Request.Body != nil {
+		var err error
+		body, err = io.ReadAll(io.LimitReader(c.Request.Body, schema.GatewayProxyMaxBufferSize+1))
+		if err != nil {
+			errorResponse(c, err.Error())
+			c.Abort()
+			return
+		}
+	}
+
+	var err error
+	if len(body) > schema.GatewayProxyMaxBufferSize {
+		// body too large to buffer for failover, stream it to the first gateway only
+		g := s.gateways.sorted()[0]
+		if err = g.proxy(c.Writer, c.Request, io.MultiReader(bytes.NewReader(body), c.Request.Body), c.Request.ContentLength); err != nil {
+			log.Warn("gateway proxy failed", "gateway", g.Url, "err", err)
+			s.gateways.setHealth(g, false, 0)
+		}
+	} else {
+		err = s.gateways.Do(func(g *Gateway) error {
+			return g.proxy(c.Writer, c.Request, bytes.NewReader(body), int64(len(body)))
+		})
+	}
+	if err != nil && !c.Writer.Written() {
+		c.JSON(http.StatusBadGateway, schema.RespErr{Err: err.Error()})
+	}
+	c.Abort()
+}
@@ -0,0 +1,194 @@
+package arseeding
+
+import (
+	"encoding/json"
+	"github.com/everFinance/arseeding/schema"
+	"github.com/everFinance/goar"
+	"github.com/everFinance/goar/types"
+	"github.com/everFinance/goar/utils"
+	"github.com/everFinance/goether"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// closeNotifyRecorder is needed by httputil.ReverseProxy
+type closeNotifyRecorder struct {
+	*httptest.ResponseRecorder
+}
+
+func (r closeNotifyRecorder) CloseNotify() <-chan bool {
+	return make(chan bool)
+}
+
+func TestGatewaysFailover(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer down.Close()
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/raw/notfound":
+			w.WriteHeader(http.StatusNotFound)
+		case "/graphql":
+			body, _ := io.ReadAll(r.Body)
+			w.Write(body)
+		default:
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte("up" + r.URL.Path))
+		}
+	}))
+	defer up.Close()
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	gateways, err := NewGateways([]schema.Gateway{{Url: closed.URL}, {Url: down.URL + "/", Timeout: 1}, {Url: up.URL}})
+	assert.NoError(t, err)
+
+	// fail over to the available gateway
+	data, contentType, err := getRawById(gateways, "abc")
+	assert.NoError(t, err)
+	assert.Equal(t, "up/raw/abc", string(data))
+	assert.Equal(t, "text/plain", contentType)
+	assert.Equal(t, up.URL, gateways.sorted()[0].Url)
+
+	// not found is not a gateway failure
+	_, _, err = getRawById(gateways, "notfound")
+	assert.ErrorIs(t, err, schema.ErrNotFound)
+	assert.True(t, gateways.sorted()[0].healthy)
+
+	// proxy request with body
+	s := &Arseeding{gateways: gateways}
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(closeNotifyRecorder{w})
+	c.Request = httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader("query"))
+	s.proxyArweaveGateway(c)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "query", w.Body.String())
+
+	// large body is streamed to the first gateway without failover
+	large := strings.Repeat("q", schema.GatewayProxyMaxBufferSize+1)
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(closeNotifyRecorder{w})
+	c.Request = httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(large))
+	s.proxyArweaveGateway(c)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, large, w.Body.String())
+
+	// health check recover gateways by latency
+	gateways.HealthCheck()
+	list := gateways.sorted()
+	assert.Equal(t, up.URL, list[0].Url)
+	assert.True(t, list[0].healthy)
+	assert.False(t, list[1].healthy)
+	assert.False(t, list[2].healthy)
+
+	// all gateways failed
+	gateways, err = NewGateways([]schema.Gateway{{Url: closed.URL}, {Url: down.URL}})
+	assert.NoError(t, err)
+	s = &Arseeding{gateways: gateways}
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(closeNotifyRecorder{w})
+	c.Request = httptest.NewRequest(http.MethodGet, "/info", nil)
+	s.proxyArweaveGateway(c)
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+
+	_, err = NewGateways([]schema.Gateway{{Url: "arweave.net"}})
+	assert.Error(t, err)
+}
+
+func TestGatewayGetBundleItems(t *testing.T) {
+	eccSigner, err := goether.NewSigner("4c3f9a1e5b234ce8f1ab58d82f849c0f70a4d5ceaf2b6e2d9a6c58b1f897ef0a")
+	assert.NoError(t, err)
+	itemSigner, err := goar.NewItemSigner(eccSigner)
+	assert.NoError(t, err)
+	bigData := make([]byte, 2*types.MAX_CHUNK_SIZE+100)
+	rand.Read(bigData)
+	items := make([]types.BundleItem, 0)
+	for _, data := range [][]byte{[]byte("item 1"), bigData, []byte("item 3")} {
+		item, err := itemSigner.CreateAndSignItem(data, "", "", nil)
+		assert.NoError(t, err)
+		items = append(items, item)
+	}
+	bundle, err := utils.NewBundle(items...)
+	assert.NoError(t, err)
+	chunks, err := utils.GenerateChunks(bundle.BundleBinary)
+	assert.NoError(t, err)
+
+	// bundle data is at weave offset 1000
+	startOffset := int64(1000)
+	gw := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/tx/bundle-id/offset" {
+			json.NewEncoder(w).Encode(types.TransactionOffset{
+				Size:   strconv.Itoa(len(bundle.BundleBinary)),
+				Offset: strconv.FormatInt(startOffset+int64(len(bundle.BundleBinary))-1, 10),
+			})
+			return
+		}
+		offset, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/chunk/"), 10, 64)
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		for _, c := range chunks.Chunks {
+			if pos := offset - startOffset; int64(c.MinByteRange) <= pos && pos < int64(c.MaxByteRange) {
+				json.NewEncoder(w).Encode(types.TransactionChunk{Chunk: utils.Base64Encode(bundle.BundleBinary[c.MinByteRange:c.MaxByteRange])})
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer gw.Close()
+
+	gateways, err := NewGateways([]schema.Gateway{{Url: gw.URL}})
+	assert.NoError(t, err)
+	res, err := gateways.sorted()[0].getBundleItems("bundle-id", []string{items[1].Id, items[2].Id})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(res))
+	assert.Equal(t, items[1].Id, res[0].Id)
+	assert.Equal(t, utils.Base64Encode(bigData), res[0].Data)
+	assert.Equal(t, items[2].Id, res[1].Id)
+}
+
+func TestGetBundleItemsFromGatewayNested(t *testing.T) {
+	eccSigner, err := goether.NewSigner("4c3f9a1e5b234ce8f1ab58d82f849c0f70a4d5ceaf2b6e2d9a6c58b1f897ef0a")
+	assert.NoError(t, err)
+	itemSigner, err := goar.NewItemSigner(eccSigner)
+	assert.NoError(t, err)
+	items := make([]types.BundleItem, 0)
+	for _, data := range []string{"item 1", "item 2"} {
+		item, err := itemSigner.CreateAndSignItem([]byte(data), "", "", nil)
+		assert.NoError(t, err)
+		items = append(items, item)
+	}
+	bundle, err := utils.NewBundle(items...)
+	assert.NoError(t, err)
+
+	// nested bundle is bundled in another bundle, and its data is loaded by /raw
+	gw := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/graphql":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"data":{"transaction":{"id":"nest-bundle","data":{"size":"` + strconv.Itoa(len(bundle.BundleBinary)) + `"},"bundledIn":{"id":"parent-bundle"}}}}`))
+		case "/raw/nest-bundle":
+			w.Write(bundle.BundleBinary)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer gw.Close()
+
+	gateways, err := NewGateways([]schema.Gateway{{Url: gw.URL}})
+	assert.NoError(t, err)
+	res, err := getBundleItemsFromGateway(gateways, "nest-bundle", []string{items[1].Id})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(res))
+	assert.Equal(t, items[1].Id, res[0].Id)
+	assert.Equal(t, utils.Base64Encode([]byte("item 2")), res[0].Data)
+}
@@ -22,6 +22,7 @@ require (
 	github.com/tidwall/gjson v1.17.3
 	github.com/ulule/limiter/v3 v3.10.0
 	github.com/urfave/cli/v2 v2.25.7
+	github.com/vektah/gqlparser/v2 v2.5.1
 	go.etcd.io/bbolt v1.3.6
 	gopkg.in/h2non/gentleman.v2 v2.0.5
 	gorm.io/datatypes v1.0.1
@@ -124,7 +125,6 @@ require (
 	github.com/tklauser/go-sysconf v0.3.12 // indirect
 	github.com/tklauser/numcpus v0.6.1 // indirect
 	github.com/ugorji/go/codec v1.2.7 // indirect
-	github.com/vektah/gqlparser/v2 v2.5.1 // indirect
 	github.com/x448/float16 v0.8.4 // indirect
 	github.com/xdg-go/pbkdf2 v1.0.0 // indirect
 	github.com/xdg-go/scram v1.1.2 // indirect
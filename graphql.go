@@ -0,0 +1,536 @@
+package arseeding
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/everFinance/arseeding/schema"
+	"github.com/everFinance/goar/types"
+	"github.com/everFinance/goar/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/parser"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+)
+
+// local graphql
+// `transaction` and `transactions` queries are served from the local tx index,
+// unsupported queries and queries that miss local data are proxied to arweave gateway.
+
+var errGqlUnsupported = errors.New("unsupported graphql query")
+
+func (s *Arseeding) graphql(c *gin.Context) {
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		errorResponse(c, err.Error())
+		return
+	}
+	// restore body for proxy
+	c.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	req := schema.GraphqlRequest{}
+	if err = json.Unmarshal(body, &req); err != nil {
+		errorResponse(c, err.Error())
+		return
+	}
+	data, err := s.resolveGraphql(req)
+	if err != nil {
+		if err != errGqlUnsupported && err != schema.ErrNotExist {
+			log.Error("s.resolveGraphql(req)", "err", err)
+		}
+		s.proxyArweaveGateway(c)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": data})
+}
+
+type gqlQuery struct {
+	doc  *ast.QueryDocument
+	vars map[string]interface{}
+}
+
+func (s *Arseeding) resolveGraphql(req schema.GraphqlRequest) (map[string]interface{}, error) {
+	doc, err := parser.ParseQuery(&ast.Source{Input: req.Query})
+	if err != nil {
+		return nil, errGqlUnsupported
+	}
+	op := doc.Operations.ForName(req.OperationName)
+	if op == nil || op.Operation != ast.Query {
+		return nil, errGqlUnsupported
+	}
+	q := &gqlQuery{doc: doc, vars: req.Variables}
+
+	result := make(map[string]interface{})
+	for _, sel := range op.SelectionSet {
+		field, ok := sel.(*ast.Field)
+		if !ok || len(field.Directives) > 0 {
+			return nil, errGqlUnsupported
+		}
+		var val interface{}
+		switch field.Name {
+		case "transaction":
+			val, err = s.gqlTransaction(q, field)
+		case "transactions":
+			val, err = s.gqlTransactions(q, field)
+		default:
+			err = errGqlUnsupported
+		}
+		if err != nil {
+			return nil, err
+		}
+		if val, ok = q.project(field.SelectionSet, val); !ok {
+			return nil, errGqlUnsupported
+		}
+		result[field.Alias] = val
+	}
+	return result, nil
+}
+
+func (s *Arseeding) gqlTransaction(q *gqlQuery, field *ast.Field) (interface{}, error) {
+	arg := field.Arguments.ForName("id")
+	if arg == nil || len(field.Arguments) != 1 {
+		return nil, errGqlUnsupported
+	}
+	val, err := arg.Value.Value(q.vars)
+	if err != nil {
+		return nil, errGqlUnsupported
+	}
+	id, ok := val.(string)
+	if !ok {
+		return nil, errGqlUnsupported
+	}
+	node, _, _, err := s.gqlNode(id)
+	return node, err
+}
+
+func (s *Arseeding) gqlTransactions(q *gqlQuery, field *ast.Field) (interface{}, error) {
+	filter, first, after, err := parseGqlTxArgs(q, field.Arguments)
+	if err != nil {
+		return nil, err
+	}
+
+	// choose the index to scan, other conditions are checked by filter
+	var scan func(before int64, fn func(pos int64, id string) bool) error
+	switch {
+	case len(filter.ids) > 0:
+		scan = gqlListScan(filter.ids)
+	case len(filter.bundledIn) == 1:
+		itemIds, err := s.store.LoadArIdToItemIds(filter.bundledIn[0])
+		if err != nil {
+			return nil, err
+		}
+		scan = gqlListScan(itemIds)
+	case len(filter.owners) == 1:
+		owner := filter.owners[0]
+		scan = func(before int64, fn func(pos int64, id string) bool) error {
+			return s.store.ScanIdsByOwner(owner, before, fn)
+		}
+	default:
+		for _, tf := range filter.tags {
+			if !tf.neq && len(tf.values) == 1 {
+				name, value := tf.name, tf.values[0]
+				scan = func(before int64, fn func(pos int64, id string) bool) error {
+					return s.store.ScanIdsByTag(name, value, before, fn)
+				}
+				break
+			}
+		}
+	}
+	if scan == nil {
+		return nil, errGqlUnsupported
+	}
+
+	edges := make([]interface{}, 0, first)
+	hasNextPage := false
+	missed := false
+	var nodeErr error
+	if after != 0 || !filter.hasAfter {
+		err = scan(after, func(pos int64, id string) bool {
+			node, record, tags, err := s.gqlNode(id)
+			if err != nil {
+				if err == schema.ErrNotExist {
+					missed = true
+					return true
+				}
+				nodeErr = err
+				return false
+			}
+			if !filter.match(record, tags) {
+				return true
+			}
+			if len(edges) == first {
+				hasNextPage = true
+				return false
+			}
+			edges = append(edges, map[string]interface{}{
+				"__typename": "TransactionEdge",
+				"cursor":     strconv.FormatInt(pos, 10),
+				"node":       node,
+			})
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+		if nodeErr != nil {
+			return nil, nodeErr
+		}
+	}
+	// the first page that local index can not answer completely is a miss
+	if !filter.hasAfter && (len(edges) == 0 || (len(filter.ids) > 0 && missed)) {
+		return nil, schema.ErrNotExist
+	}
+
+	return map[string]interface{}{
+		"__typename": "TransactionConnection",
+		"pageInfo": map[string]interface{}{
+			"__typename":  "PageInfo",
+			"hasNextPage": hasNextPage,
+		},
+		"edges": edges,
+	}, nil
+}
+
+// gqlNode build the graphql Transaction object of tx or bundle item
+func (s *Arseeding) gqlNode(id string) (node map[string]interface{}, record schema.IndexRecord, tags []types.Tag, err error) {
+	record, err = s.store.LoadIndexRecord(id)
+	if err != nil {
+		return
+	}
+	var anchor, signature, ownerKey, fee, quantity string
+	if record.IsItem {
+		item, err := s.store.LoadItemMeta(id)
+		if err != nil {
+			return nil, record, nil, err
+		}
+		anchor, signature, ownerKey, tags = item.Anchor, item.Signature, item.Owner, item.Tags
+	} else {
+		arTx, err := s.store.LoadTxMeta(id)
+		if err != nil {
+			return nil, record, nil, err
+		}
+		tags, err = utils.TagsDecode(arTx.Tags)
+		if err != nil {
+			return nil, record, nil, err
+		}
+		anchor, signature, ownerKey, fee, quantity = arTx.LastTx, arTx.Signature, arTx.Owner, arTx.Reward, arTx.Quantity
+	}
+
+	gqlTags := make([]interface{}, 0, len(tags))
+	for _, tg := range tags {
+		gqlTags = append(gqlTags, map[string]interface{}{
+			"__typename": "Tag",
+			"name":       tg.Name,
+			"value":      tg.Value,
+		})
+	}
+	var contentType, bundledIn, parent interface{}
+	if record.ContentType != "" {
+		contentType = record.ContentType
+	}
+	if record.BundledIn != "" {
+		bundledIn = map[string]interface{}{"__typename": "Bundle", "id": record.BundledIn}
+		parent = map[string]interface{}{"__typename": "Parent", "id": record.BundledIn}
+	}
+	node = map[string]interface{}{
+		"__typename": "Transaction",
+		"id":         record.Id,
+		"anchor":     anchor,
+		"signature":  signature,
+		"recipient":  record.Target,
+		"owner": map[string]interface{}{
+			"__typename": "Owner",
+			"address":    record.Owner,
+			"key":        ownerKey,
+		},
+		"fee":      gqlAmount(fee),
+		"quantity": gqlAmount(quantity),
+		"data": map[string]interface{}{
+			"__typename": "MetaData",
+			"size":       strconv.FormatInt(record.DataSize, 10),
+			"type":       contentType,
+		},
+		"tags":      gqlTags,
+		"block":     nil, // block info is not indexed
+		"bundledIn": bundledIn,
+		"parent":    parent,
+	}
+	return
+}
+
+func gqlAmount(winston string) map[string]interface{} {
+	if winston == "" {
+		winston = "0"
+	}
+	ar := "0"
+	if w, err := decimal.NewFromString(winston); err == nil {
+		ar = w.Shift(-12).StringFixed(12)
+	}
+	return map[string]interface{}{
+		"__typename": "Amount",
+		"winston":    winston,
+		"ar":         ar,
+	}
+}
+
+// gqlListScan scan a fixed id list in the given order, position of ids[i] is len(ids)-1-i
+func gqlListScan(ids []string) func(before int64, fn func(pos int64, id string) bool) error {
+	return func(before int64, fn func(pos int64, id string) bool) error {
+		for i, id := range ids {
+			pos := int64(len(ids) - 1 - i)
+			if before > 0 && pos >= before {
+				continue
+			}
+			if !fn(pos, id) {
+				return nil
+			}
+		}
+		return nil
+	}
+}
+
+type gqlTagFilter struct {
+	name   string
+	values []string
+	neq    bool
+}
+
+type gqlTxFilter struct {
+	ids        []string
+	owners     []string
+	recipients []string
+	bundledIn  []string
+	tags       []gqlTagFilter
+	hasAfter   bool
+}
+
+func parseGqlTxArgs(q *gqlQuery, args ast.ArgumentList) (filter gqlTxFilter, first int, after int64, err error) {
+	first = schema.GraphqlDefaultFirst
+	for _, arg := range args {
+		val, err := arg.Value.Value(q.vars)
+		if err != nil {
+			return filter, 0, 0, errGqlUnsupported
+		}
+		if val == nil {
+			continue
+		}
+		ok := true
+		switch arg.Name {
+		case "ids":
+			filter.ids, ok = gqlStringList(val)
+		case "owners":
+			filter.owners, ok = gqlStringList(val)
+			for i, owner := range filter.owners {
+				if len(owner) > 43 { // owner public key
+					if filter.owners[i], err = Base64Address(owner); err != nil {
+						return filter, 0, 0, errGqlUnsupported
+					}
+				}
+			}
+		case "recipients":
+			filter.recipients, ok = gqlStringList(val)
+		case "bundledIn":
+			filter.bundledIn, ok = gqlStringList(val)
+		case "tags":
+			filter.tags, ok = gqlTagFilters(val)
+		case "first":
+			switch v := val.(type) {
+			case int64:
+				first = int(v)
+			case float64: // from json variables
+				first = int(v)
+			default:
+				ok = false
+			}
+		case "after":
+			var cursor string
+			if cursor, ok = val.(string); ok {
+				// cursor of arweave gateway can not be parsed
+				after, err = strconv.ParseInt(cursor, 10, 64)
+				ok = err == nil && after >= 0
+				filter.hasAfter = true
+			}
+		case "sort":
+			ok = val == "HEIGHT_DESC"
+		default: // block filter
+			ok = false
+		}
+		if !ok {
+			return filter, 0, 0, errGqlUnsupported
+		}
+	}
+	if first <= 0 || first > schema.GraphqlMaxFirst {
+		first = schema.GraphqlMaxFirst
+	}
+	return
+}
+
+func gqlStringList(val interface{}) ([]string, bool) {
+	list, ok := val.([]interface{})
+	if !ok {
+		return nil, false
+	}
+	res := make([]string, 0, len(list))
+	for _, v := range list {
+		str, ok := v.(string)
+		if !ok {
+			return nil, false
+		}
+		res = append(res, str)
+	}
+	return res, true
+}
+
+func gqlTagFilters(val interface{}) ([]gqlTagFilter, bool) {
+	list, ok := val.([]interface{})
+	if !ok {
+		return nil, false
+	}
+	res := make([]gqlTagFilter, 0, len(list))
+	for _, v := range list {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		tf := gqlTagFilter{}
+		if tf.name, ok = obj["name"].(string); !ok {
+			return nil, false
+		}
+		if tf.values, ok = gqlStringList(obj["values"]); !ok {
+			return nil, false
+		}
+		if op, exist := obj["op"]; exist && op != nil {
+			switch op {
+			case "EQ":
+			case "NEQ":
+				tf.neq = true
+			default:
+				return nil, false
+			}
+		}
+		res = append(res, tf)
+	}
+	return res, true
+}
+
+func (f gqlTxFilter) match(record schema.IndexRecord, tags []types.Tag) bool {
+	if len(f.ids) > 0 && !gqlContains(f.ids, record.Id) {
+		return false
+	}
+	if len(f.owners) > 0 && !gqlContains(f.owners, record.Owner) {
+		return false
+	}
+	if len(f.recipients) > 0 && !gqlContains(f.recipients, record.Target) {
+		return false
+	}
+	if len(f.bundledIn) > 0 && !gqlContains(f.bundledIn, record.BundledIn) {
+		return false
+	}
+	for _, tf := range f.tags {
+		matched := false
+		for _, tg := range tags {
+			if tg.Name == tf.name && gqlContains(tf.values, tg.Value) != tf.neq {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+func gqlContains(list []string, str string) bool {
+	for _, s := range list {
+		if s == str {
+			return true
+		}
+	}
+	return false
+}
+
+// project pick the selected fields from resolved value, return false if selection can not be satisfied
+func (q *gqlQuery) project(set ast.SelectionSet, val interface{}) (interface{}, bool) {
+	switch v := val.(type) {
+	case nil:
+		return nil, true
+	case map[string]interface{}:
+		if len(set) == 0 {
+			return nil, false
+		}
+		out := make(map[string]interface{})
+		if !q.projectFields(set, v, out) {
+			return nil, false
+		}
+		return out, true
+	case []interface{}:
+		list := make([]interface{}, 0, len(v))
+		for _, elem := range v {
+			e, ok := q.project(set, elem)
+			if !ok {
+				return nil, false
+			}
+			list = append(list, e)
+		}
+		return list, true
+	default:
+		return v, len(set) == 0
+	}
+}
+
+func (q *gqlQuery) projectFields(set ast.SelectionSet, obj map[string]interface{}, out map[string]interface{}) bool {
+	for _, sel := range set {
+		switch f := sel.(type) {
+		case *ast.Field:
+			if len(f.Directives) > 0 {
+				return false
+			}
+			val, exist := obj[f.Name]
+			if !exist {
+				return false
+			}
+			pv, ok := q.project(f.SelectionSet, val)
+			if !ok {
+				return false
+			}
+			// same field selected in fragments
+			if pre, ok := out[f.Alias].(map[string]interface{}); ok {
+				if cur, ok := pv.(map[string]interface{}); ok {
+					for k, v := range cur {
+						pre[k] = v
+					}
+					continue
+				}
+			}
+			out[f.Alias] = pv
+		case *ast.InlineFragment:
+			if len(f.Directives) > 0 {
+				return false
+			}
+			if f.TypeCondition != "" && f.TypeCondition != obj["__typename"] {
+				continue
+			}
+			if !q.projectFields(f.SelectionSet, obj, out) {
+				return false
+			}
+		case *ast.FragmentSpread:
+			def := q.doc.Fragments.ForName(f.Name)
+			if def == nil || len(f.Directives) > 0 {
+				return false
+			}
+			if def.TypeCondition != obj["__typename"] {
+				continue
+			}
+			if !q.projectFields(def.SelectionSet, obj, out) {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
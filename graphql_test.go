@@ -0,0 +1,88 @@
+package arseeding
+
+import (
+	"encoding/json"
+	"github.com/everFinance/arseeding/schema"
+	"github.com/everFinance/goar"
+	"github.com/everFinance/goar/types"
+	"github.com/everFinance/goether"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+)
+
+func TestLocalGraphql(t *testing.T) {
+	dbPath := "./data/tmp.db"
+	eccSigner, err := goether.NewSigner("4c3f9a1e5b234ce8f1ab58d82f849c0f70a4d5ceaf2b6e2d9a6c58b1f897ef0a")
+	assert.NoError(t, err)
+	itemSigner, err := goar.NewItemSigner(eccSigner)
+	assert.NoError(t, err)
+	s, err := NewBoltStore(dbPath)
+	assert.NoError(t, err)
+	defer os.RemoveAll(dbPath)
+	aa := &Arseeding{store: s}
+
+	itemIds := make([]string, 0)
+	for i, appName := range []string{"app-1", "app-2", "app-1"} {
+		item, err := itemSigner.CreateAndSignItem([]byte{byte(i)}, "", "", []types.Tag{
+			{Name: "App-Name", Value: appName},
+			{Name: "Content-Type", Value: "text/plain"},
+		})
+		assert.NoError(t, err)
+		assert.NoError(t, aa.saveItem(item))
+		itemIds = append(itemIds, item.Id)
+	}
+	err = s.SaveArIdToItemIds("bundle-arId", itemIds[:1])
+	assert.NoError(t, err)
+
+	// tag query, newest first
+	data, err := aa.resolveGraphql(schema.GraphqlRequest{
+		Query: `query($first: Int) { txs: transactions(tags: [{name: "App-Name", values: ["app-1"]}], first: $first) {
+			pageInfo { hasNextPage }
+			edges { cursor node { id data { size type } ...bundle } }
+		} }
+		fragment bundle on Transaction { bundledIn { id } }`,
+		Variables: map[string]interface{}{"first": float64(1)},
+	})
+	assert.NoError(t, err)
+	js, _ := json.Marshal(data)
+	res := struct {
+		Txs struct {
+			PageInfo struct{ HasNextPage bool }
+			Edges    []struct {
+				Cursor string
+				Node   struct {
+					Id        string
+					Data      struct{ Size, Type string }
+					BundledIn *struct{ Id string }
+				}
+			}
+		}
+	}{}
+	assert.NoError(t, json.Unmarshal(js, &res))
+	assert.Equal(t, true, res.Txs.PageInfo.HasNextPage)
+	assert.Equal(t, 1, len(res.Txs.Edges))
+	assert.Equal(t, itemIds[2], res.Txs.Edges[0].Node.Id)
+	assert.Equal(t, "1", res.Txs.Edges[0].Node.Data.Size)
+	assert.Equal(t, "text/plain", res.Txs.Edges[0].Node.Data.Type)
+	assert.Nil(t, res.Txs.Edges[0].Node.BundledIn)
+
+	// next page
+	data, err = aa.resolveGraphql(schema.GraphqlRequest{
+		Query:     `query($after: String) { txs: transactions(tags: [{name: "App-Name", values: ["app-1"]}], after: $after) { pageInfo { hasNextPage } edges { cursor node { id bundledIn { id } } } } }`,
+		Variables: map[string]interface{}{"after": res.Txs.Edges[0].Cursor},
+	})
+	assert.NoError(t, err)
+	js, _ = json.Marshal(data)
+	assert.NoError(t, json.Unmarshal(js, &res))
+	assert.Equal(t, false, res.Txs.PageInfo.HasNextPage)
+	assert.Equal(t, 1, len(res.Txs.Edges))
+	assert.Equal(t, itemIds[0], res.Txs.Edges[0].Node.Id)
+	assert.Equal(t, "bundle-arId", res.Txs.Edges[0].Node.BundledIn.Id)
+
+	// miss and unsupported queries are proxied
+	_, err = aa.resolveGraphql(schema.GraphqlRequest{Query: `{ transaction(id: "not-exist") { id } }`})
+	assert.Equal(t, schema.ErrNotExist, err)
+	_, err = aa.resolveGraphql(schema.GraphqlRequest{Query: `{ transactions(block: {min: 1}, tags: [{name: "App-Name", values: ["app-1"]}]) { edges { node { id } } } }`})
+	assert.Equal(t, errGqlUnsupported, err)
+}
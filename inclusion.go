@@ -0,0 +1,177 @@
+package arseeding
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/everFinance/arseeding/argraphql"
+	"github.com/everFinance/arseeding/schema"
+	"github.com/everFinance/goar/utils"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+// markItemsLost set the orders of items to lost, key: itemId, value: lost reason
+func (s *Arseeding) markItemsLost(lost map[string]string) {
+	for itemId, reason := range lost {
+		if err := s.wdb.UpdateOrdToLost(itemId, reason); err != nil {
+			log.Error("s.wdb.UpdateOrdToLost(itemId,reason)", "err", err, "itemId", itemId, "reason", reason)
+			continue
+		}
+		log.Error("bundle item is lost, requeue by admin api", "itemId", itemId, "reason", reason)
+		metricItemLost(reason)
+	}
+}
+
+// verifyBundleInclusion check the items of succeeded bundle arTxs are really in the posted bundle
+func (s *Arseeding) verifyBundleInclusion() {
+	txs, err := s.wdb.GetArTxByInclusion(schema.InclusionUnchecked)
+	if err != nil {
+		log.Error("s.wdb.GetArTxByInclusion(schema.InclusionUnchecked)", "err", err)
+		return
+	}
+	for _, tx := range txs {
+		if err = s.checkArTxInclusion(tx); err != nil {
+			log.Error("s.checkArTxInclusion(tx)", "err", err, "arId", tx.ArId)
+		}
+	}
+}
+
+// checkArTxInclusion decode the header of bundle data in local store, or query bundledIn of items from gateway graphql if data is not stored.
+// items not indexed by gateway are checked again until InclusionIndexBlocks after the arTx is mined
+func (s *Arseeding) checkArTxInclusion(tx schema.OnChainTx) error {
+	itemIds := make([]string, 0)
+	if err := json.Unmarshal(tx.ItemIds, &itemIds); err != nil {
+		return err
+	}
+
+	lost := make(map[string]string)
+	bundled, err := s.bundleItemIdsOfArTx(tx.ArId)
+	if err == nil {
+		for _, itemId := range itemIds {
+			if _, ok := bundled[itemId]; !ok {
+				lost[itemId] = schema.LostReasonNotInBundle
+			}
+		}
+	} else {
+		log.Debug("bundle data is not in local store, check inclusion by graphql", "arId", tx.ArId, "err", err)
+		missing, err := s.itemsNotBundledIn(tx.ArId, itemIds)
+		if err != nil {
+			return err
+		}
+		if len(missing) > 0 {
+			if s.cache.GetInfo().Height-tx.BlockHeight <= schema.InclusionIndexBlocks {
+				return nil // wait for gateway indexing
+			}
+			for _, itemId := range missing {
+				lost[itemId] = schema.LostReasonNotIndexed
+			}
+		}
+	}
+	s.markItemsLost(lost)
+	return s.wdb.UpdateArTxInclusion(tx.ArId, schema.InclusionChecked, nil)
+}
+
+// bundleItemIdsOfArTx decode item ids from the bundle header of arTx data in local store
+func (s *Arseeding) bundleItemIdsOfArTx(arId string) (map[string]struct{}, error) {
+	arTx, err := s.store.LoadTxMeta(arId)
+	if err != nil {
+		return nil, err
+	}
+	errInvalid := errors.New("invalid bundle binary")
+	data, err := getArTxDataPrefix(arTx.DataRoot, arTx.DataSize, s.store, 32)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 32 {
+		return nil, errInvalid
+	}
+	count, ok := bundleLong(data[:32])
+	if !ok || count > schema.MaxPerOnChainSize/64 {
+		return nil, errInvalid
+	}
+	headerEnd := 32 + 64*count
+	if int64(len(data)) < headerEnd {
+		if data, err = getArTxDataPrefix(arTx.DataRoot, arTx.DataSize, s.store, uint64(headerEnd)); err != nil {
+			return nil, err
+		}
+		if int64(len(data)) < headerEnd {
+			return nil, errInvalid
+		}
+	}
+
+	ids := make(map[string]struct{}, count)
+	for i := int64(0); i < count; i++ {
+		ids[utils.Base64Encode(data[32+i*64+32:32+(i+1)*64])] = struct{}{}
+	}
+	return ids, nil
+}
+
+// itemsNotBundledIn return the items whose bundledIn is not arId in gateway graphql
+func (s *Arseeding) itemsNotBundledIn(arId string, itemIds []string) ([]string, error) {
+	bundled := make(map[string]struct{}, len(itemIds))
+	// 90 items per query
+	for i := 0; i < len(itemIds); i += 90 {
+		end := i + 90
+		if end > len(itemIds) {
+			end = len(itemIds)
+		}
+		var resp *argraphql.BatchGetItemsBundleInResponse
+		err := s.gateways.Do(func(g *Gateway) (err error) {
+			resp, err = g.GraphQL().BatchGetItemsBundleIn(context.Background(), itemIds[i:end], 90, "")
+			return
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, edge := range resp.Transactions.Edges {
+			if edge.Node.BundledIn.Id == arId {
+				bundled[edge.Node.Id] = struct{}{}
+			}
+		}
+	}
+
+	missing := make([]string, 0)
+	for _, itemId := range itemIds {
+		if _, ok := bundled[itemId]; !ok {
+			missing = append(missing, itemId)
+		}
+	}
+	return missing, nil
+}
+
+func (s *Arseeding) getLostItems(c *gin.Context) {
+	cursorId, err := strconv.ParseInt(c.DefaultQuery("cursorId", "0"), 10, 64)
+	if err != nil {
+		errorResponse(c, err.Error())
+		return
+	}
+	num, err := strconv.ParseInt(c.DefaultQuery("num", "20"), 10, 64)
+	if err != nil {
+		errorResponse(c, err.Error())
+		return
+	}
+	orders, err := s.wdb.GetLostOrders(cursorId, int(num))
+	if err != nil {
+		internalErrorResponse(c, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, orders)
+}
+
+// requeueItems body: {"itemIds": [...]}, all lost items are requeued if itemIds is empty
+func (s *Arseeding) requeueItems(c *gin.Context) {
+	req := schema.ReqRequeue{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errorResponse(c, err.Error())
+		return
+	}
+	num, err := s.wdb.RequeueLostOrders(req.ItemIds)
+	if err != nil {
+		internalErrorResponse(c, err.Error())
+		return
+	}
+	log.Info("requeue lost items", "num", num, "itemIds", req.ItemIds)
+	c.JSON(http.StatusOK, schema.RespRequeue{Requeued: num})
+}
@@ -0,0 +1,105 @@
+package arseeding
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/everFinance/arseeding/schema"
+	"github.com/everFinance/goar"
+	"github.com/everFinance/goar/types"
+	"github.com/everFinance/goar/utils"
+	"github.com/everFinance/goether"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestBundleInclusion(t *testing.T) {
+	store, err := NewBoltStore("./data/tmp.db")
+	assert.NoError(t, err)
+	defer os.RemoveAll("./data/tmp.db")
+	wdb := NewSqliteDb("./data/sqlite")
+	defer os.RemoveAll("./data/sqlite")
+	assert.NoError(t, wdb.Migrate(false, true))
+	s := &Arseeding{store: store, wdb: wdb}
+
+	eccSigner, err := goether.NewSigner("4c3f9a1e5b234ce8f1ab58d82f849c0f70a4d5ceaf2b6e2d9a6c58b1f897ef0a")
+	assert.NoError(t, err)
+	itemSigner, err := goar.NewItemSigner(eccSigner)
+	assert.NoError(t, err)
+	bigData := make([]byte, 2*types.MAX_CHUNK_SIZE+100)
+	rand.Read(bigData)
+	items := make([]types.BundleItem, 0)
+	itemIds := make([]string, 0)
+	for _, data := range [][]byte{bigData, []byte("item 2"), []byte("item 3")} {
+		item, err := itemSigner.CreateAndSignItem(data, "", "", nil)
+		assert.NoError(t, err)
+		items = append(items, item)
+		itemIds = append(itemIds, item.Id)
+		assert.NoError(t, wdb.InsertOrder(schema.Order{ItemId: item.Id, PaymentStatus: schema.SuccPayment, OnChainStatus: schema.SuccOnChain}))
+	}
+
+	// the third item is skipped when the bundle is posted
+	bundle, err := utils.NewBundle(items[:2]...)
+	assert.NoError(t, err)
+	chunks, err := utils.GenerateChunks(bundle.BundleBinary)
+	assert.NoError(t, err)
+	arTx := types.Transaction{
+		ID:       "bundle-arId",
+		DataRoot: utils.Base64Encode(chunks.DataRoot),
+		DataSize: fmt.Sprintf("%d", len(bundle.BundleBinary)),
+	}
+	assert.NoError(t, store.SaveTxMeta(arTx))
+	assert.NoError(t, s.syncAddTxDataEndOffset(arTx.DataRoot, arTx.DataSize))
+	assert.NoError(t, setTxDataChunks(arTx, bundle.BundleBinary, store))
+
+	bundled, err := s.bundleItemIdsOfArTx(arTx.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(bundled))
+	assert.Contains(t, bundled, itemIds[0])
+	assert.Contains(t, bundled, itemIds[1])
+
+	itemIdsJs, err := json.Marshal(itemIds)
+	assert.NoError(t, err)
+	assert.NoError(t, wdb.InsertArTx(schema.OnChainTx{ArId: arTx.ID, Status: schema.SuccOnChain, ItemIds: itemIdsJs, Inclusion: schema.InclusionUnchecked}))
+	s.verifyBundleInclusion()
+	txs, err := wdb.GetArTxByInclusion(schema.InclusionUnchecked)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(txs))
+	txs, err = wdb.GetArTxByInclusion(schema.InclusionChecked)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(txs))
+
+	// admin api
+	serve := func(method, target string, body []byte, handler gin.HandlerFunc) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(method, target, bytes.NewReader(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+		handler(c)
+		return w
+	}
+	w := serve(http.MethodGet, "/admin/bundle/lost", nil, s.getLostItems)
+	assert.Equal(t, http.StatusOK, w.Code)
+	lost := make([]schema.Order, 0)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &lost))
+	assert.Equal(t, 1, len(lost))
+	assert.Equal(t, itemIds[2], lost[0].ItemId)
+	assert.Equal(t, schema.LostOnChain, lost[0].OnChainStatus)
+	assert.Equal(t, schema.LostReasonNotInBundle, lost[0].LostReason)
+
+	w = serve(http.MethodPost, "/admin/bundle/requeue", []byte(`{"itemIds":["`+itemIds[1]+`","`+itemIds[2]+`"]}`), s.requeueItems)
+	assert.Equal(t, http.StatusOK, w.Code)
+	res := schema.RespRequeue{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	assert.Equal(t, int64(1), res.Requeued)
+	ords, err := wdb.GetNeedOnChainOrders()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(ords))
+	assert.Equal(t, itemIds[2], ords[0].ItemId)
+	assert.Equal(t, "", ords[0].LostReason)
+}
@@ -0,0 +1,421 @@
+package arseeding
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/everFinance/arseeding/schema"
+	"github.com/everFinance/goar/types"
+	"github.com/everFinance/goar/utils"
+	"hash/fnv"
+	"io"
+	"strconv"
+	"sync"
+)
+
+// local tx index
+// every index key is a paged list of ids, ids are appended in saved order and scanned from the newest one.
+// position of an id in the list is stable, so it can be used as pagination cursor.
+// index record is locked by id and index list by its key, a record lock may be held when a list is locked but not vice versa.
+
+// keyLocks lock by key, keys hashed to the same slot share the mutex
+type keyLocks [256]sync.Mutex
+
+func (l *keyLocks) lock(key string) (unlock func()) {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	m := &l[h.Sum32()%uint32(len(l))]
+	m.Lock()
+	return m.Unlock
+}
+
+func (s *Store) SaveItemIndex(item types.BundleItem) error {
+	owner, err := Base64Address(item.Owner)
+	if err != nil {
+		return err
+	}
+	dataSize := int64(0)
+	hash := sha256.New()
+	if item.DataReader != nil {
+		fileInfo, err := item.DataReader.Stat()
+		if err != nil {
+			return err
+		}
+		dataSize = fileInfo.Size()
+		// read by offset, so the file position of DataReader is not changed
+		if _, err = io.Copy(hash, io.NewSectionReader(item.DataReader, 0, dataSize)); err != nil {
+			return err
+		}
+	} else {
+		data, err := utils.Base64Decode(item.Data)
+		if err != nil {
+			return err
+		}
+		dataSize = int64(len(data))
+		hash.Write(data)
+	}
+	record := schema.IndexRecord{
+		Id:          item.Id,
+		Owner:       owner,
+		Target:      item.Target,
+		DataSize:    dataSize,
+		ContentType: getTagValue(item.Tags, schema.ContentType),
+		DataSha256:  hex.EncodeToString(hash.Sum(nil)),
+		IsItem:      true,
+	}
+	return s.saveIndex(record, item.Tags)
+}
+
+func (s *Store) SaveTxIndex(arTx types.Transaction) error {
+	owner, err := Base64Address(arTx.Owner)
+	if err != nil {
+		return err
+	}
+	dataSize, err := strconv.ParseInt(arTx.DataSize, 10, 64)
+	if err != nil {
+		return err
+	}
+	tags, err := utils.TagsDecode(arTx.Tags)
+	if err != nil {
+		return err
+	}
+	record := schema.IndexRecord{
+		Id:          arTx.ID,
+		Owner:       owner,
+		Target:      arTx.Target,
+		DataSize:    dataSize,
+		ContentType: getTagValue(tags, schema.ContentType),
+	}
+	// data of tx is usually synced by chunks later
+	if arTx.Data != "" {
+		data, err := utils.Base64Decode(arTx.Data)
+		if err != nil {
+			return err
+		}
+		hash := sha256.Sum256(data)
+		record.DataSha256 = hex.EncodeToString(hash[:])
+	}
+	return s.saveIndex(record, tags)
+}
+
+func (s *Store) saveIndex(record schema.IndexRecord, tags []types.Tag) error {
+	defer s.recordLocks.lock(record.Id)()
+
+	if s.KVDb.Exist(schema.TxIndexRecordBucket, record.Id) {
+		return nil
+	}
+	if err := s.putIndexRecord(record); err != nil {
+		return err
+	}
+	keys := []schema.IndexPosition{{Bucket: schema.TxIndexOwnerBucket, Key: record.Owner}}
+	if record.Target != "" {
+		keys = append(keys, schema.IndexPosition{Bucket: schema.TxIndexTargetBucket, Key: record.Target})
+	}
+	for _, tg := range tags {
+		keys = append(keys, schema.IndexPosition{Bucket: schema.TxIndexTagBucket, Key: tagIndexKey(tg.Name, tg.Value)})
+	}
+	for _, k := range keys {
+		pos, err := s.appendIndexId(k.Bucket, k.Key, record.Id)
+		if err != nil {
+			return err
+		}
+		k.Pos = pos
+		record.Positions = append(record.Positions, k)
+	}
+	return s.putIndexRecord(record)
+}
+
+// DelItemIndex remove the item from all indexes by the positions in its index record,
+// records saved without positions are removed by the tags of item meta
+func (s *Store) DelItemIndex(itemId string) error {
+	defer s.recordLocks.lock(itemId)()
+
+	record, err := s.LoadIndexRecord(itemId)
+	if err != nil {
+		if err == schema.ErrNotExist {
+			return nil
+		}
+		return err
+	}
+	if len(record.Positions) > 0 {
+		for _, p := range record.Positions {
+			if err = s.removeIndexIdAt(p.Bucket, p.Key, itemId, p.Pos); err != nil {
+				return err
+			}
+		}
+		return s.KVDb.Delete(schema.TxIndexRecordBucket, itemId)
+	}
+
+	meta, err := s.LoadItemMeta(itemId)
+	if err != nil {
+		return err
+	}
+	if err = s.removeIndexId(schema.TxIndexOwnerBucket, record.Owner, itemId); err != nil {
+		return err
+	}
+	if record.Target != "" {
+		if err = s.removeIndexId(schema.TxIndexTargetBucket, record.Target, itemId); err != nil {
+			return err
+		}
+	}
+	for _, tg := range meta.Tags {
+		if err = s.removeIndexId(schema.TxIndexTagBucket, tagIndexKey(tg.Name, tg.Value), itemId); err != nil {
+			return err
+		}
+	}
+	return s.KVDb.Delete(schema.TxIndexRecordBucket, itemId)
+}
+
+func (s *Store) LoadIndexRecord(id string) (record schema.IndexRecord, err error) {
+	data, err := s.KVDb.Get(schema.TxIndexRecordBucket, id)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(data, &record)
+	return
+}
+
+func (s *Store) putIndexRecord(record schema.IndexRecord) error {
+	val, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return s.KVDb.Put(schema.TxIndexRecordBucket, record.Id, val)
+}
+
+// updateIndexRecord load, update and save the index record of id under its lock
+func (s *Store) updateIndexRecord(id string, update func(record *schema.IndexRecord)) error {
+	defer s.recordLocks.lock(id)()
+	record, err := s.LoadIndexRecord(id)
+	if err != nil {
+		return err
+	}
+	update(&record)
+	return s.putIndexRecord(record)
+}
+
+func (s *Store) SaveIndexDataSha256(id string, data []byte) error {
+	hash := sha256.Sum256(data)
+	return s.updateIndexRecord(id, func(record *schema.IndexRecord) {
+		record.DataSha256 = hex.EncodeToString(hash[:])
+	})
+}
+
+// SaveItemsBundledIn record the bundle arId which items are bundled in
+func (s *Store) SaveItemsBundledIn(arId string, itemIds []string) error {
+	for _, itemId := range itemIds {
+		err := s.updateIndexRecord(itemId, func(record *schema.IndexRecord) {
+			record.BundledIn = arId
+		})
+		if err != nil && err != schema.ErrNotExist {
+			return err
+		}
+	}
+	return nil
+}
+
+// ScanIdsByOwner scan ids from position before-1 to the oldest, before <= 0 means from the newest
+func (s *Store) ScanIdsByOwner(owner string, before int64, fn func(pos int64, id string) bool) error {
+	return s.scanIndex(schema.TxIndexOwnerBucket, owner, before, fn)
+}
+
+func (s *Store) ScanIdsByTag(name, value string, before int64, fn func(pos int64, id string) bool) error {
+	return s.scanIndex(schema.TxIndexTagBucket, tagIndexKey(name, value), before, fn)
+}
+
+func (s *Store) ScanIdsByTarget(target string, before int64, fn func(pos int64, id string) bool) error {
+	return s.scanIndex(schema.TxIndexTargetBucket, target, before, fn)
+}
+
+// QueryIndexedItems return bundle item metas that match all conditions of filter, from position cursor-1 to the oldest.
+// nextCursor is 0 if there are no more items
+func (s *Store) QueryIndexedItems(filter schema.ItemIndexFilter, cursor int64, num int) (items []types.BundleItem, nextCursor int64, err error) {
+	items = make([]types.BundleItem, 0, num)
+	var scan func(before int64, fn func(pos int64, id string) bool) error
+	switch {
+	case filter.Owner != "":
+		scan = func(before int64, fn func(pos int64, id string) bool) error {
+			return s.ScanIdsByOwner(filter.Owner, before, fn)
+		}
+	case filter.Target != "":
+		scan = func(before int64, fn func(pos int64, id string) bool) error {
+			return s.ScanIdsByTarget(filter.Target, before, fn)
+		}
+	case len(filter.Tags) > 0:
+		scan = func(before int64, fn func(pos int64, id string) bool) error {
+			return s.ScanIdsByTag(filter.Tags[0].Name, filter.Tags[0].Value, before, fn)
+		}
+	default:
+		return nil, 0, schema.ErrNilIndexFilter
+	}
+
+	var loadErr error
+	err = scan(cursor, func(pos int64, id string) bool {
+		record, err := s.LoadIndexRecord(id)
+		if err != nil {
+			loadErr = err
+			return false
+		}
+		if !record.IsItem ||
+			(filter.Owner != "" && record.Owner != filter.Owner) ||
+			(filter.Target != "" && record.Target != filter.Target) {
+			return true
+		}
+		meta, err := s.LoadItemMeta(id)
+		if err != nil {
+			loadErr = err
+			return false
+		}
+		for _, tg := range filter.Tags {
+			if getTagValue(meta.Tags, tg.Name) != tg.Value {
+				return true
+			}
+		}
+		if len(items) == num {
+			nextCursor = pos + 1
+			return false
+		}
+		items = append(items, meta)
+		return true
+	})
+	if err == nil {
+		err = loadErr
+	}
+	return
+}
+
+// appendIndexId return the position of id in the index list
+func (s *Store) appendIndexId(bucket, key, id string) (int64, error) {
+	defer s.listLocks.lock(bucket + "/" + key)()
+	pageNum, err := s.loadIndexPageNum(bucket, key)
+	if err != nil {
+		return 0, err
+	}
+	ids := make([]string, 0)
+	if pageNum > 0 {
+		ids, err = s.loadIndexPage(bucket, key, pageNum-1)
+		if err != nil {
+			return 0, err
+		}
+	}
+	if pageNum == 0 || len(ids) >= schema.IndexPageSize {
+		ids = make([]string, 0, 1)
+		pageNum++
+		if err = s.KVDb.Put(bucket, key, []byte(strconv.FormatInt(pageNum, 10))); err != nil {
+			return 0, err
+		}
+	}
+	ids = append(ids, id)
+	pos := (pageNum-1)*schema.IndexPageSize + int64(len(ids)-1)
+	return pos, s.putIndexPage(bucket, key, pageNum-1, ids)
+}
+
+// removeIndexIdAt blank out the id at pos, scan the list if the id is not there
+func (s *Store) removeIndexIdAt(bucket, key, id string, pos int64) error {
+	unlock := s.listLocks.lock(bucket + "/" + key)
+	page := pos / schema.IndexPageSize
+	ids, err := s.loadIndexPage(bucket, key, page)
+	if err != nil && err != schema.ErrNotExist {
+		unlock()
+		return err
+	}
+	if i := pos % schema.IndexPageSize; i < int64(len(ids)) && ids[i] == id {
+		ids[i] = ""
+		err = s.putIndexPage(bucket, key, page, ids)
+		unlock()
+		return err
+	}
+	unlock()
+	return s.removeIndexId(bucket, key, id)
+}
+
+// removeIndexId blank out the id in its page, so the positions of other ids are not changed.
+// pages are scanned from the oldest one, expired items are the oldest
+func (s *Store) removeIndexId(bucket, key, id string) error {
+	defer s.listLocks.lock(bucket + "/" + key)()
+	pageNum, err := s.loadIndexPageNum(bucket, key)
+	if err != nil {
+		return err
+	}
+	for page := int64(0); page < pageNum; page++ {
+		ids, err := s.loadIndexPage(bucket, key, page)
+		if err != nil {
+			return err
+		}
+		for i := range ids {
+			if ids[i] == id {
+				ids[i] = ""
+				return s.putIndexPage(bucket, key, page, ids)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Store) scanIndex(bucket, key string, before int64, fn func(pos int64, id string) bool) error {
+	pageNum, err := s.loadIndexPageNum(bucket, key)
+	if err != nil {
+		return err
+	}
+	for page := pageNum - 1; page >= 0; page-- {
+		start := page * schema.IndexPageSize
+		if before > 0 && start >= before {
+			continue
+		}
+		ids, err := s.loadIndexPage(bucket, key, page)
+		if err != nil {
+			return err
+		}
+		for i := len(ids) - 1; i >= 0; i-- {
+			pos := start + int64(i)
+			if before > 0 && pos >= before {
+				continue
+			}
+			if ids[i] == "" { // deleted
+				continue
+			}
+			if !fn(pos, ids[i]) {
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Store) loadIndexPageNum(bucket, key string) (int64, error) {
+	data, err := s.KVDb.Get(bucket, key)
+	if err != nil {
+		if err == schema.ErrNotExist {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return strconv.ParseInt(string(data), 10, 64)
+}
+
+func (s *Store) loadIndexPage(bucket, key string, page int64) (ids []string, err error) {
+	data, err := s.KVDb.Get(bucket, indexPageKey(key, page))
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(data, &ids)
+	return
+}
+
+func (s *Store) putIndexPage(bucket, key string, page int64, ids []string) error {
+	val, err := json.Marshal(ids)
+	if err != nil {
+		return err
+	}
+	return s.KVDb.Put(bucket, indexPageKey(key, page), val)
+}
+
+func indexPageKey(key string, page int64) string {
+	return fmt.Sprintf("%s-%d", key, page)
+}
+
+func tagIndexKey(name, value string) string {
+	hash := sha256.Sum256([]byte(name + value))
+	return utils.Base64Encode(hash[:])
+}
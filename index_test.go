@@ -0,0 +1,108 @@
+package arseeding
+
+import (
+	"fmt"
+	"github.com/everFinance/arseeding/schema"
+	"github.com/everFinance/goar"
+	"github.com/everFinance/goar/types"
+	"github.com/everFinance/goether"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"sync"
+	"testing"
+)
+
+func TestQueryIndexedItems(t *testing.T) {
+	dbPath := "./data/tmp.db"
+	eccSigner, err := goether.NewSigner("4c3f9a1e5b234ce8f1ab58d82f849c0f70a4d5ceaf2b6e2d9a6c58b1f897ef0a")
+	assert.NoError(t, err)
+	itemSigner, err := goar.NewItemSigner(eccSigner)
+	assert.NoError(t, err)
+	s, err := NewBoltStore(dbPath)
+	assert.NoError(t, err)
+	defer os.RemoveAll(dbPath)
+
+	target := "Ii5wAMlLNz13n26nYY45mcZErwZLjICmYd46GZvn4ck"
+	items := make([]types.BundleItem, 0)
+	for i, appName := range []string{"app-1", "app-2", "app-1", "app-1"} {
+		tg := ""
+		if i > 0 {
+			tg = target
+		}
+		item, err := itemSigner.CreateAndSignItem([]byte{byte(i)}, tg, "", []types.Tag{{Name: "App-Name", Value: appName}})
+		assert.NoError(t, err)
+		assert.NoError(t, s.AtomicSaveItem(item))
+		items = append(items, item)
+	}
+	owner, err := Base64Address(items[0].Owner)
+	assert.NoError(t, err)
+
+	// owner and tag, paged
+	filter := schema.ItemIndexFilter{Owner: owner, Tags: []types.Tag{{Name: "App-Name", Value: "app-1"}}}
+	res, cursor, err := s.QueryIndexedItems(filter, 0, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(res))
+	assert.Equal(t, items[3].Id, res[0].Id)
+	assert.Equal(t, items[2].Id, res[1].Id)
+	res, cursor, err = s.QueryIndexedItems(filter, cursor, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(res))
+	assert.Equal(t, items[0].Id, res[0].Id)
+	assert.Equal(t, int64(0), cursor)
+
+	// target
+	res, _, err = s.QueryIndexedItems(schema.ItemIndexFilter{Target: target}, 0, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(res))
+
+	// deleted item is removed from indexes
+	assert.NoError(t, s.AtomicDelItem(items[2].Id))
+	res, _, err = s.QueryIndexedItems(schema.ItemIndexFilter{Tags: []types.Tag{{Name: "App-Name", Value: "app-1"}}}, 0, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(res))
+	assert.Equal(t, items[3].Id, res[0].Id)
+	assert.Equal(t, items[0].Id, res[1].Id)
+
+	// index is removed by positions, without item meta
+	record, err := s.LoadIndexRecord(items[3].Id)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(record.Positions))
+	assert.Equal(t, schema.IndexPosition{Bucket: schema.TxIndexOwnerBucket, Key: owner, Pos: 3}, record.Positions[0])
+	assert.NoError(t, s.DelItemMeta(items[3].Id))
+	assert.NoError(t, s.AtomicDelItem(items[3].Id))
+	assert.False(t, s.IsExistItemBinary(items[3].Id))
+	res, _, err = s.QueryIndexedItems(schema.ItemIndexFilter{Tags: []types.Tag{{Name: "App-Name", Value: "app-1"}}}, 0, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(res))
+	assert.Equal(t, items[0].Id, res[0].Id)
+
+	_, _, err = s.QueryIndexedItems(schema.ItemIndexFilter{}, 0, 10)
+	assert.Equal(t, schema.ErrNilIndexFilter, err)
+}
+
+func TestAppendIndexIdConcurrent(t *testing.T) {
+	dbPath := "./data/tmp.db"
+	s, err := NewBoltStore(dbPath)
+	assert.NoError(t, err)
+	defer os.RemoveAll(dbPath)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := s.appendIndexId(schema.TxIndexOwnerBucket, fmt.Sprintf("owner%d", i%2), fmt.Sprintf("id%d", i))
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+
+	for _, owner := range []string{"owner0", "owner1"} {
+		positions := make(map[int64]struct{})
+		assert.NoError(t, s.ScanIdsByOwner(owner, 0, func(pos int64, id string) bool {
+			positions[pos] = struct{}{}
+			return true
+		}))
+		assert.Equal(t, 25, len(positions))
+	}
+}
@@ -10,7 +10,6 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/crypto/ecies"
-	"github.com/everFinance/arseeding/rawdb"
 	"github.com/everFinance/arseeding/schema"
 	"github.com/everFinance/go-everpay/account"
 	"github.com/everFinance/go-everpay/config"
@@ -25,7 +24,6 @@ import (
 	"github.com/tidwall/gjson"
 	"gorm.io/gorm"
 	"math/big"
-	"os"
 	"strings"
 	"sync"
 	"time"
@@ -42,6 +40,10 @@ func (s *Arseeding) runJobs(bundleInterval int) {
 	s.scheduler.Every(2).Minute().SingletonMode().Do(s.updateArFee)
 	s.scheduler.Every(30).Seconds().SingletonMode().Do(s.updateInfo)
 	s.scheduler.Every(5).Minute().SingletonMode().Do(s.updatePeerMap)
+	s.scheduler.Every(1).Minute().SingletonMode().Do(s.gateways.HealthCheck)
+	if s.EnableManifest {
+		s.scheduler.Every(1).Minute().SingletonMode().Do(s.loadCustomDomains)
+	}
 	s.scheduler.Every(5).Minute().SingletonMode().Do(s.updateTokenPrice)
 	s.scheduler.Every(1).Minute().SingletonMode().Do(s.updateBundlePerFee)
 	// about bundle
@@ -54,11 +56,16 @@ func (s *Arseeding) runJobs(bundleInterval int) {
 		s.scheduler.Every(1).Day().At("00:00").SingletonMode().Do(s.collectFee)
 	}
 
-	s.scheduler.Every(bundleInterval).Seconds().SingletonMode().Do(s.onChainBundleItems) // can set a longer time, if the items are less. such as 2m
+	bundleTick := bundleInterval
+	if s.bundlePolicy.Enable {
+		bundleTick = schema.BundlePolicyCheckInterval // policy decide when to on chain
+	}
+	s.scheduler.Every(bundleTick).Seconds().SingletonMode().Do(s.onChainBundleItems) // can set a longer time, if the items are less. such as 2m
 	// onChainBundleItems by upload order
 	s.scheduler.Every(bundleInterval).Seconds().SingletonMode().Do(s.onChainItemsBySeq)
 	s.scheduler.Every(3).Minute().SingletonMode().Do(s.watchArTx)
 	s.scheduler.Every(2).Minute().SingletonMode().Do(s.retryOnChainArTx)
+	s.scheduler.Every(5).Minute().SingletonMode().Do(s.verifyBundleInclusion)
 
 	// s.scheduler.Every(10).Seconds().SingletonMode().Do(s.parseAndSaveBundleTx) // todo stream
 
@@ -67,9 +74,6 @@ func (s *Arseeding) runJobs(bundleInterval int) {
 
 	s.scheduler.Every(1).Minute().SingletonMode().Do(s.updateBundler)
 
-	// delete tmp file, one may be repeat request same data,tmp file can be reserve with short time
-	s.scheduler.Every(2).Minute().SingletonMode().Do(s.deleteTmpFile)
-
 	// statistic
 	s.scheduler.Every(1).Minute().SingletonMode().Do(s.UpdateRealTime)
 	go s.ProduceDailyStatistic()
@@ -202,14 +206,14 @@ func (s *Arseeding) watchEverReceiptTxs() {
 	}
 	subTx := s.everpaySdk.Cli.SubscribeTxs(sdkSchema.FilterQuery{
 		StartCursor: int64(startCursor),
-		Address:     s.bundler.Signer.Address,
+		Address:     s.bundler.Signer.Address(),
 	})
 	defer subTx.Unsubscribe()
 
 	for {
 		select {
 		case tt := <-subTx.Subscribe():
-			if tt.To != s.bundler.Signer.Address {
+			if tt.To != s.bundler.Signer.Address() {
 				continue
 			}
 			_, from, err := account.IDCheck(tt.From)
@@ -518,6 +522,10 @@ func getUnPaidOrdersByItemIds(wdb *Wdb, itemIds []string) ([]schema.Order, error
 }
 
 func (s *Arseeding) collectFee() {
+	if _, ok := everpaySigner(s.bundler.Signer); !ok {
+		log.Warn("everTx can not be signed by remote bundler signer, skip fee collection")
+		return
+	}
 	collectAddr := s.config.FeeCollectAddress()
 	if collectAddr == "" {
 		log.Warn("s.config.FeeCollectAddress()", "collectAddr", "null")
@@ -525,9 +533,9 @@ func (s *Arseeding) collectFee() {
 	}
 
 	// check bundler address token balance
-	tokBals, err := s.everpaySdk.Cli.Balances(s.bundler.Signer.Address)
+	tokBals, err := s.everpaySdk.Cli.Balances(s.bundler.Signer.Address())
 	if err != nil {
-		log.Error("s.everpaySdk.Cli.Balances(s.bundler.Signer.Address)", "err", err, "bundler", s.bundler.Signer.Address)
+		log.Error("s.everpaySdk.Cli.Balances(s.bundler.Signer.Address())", "err", err, "bundler", s.bundler.Signer.Address())
 		return
 	}
 
@@ -542,7 +550,7 @@ func (s *Arseeding) collectFee() {
 		mmap := map[string]string{
 			"appName": "arseeding",
 			"action":  "feeCollection",
-			"bundler": s.bundler.Signer.Address,
+			"bundler": s.bundler.Signer.Address(),
 		}
 		data, _ := json.Marshal(mmap)
 		_, err = s.everpaySdk.Transfer(tokBal.Tag, amt, collectAddr, string(data))
@@ -554,6 +562,9 @@ func (s *Arseeding) collectFee() {
 }
 
 func (s *Arseeding) refundReceipt() {
+	if _, ok := everpaySigner(s.bundler.Signer); !ok {
+		return // refund receipts are kept unrefund for manual refund
+	}
 	recpts, err := s.wdb.GetReceiptsByStatus(schema.UnRefund)
 	if err != nil {
 		log.Error("s.wdb.GetReceiptsByStatus(schema.UnRefund)", "err", err)
@@ -593,23 +604,41 @@ func (s *Arseeding) refundReceipt() {
 }
 
 func (s *Arseeding) onChainBundleItems() {
-	ords, err := s.wdb.GetNeedOnChainOrders()
+	// candidates are selected by apikey tier in db, fee priority is sorted by planBundles
+	limit := schema.BundlePlanCandidates
+	if s.bundlePolicy.Priority == schema.BundlePriorityFee {
+		limit = schema.BundlePlanFeeCandidates
+	}
+	// orders waited longer than MaxWait are always candidates, so they are flushed even if higher tier orders are more than limit
+	waitedBefore := time.Time{}
+	if s.bundlePolicy.MaxWait > 0 {
+		waitedBefore = time.Now().Add(-time.Duration(s.bundlePolicy.MaxWait) * time.Second)
+	}
+	ords, err := s.wdb.GetNeedOnChainOrdersByTier(s.bundlePolicy.ApiKeyTiers, waitedBefore, limit)
 	if err != nil {
-		log.Error("s.wdb.GetNeedOnChainOrders()", "err", err)
+		log.Error("s.wdb.GetNeedOnChainOrdersByTier(s.bundlePolicy.ApiKeyTiers,waitedBefore,limit)", "err", err)
 		return
 	}
 	if len(ords) == 0 {
 		return
 	}
-	// send arTx to arweave
-	arTx, onChainItemIds, err := s.onChainOrds(ords)
-	if err != nil {
-		log.Error("s.onChainOrds()", "err", err)
-		return
+	// big queue is split into bundles by policy, orders of apikey with own bundle tags or pinned bundler are packed separately
+	prices := s.tokenPrices()
+	separate := func(apiKey string) bool {
+		return s.bundleTags.hasApiKeyTags(apiKey) || s.bundlers.pinned(apiKey)
 	}
+	for _, group := range groupOrds(ords, separate) {
+		for _, bundleOrds := range planBundles(group, s.bundlePolicy, prices, time.Now()) {
+			// send arTx to arweave
+			arTx, onChainItemIds, err := s.onChainOrds(bundleOrds)
+			if err != nil {
+				log.Error("s.onChainOrds()", "err", err)
+				continue
+			}
 
-	s.updateOnChainInfo(onChainItemIds, arTx, schema.PendingOnChain)
-
+			s.updateOnChainInfo(onChainItemIds, arTx, schema.PendingOnChain)
+		}
+	}
 }
 
 func (s *Arseeding) onChainItemsBySeq() {
@@ -622,7 +651,14 @@ func (s *Arseeding) onChainItemsBySeq() {
 	if len(ords) == 0 {
 		return
 	}
-	arTx, onChainItemIds, err := s.onChainOrds(ords)
+	// keep the sequence, only size limit of policy is used
+	policy := s.bundlePolicy
+	policy.Priority, policy.ApiKeyTiers = schema.BundlePriorityFifo, nil
+	bundles := planBundles(ords, policy, nil, time.Now())
+	if len(bundles) == 0 {
+		return
+	}
+	arTx, onChainItemIds, err := s.onChainOrds(bundles[0])
 	if err != nil {
 		return
 	}
@@ -657,7 +693,7 @@ func (s *Arseeding) onChainOrds(ords []schema.Order) (arTx types.Transaction, on
 	}
 
 	// send arTx to arweave
-	return s.onChainBundleTx(itemIds)
+	return s.onChainBundleTx(itemIds, bundleApiKey(ords), 0, 0)
 }
 
 func (s *Arseeding) updateOnChainInfo(onChainItemIds []string, arTx types.Transaction, onChainStatus string) {
@@ -675,10 +711,15 @@ func (s *Arseeding) updateOnChainInfo(onChainItemIds []string, arTx types.Transa
 		Status:    schema.PendingOnChain,
 		ItemIds:   onChainItemIdsJs,
 		ItemNum:   len(onChainItemIds),
+		Bundler:   bundlerOfArTx(arTx),
+		Attempts:  1,
 	}); err != nil {
 		log.Error("s.wdb.InsertArTx", "err", err)
 		return
 	}
+	if err = s.store.SaveItemsBundledIn(arTx.ID, onChainItemIds); err != nil {
+		log.Error("s.store.SaveItemsBundledIn(arTx.ID,onChainItemIds)", "err", err, "arId", arTx.ID)
+	}
 
 	// update order onChainStatus to pending
 	for _, itemId := range onChainItemIds {
@@ -699,7 +740,7 @@ func (s *Arseeding) watchArTx() {
 		// check onchain status
 		arTxStatus, err := s.arCli.GetTransactionStatus(tx.ArId)
 		if err != nil {
-			if err != goar.ErrPendingTx && s.cache.GetInfo().Height-tx.CurHeight > 50 {
+			if err != goar.ErrPendingTx && s.cache.GetInfo().Height-tx.CurHeight > int64(s.resubmitPolicy.ExpiredBlocks) {
 				// arTx has expired
 				if err = s.wdb.UpdateArTxStatus(tx.ArId, schema.FailedOnChain, nil, nil); err != nil {
 					log.Error("UpdateArTxStatus(tx.ArId,schema.FailedOnChain)", "err", err)
@@ -716,6 +757,12 @@ func (s *Arseeding) watchArTx() {
 				dbTx.Rollback()
 				continue
 			}
+			// items of bundle are verified by verifyBundleInclusion
+			if err = s.wdb.UpdateArTxInclusion(tx.ArId, schema.InclusionUnchecked, dbTx); err != nil {
+				log.Error("s.wdb.UpdateArTxInclusion(tx.ArId,schema.InclusionUnchecked,dbTx)", "err", err)
+				dbTx.Rollback()
+				continue
+			}
 
 			// update order onchain status
 			bundleItemIds := make([]string, 0)
@@ -747,133 +794,74 @@ func (s *Arseeding) retryOnChainArTx() {
 		return
 	}
 	for _, tx := range txs {
-		itemIds := make([]string, 0)
-		if err = json.Unmarshal(tx.ItemIds, &itemIds); err != nil {
+		if err = s.resubmitArTx(tx); err != nil {
+			log.Error("s.resubmitArTx(tx)", "err", err, "id", tx.ID, "arId", tx.ArId)
 			return
 		}
-		arTx, _, err := s.onChainBundleTx(itemIds)
-		if err != nil {
-			return
-		}
-		// update onChain
-		if err = s.wdb.UpdateArTx(tx.ID, arTx.ID, s.cache.GetInfo().Height, arTx.DataSize, arTx.Reward, schema.PendingOnChain); err != nil {
-			log.Error("s.wdb.UpdateArTx", "err", err, "id", tx.ID, "arId", arTx.ID)
-		}
 	}
 }
 
-func (s *Arseeding) onChainBundleTx(itemIds []string) (arTx types.Transaction, onChainItemIds []string, err error) {
-	onChainItems := make([]types.BundleItem, 0)
-	bundle := &types.Bundle{}
-	verifyBundle := &types.Bundle{}
-	if s.store.KVDb.Type() != rawdb.S3Type {
-		onChainItems, err = s.getOnChainBundle(itemIds)
-		// assemble and send to arweave
-		bundle, err = utils.NewBundle(onChainItems...)
-		if err != nil {
-			log.Error("utils.NewBundle(onChainItems...)", "err", err)
-			return
-		}
-
-		// verify bundle, ensure that the bundle is exactly right before sending
-		if _, err = utils.DecodeBundle(bundle.BundleBinary); err != nil {
-			err = errors.New(fmt.Sprintf("Verify bundle failed; err:%v", err))
-			return
-		}
-	} else { // only s3 support stream
-		defer func() {
-			for _, item := range onChainItems {
-				if item.DataReader != nil {
-					item.DataReader.Close()
-					os.Remove(item.DataReader.Name())
-				}
-			}
-			for _, item := range verifyBundle.Items {
-				if item.DataReader != nil {
-					item.DataReader.Close()
-					os.Remove(item.DataReader.Name())
-				}
-			}
-			if bundle.BundleDataReader != nil {
-				bundle.BundleDataReader.Close()
-				os.Remove(bundle.BundleDataReader.Name())
-			}
-		}()
-
-		onChainItems, err = s.getOnChainBundleStream(itemIds)
-		if err != nil {
-			log.Error("s.getOnChainBundleStream(itemIds)", "err", err)
-			return
-		}
-		// assemble and send to arweave
-		bundle, err = utils.NewBundleStream(onChainItems...)
-		if err != nil {
-			log.Error("utils.NewBundle(onChainItems...)", "err", err)
-			return
-		}
-
-		// verify bundle, ensure that the bundle is exactly right before sending
-		if verifyBundle, err = utils.DecodeBundleStream(bundle.BundleDataReader); err != nil {
-			err = errors.New(fmt.Sprintf("Verify bundle failed; err:%v", err))
-			return
-		}
-		for _, item := range verifyBundle.Items {
-			if err = utils.VerifyBundleItem(item); err != nil {
-				log.Error("utils.VerifyBundleItem(item)", "err", err, "itemId", item.Id)
-				err = errors.New("utils.VerifyBundleItem(item) failed")
-				return
-			}
-		}
+// onChainBundleTx apiKey is the owner of all items, it decides the bundle tags.
+// attempt is the number of arTxs sent for the bundle before, reward is escalated by resubmit policy and capped by maxReward, 0 means no cap
+func (s *Arseeding) onChainBundleTx(itemIds []string, apiKey string, attempt int, maxReward int64) (arTx types.Transaction, onChainItemIds []string, err error) {
+	items, skipped, err := s.checkBundleItems(itemIds)
+	s.markItemsLost(skipped)
+	if err != nil {
+		return
 	}
 
 	// get onChainItemIds
-	for _, item := range onChainItems {
-		onChainItemIds = append(onChainItemIds, item.Id)
+	for _, item := range items {
+		onChainItemIds = append(onChainItemIds, item.id)
 	}
 
-	arTxtags := []types.Tag{
-		{Name: "App-Name", Value: "arseeding"},
-		{Name: "App-Version", Value: "1.0.0"},
-		{Name: "Action", Value: "Bundle"},
-		{Name: "Protocol-Name", Value: "U"},
-		{Name: "Action", Value: "Burn"},
-		{Name: "App-Name", Value: "SmartWeaveAction"},
-		{Name: "App-Version", Value: "0.3.0"},
-		{Name: "Input", Value: `{"function":"mint"}`},
-		{Name: "Contract", Value: "KTzTXT_ANmF84fWEKHzWURD1LWd9QaFR9yfYUwH2Lxw"},
+	size := bundleSize(items)
+	arTxtags, err := s.bundleTags.render(apiKey, size, len(items))
+	if err != nil {
+		log.Error("s.bundleTags.render(apiKey,size,len(items))", "err", err)
+		return
 	}
-
 	if len(s.customTags) > 0 {
-		arTxtags = append(s.customTags, arTxtags...)
+		arTxtags = append(append([]types.Tag{}, s.customTags...), arTxtags...)
 	}
 
-	// speed arTx Fee
+	bundler, err := s.bundlers.pick(apiKey, arTxtags)
+	if err != nil {
+		log.Error("s.bundlers.pick(apiKey,arTxtags)", "err", err)
+		return
+	}
+	defer s.bundlers.release(bundler)
 
+	// speed arTx Fee
 	concurrentNum := s.config.Param.ChunkConcurrentNum
-	if len(bundle.BundleBinary) > 0 {
-		log.Debug("use binary submit bundle arTx", "binary length:", len(bundle.BundleBinary))
-		price := calculatePrice(s.cache.GetFee(), int64(len(bundle.BundleBinary)))
-		speedFactor := calculateFactor(price, s.config.GetSpeedFee())
-		arTx, err = s.bundler.SendBundleTxSpeedUp(context.TODO(), concurrentNum, bundle.BundleBinary, arTxtags, speedFactor)
-	} else {
-		fileInfo, err1 := bundle.BundleDataReader.Stat()
+	price := calculatePrice(s.cache.GetFee(), size)
+	speedFactor := resubmitSpeedFactor(s.resubmitPolicy, price, s.config.GetSpeedFee(), attempt)
+	if size <= schema.MaxInMemoryBundleSize {
+		bundleBinary, err1 := loadBundle(s.store, items)
 		if err1 != nil {
 			err = err1
+			log.Error("loadBundle(s.store,items)", "err", err)
 			return
 		}
-		if fileInfo.Size() == 0 {
-			err = errors.New("bundle.BundleDataReader is null")
+		log.Debug("use binary submit bundle arTx", "binary length:", len(bundleBinary))
+		arTx, err = bundler.SendBundleTxSpeedUp(context.TODO(), concurrentNum, bundleBinary, arTxtags, speedFactor, maxReward)
+	} else {
+		// write bundle to spool file, so the bundle size is not limited by memory
+		spool, err1 := spoolBundle(s.store, items)
+		if err1 != nil {
+			err = err1
+			log.Error("spoolBundle(s.store,items)", "err", err)
 			return
 		}
-		price := calculatePrice(s.cache.GetFee(), fileInfo.Size())
-		speedFactor := calculateFactor(price, s.config.GetSpeedFee())
-		arTx, err = s.bundler.SendBundleTxSpeedUp(context.TODO(), concurrentNum, bundle.BundleDataReader, arTxtags, speedFactor)
+		defer spool.Close()
+		log.Debug("use spool file submit bundle arTx", "size", size)
+		arTx, err = sendBundleSpool(bundler, concurrentNum, spool, arTxtags, speedFactor, maxReward)
 	}
 	if err != nil {
-		log.Error("s.bundler.SendBundleTxSpeedUp(bundle.BundleBinary,arTxtags)", "err", err)
+		log.Error("bundler.SendBundleTxSpeedUp(bundle.BundleBinary,arTxtags)", "err", err, "bundler", bundler.Signer.Address())
 		return
 	}
-	log.Info("Send bundle arTx", "arTx", arTx.ID)
+	log.Info("Send bundle arTx", "arTx", arTx.ID, "bundler", bundler.Signer.Address())
 
 	// arseeding broadcast tx data
 	if err := s.arseedCli.SubmitTxConcurrent(context.TODO(), concurrentNum, arTx); err != nil {
@@ -882,75 +870,6 @@ func (s *Arseeding) onChainBundleTx(itemIds []string) (arTx types.Transaction, o
 	return
 }
 
-func (s *Arseeding) getOnChainBundle(itemIds []string) (onChainItems []types.BundleItem, err error) {
-	onChainItems = make([]types.BundleItem, 0, len(itemIds))
-	for _, itemId := range itemIds {
-		_, itemBinary, err := s.store.LoadItemBinary(itemId)
-		if err != nil {
-			log.Error("s.store.LoadItemBinary(itemId)", "err", err, "itemId", itemId)
-			continue
-		}
-		item, err := utils.DecodeBundleItem(itemBinary)
-		if err != nil {
-			log.Error("utils.DecodeBundleItem(itemBinary)", "err", err, "itemId", itemId)
-			continue
-		}
-		onChainItems = append(onChainItems, *item)
-	}
-	if len(onChainItems) == 0 {
-		err = errors.New("onChainItems is null")
-		return
-	}
-
-	// the end off item.Data not be "", because when the case viewblock decode failed. // todo viewblock used stream function decode item, so this is a bug for them
-	endItem := onChainItems[len(onChainItems)-1]
-	if endItem.Data == "" {
-		// find a data != "" item and push to end off
-		idx := -1
-		for i, item := range onChainItems {
-			if item.Data != "" {
-				idx = i
-				break
-			}
-		}
-		if idx == -1 {
-			err = errors.New("all bundle items data are null")
-			return
-		}
-		newEndItem := onChainItems[idx]
-		onChainItems = append(onChainItems[:idx], onChainItems[idx+1:]...)
-		onChainItems = append(onChainItems, newEndItem)
-	}
-	return
-}
-
-func (s *Arseeding) getOnChainBundleStream(itemIds []string) (onChainItems []types.BundleItem, err error) {
-	onChainItems = make([]types.BundleItem, 0, len(itemIds))
-	for _, itemId := range itemIds {
-		binaryReader, _, err := s.store.LoadItemBinary(itemId)
-		if err != nil {
-			log.Error("s.store.LoadItemBinary(itemId)", "err", err, "itemId", itemId)
-			continue
-		}
-
-		item, err2 := utils.DecodeBundleItemStream(binaryReader)
-		if err2 != nil {
-			log.Error("utils.DecodeBundleItem(itemBinary)", "err", err2, "itemId", itemId)
-			continue
-		}
-		binaryReader.Close()
-		os.Remove(binaryReader.Name())
-		onChainItems = append(onChainItems, *item)
-	}
-	if len(onChainItems) == 0 {
-		err = errors.New("onChainItems is null")
-		return
-	}
-	return
-	// the end off item.Data not be "", because when the case viewblock decode failed. // todo viewblock used stream function decode item, so this is a bug for them
-	// todo
-}
-
 func (s *Arseeding) processExpiredOrd() {
 	ords, err := s.wdb.GetExpiredOrders()
 	if err != nil {
@@ -1016,24 +935,33 @@ func (s *Arseeding) parseAndSaveBundleTx() {
 }
 
 func (s *Arseeding) updateBundler() {
-	// update bundler balance
-	addr := s.bundler.Signer.Address
-	bal, err := s.arCli.GetWalletBalance(addr)
+	// update balance of all bundlers
+	for _, addr := range s.bundlers.addresses() {
+		bal, err := s.arCli.GetWalletBalance(addr)
+		if err != nil {
+			log.Error("s.arCli.GetWalletBalance(addr)", "err", err, "bundler", addr)
+			continue
+		}
+		s.bundlers.setBalance(addr, bal)
+		metricBundlerBalance(bal, addr)
+	}
+
+	pending, err := s.wdb.GetPendingArTxNumByBundler()
 	if err != nil {
+		log.Error("s.wdb.GetPendingArTxNumByBundler()", "err", err)
 		return
 	}
-	metricBundlerBalance(bal, addr)
+	// arTxs without bundler are posted by the primary bundler
+	pending[s.bundler.Signer.Address()] += pending[""]
+	s.bundlers.setPending(pending)
 }
 
-func (s *Arseeding) deleteTmpFile() {
-	tmpFileMapLock.Lock()
-	defer tmpFileMapLock.Unlock()
-	for tmpFileName, cnt := range tmpFileMap {
-		if cnt <= 0 {
-			delete(tmpFileMap, tmpFileName)
-			os.Remove(tmpFileName)
-		}
+func bundlerOfArTx(arTx types.Transaction) string {
+	addr, err := utils.OwnerToAddress(arTx.Owner)
+	if err != nil {
+		return ""
 	}
+	return addr
 }
 
 func filterPeers(peers []string, constTx *types.Transaction) map[string]bool {
@@ -1,16 +1,18 @@
 package arseeding
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/everFinance/arseeding/argraphql"
-	"github.com/everFinance/goar"
-	"gopkg.in/h2non/gentleman.v2"
+	"github.com/gin-gonic/gin"
+	"html/template"
 	"io"
 	"net/http"
-	"os"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/everFinance/arseeding/schema"
@@ -18,39 +20,319 @@ import (
 	"github.com/everFinance/goar/utils"
 )
 
-func handleManifest(maniData []byte, path string, db *Store) ([]types.Tag, []byte, error) {
+// handleManifest resolve path in manifest, dataReader of the resource must be closed by caller
+func handleManifest(maniData []byte, path string, db *Store) (txId string, tags []types.Tag, dataReader io.ReadSeekCloser, data []byte, err error) {
+	txId, err = resolveManifest(maniData, path, db, 0)
+	if err != nil {
+		return
+	}
+	tags, dataReader, data, err = getArTxOrItemData(txId, db)
+	return
+}
+
+// resolveManifest return the resource id of path, a path point to another manifest is resolved in the nested manifest
+func resolveManifest(maniData []byte, path string, db *Store, depth int) (string, error) {
+	if depth >= schema.MaxManifestDepth {
+		return "", schema.ErrManifestDepth
+	}
 	mani := schema.ManifestData{}
 	if err := json.Unmarshal(maniData, &mani); err != nil {
-		return nil, nil, err
+		return "", err
 	}
 
-	path = strings.TrimPrefix(path, "/")
-	path = strings.TrimSuffix(path, "/")
+	path = strings.Trim(path, "/")
 	if path == "" {
-		if mani.Index.Path == "" {
-			return nil, nil, schema.ErrPageNotFound
+		if mani.Index.TxId != "" {
+			return resolveManifestResource(mani.Index.TxId, "", db, depth)
 		}
 		path = mani.Index.Path
 	}
-	txId, ok := mani.Paths[path]
-	if !ok {
-		// could ignore index.html, so add index.html and try again
-		txId, ok = mani.Paths[path+"/"+"index.html"]
+	if path != "" {
+		txId, ok := mani.Paths[path]
+		if !ok {
+			// could ignore index.html, so add index.html and try again
+			txId, ok = mani.Paths[path+"/"+"index.html"]
+		}
+		if ok {
+			return resolveManifestResource(txId.TxId, "", db, depth)
+		}
+
+		// the longest prefix of path which is a nested manifest
+		for i := strings.LastIndex(path, "/"); i > 0; i = strings.LastIndex(path[:i], "/") {
+			txId, ok := mani.Paths[path[:i]]
+			if ok && isManifestResource(txId.TxId, db) {
+				resId, err := resolveManifestResource(txId.TxId, path[i+1:], db, depth)
+				if err != schema.ErrPageNotFound {
+					return resId, err
+				}
+				// not found in nested manifest, use fallback of current manifest
+				break
+			}
+		}
 	}
-	if !ok {
-		return nil, nil, schema.ErrPageNotFound
+
+	// v0.2.0 fallback, e.g. single page app
+	if mani.Fallback != nil && mani.Fallback.TxId != "" {
+		return resolveManifestResource(mani.Fallback.TxId, "", db, depth)
 	}
+	return "", schema.ErrPageNotFound
+}
 
-	tags, dataReader, data, err := getArTxOrItemData(txId.TxId, db)
+func resolveManifestResource(txId, subPath string, db *Store, depth int) (string, error) {
+	if !isManifestResource(txId, db) {
+		return txId, nil
+	}
+	_, dataReader, data, err := getArTxOrItemData(txId, db)
+	if err != nil {
+		return "", err
+	}
 	if dataReader != nil {
 		data, err = io.ReadAll(dataReader)
 		dataReader.Close()
-		os.Remove(dataReader.Name())
+		if err != nil {
+			return "", err
+		}
+	}
+	return resolveManifest(data, subPath, db, depth+1)
+}
+
+func isManifestResource(txId string, db *Store) bool {
+	tags, err := getArTxOrItemTags(txId, db)
+	return err == nil && getTagValue(tags, schema.ContentType) == schema.ManifestType
+}
+
+// manifestPathResponse serve the resource of request path in manifest like direct data access, but with short cache time
+func manifestPathResponse(c *gin.Context, maniData []byte, db *Store) {
+	c.Abort()
+	txId, tags, dataReader, data, err := handleManifest(maniData, c.Request.URL.Path, db)
+	if err != nil {
+		if dataReader != nil {
+			dataReader.Close()
+		}
+		if err == schema.ErrPageNotFound && strings.Trim(c.Request.URL.Path, "/") == "" {
+			// manifest without index, show the index of paths
+			if entries, _, err := listManifestEntries(maniData, "", "", schema.ManifestListMaxNum, db); err == nil {
+				c.Header("Cache-Control", schema.ManifestCacheControl)
+				manifestIndexHtml(c, "/", entries, false)
+				return
+			}
+		}
+		manifestErrorResponse(c, err)
+		return
+	}
+	c.Header("Cache-Control", schema.ManifestCacheControl)
+	dataResponse(c, dataReader, data, tags, txId)
+}
+
+// manifestErrorResponse response the error of manifest resolving with suitable status code
+func manifestErrorResponse(c *gin.Context, err error) {
+	switch err {
+	case schema.ErrPageNotFound, schema.ErrLocalNotExist:
+		notFoundResponse(c, err.Error())
+	case schema.ErrManifestDepth:
+		c.JSON(http.StatusLoopDetected, schema.RespErr{Err: err.Error()})
+	case schema.ErrManifestPaths:
+		errorResponse(c, err.Error())
+	default:
+		internalErrorResponse(c, err.Error())
+	}
+}
+
+// listManifest list path tree of manifest, query params: prefix, cursor, num, format=html.
+// entries are sorted by path, the next page starts after the cursor path
+func (s *Arseeding) listManifest(c *gin.Context) {
+	id := c.Param("id")
+	num, err := strconv.Atoi(c.DefaultQuery("num", strconv.Itoa(schema.ManifestListDefaultNum)))
+	if err != nil {
+		errorResponse(c, err.Error())
+		return
+	}
+	if num <= 0 || num > schema.ManifestListMaxNum {
+		num = schema.ManifestListMaxNum
+	}
+	if !isManifestResource(id, s.store) {
+		if _, err := getArTxOrItemTags(id, s.store); err != nil {
+			notFoundResponse(c, err.Error())
+		} else {
+			errorResponse(c, "not a manifest")
+		}
+		return
+	}
+	maniData, err := loadManifestData(id, s.store)
+	if err != nil {
+		manifestErrorResponse(c, err)
+		return
+	}
+	mani := schema.ManifestData{}
+	if err = json.Unmarshal(maniData, &mani); err != nil {
+		internalErrorResponse(c, err.Error())
+		return
+	}
+	entries, nextCursor, err := listManifestEntries(maniData, strings.TrimLeft(c.Query("prefix"), "/"), c.Query("cursor"), num, s.store)
+	if err != nil {
+		manifestErrorResponse(c, err)
+		return
+	}
+	if c.Query("format") == "html" {
+		manifestIndexHtml(c, id, entries, true)
+		return
+	}
+	c.JSON(http.StatusOK, schema.RespManifestLs{
+		ManifestId: id,
+		Index:      mani.Index,
+		Fallback:   mani.Fallback,
+		Entries:    entries,
+		NextCursor: nextCursor,
+	})
+}
+
+func loadManifestData(id string, db *Store) ([]byte, error) {
+	_, dataReader, data, err := getArTxOrItemData(id, db)
+	if err != nil {
+		return nil, err
+	}
+	if dataReader != nil {
+		defer dataReader.Close()
+		return io.ReadAll(dataReader)
+	}
+	return data, nil
+}
+
+// listManifestEntries list at most num paths start with prefix and after cursor path, sorted by path.
+// nextCursor is "" if there are no more entries
+func listManifestEntries(maniData []byte, prefix, cursor string, num int, db *Store) (entries []schema.ManifestEntry, nextCursor string, err error) {
+	l := &manifestLister{prefix: prefix, db: db}
+	if err = l.list(maniData, "", 0); err != nil {
+		return
+	}
+	sort.Slice(l.entries, func(i, j int) bool {
+		return l.entries[i].Path < l.entries[j].Path
+	})
+	start := sort.Search(len(l.entries), func(i int) bool {
+		return l.entries[i].Path > cursor
+	})
+	page := l.entries[start:]
+	if len(page) > num {
+		page = page[:num]
+		nextCursor = page[num-1].Path
+	}
+	// details of entry need several lookups, only load the entries of page
+	entries = make([]schema.ManifestEntry, 0, len(page))
+	for _, e := range page {
+		entries = append(entries, manifestEntry(e.Path, e.Id, e.Manifest, db))
+	}
+	return
+}
+
+// manifestLister collect the paths of manifest and its nested manifests, at most MaxManifestListPaths paths are resolved
+type manifestLister struct {
+	prefix  string
+	db      *Store
+	paths   int
+	entries []schema.ManifestEntry
+}
+
+// list basePath is the path of the nested manifest in root manifest
+func (l *manifestLister) list(maniData []byte, basePath string, depth int) error {
+	if depth >= schema.MaxManifestDepth {
+		return schema.ErrManifestDepth
+	}
+	mani := schema.ManifestData{}
+	if err := json.Unmarshal(maniData, &mani); err != nil {
+		return err
+	}
+
+	for p, res := range mani.Paths {
+		fullPath := basePath + p
+		matched := strings.HasPrefix(fullPath, l.prefix)
+		if !matched && !strings.HasPrefix(l.prefix, fullPath+"/") {
+			continue
+		}
+		if l.paths++; l.paths > schema.MaxManifestListPaths {
+			return schema.ErrManifestPaths
+		}
+		isManifest := isManifestResource(res.TxId, l.db)
+		if matched {
+			l.entries = append(l.entries, schema.ManifestEntry{Path: fullPath, Id: res.TxId, Manifest: isManifest})
+		}
+		if !isManifest {
+			continue
+		}
+		nestedData, err := loadManifestData(res.TxId, l.db)
+		if err != nil {
+			return err
+		}
+		if err = l.list(nestedData, fullPath+"/", depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isDataLocal the data of item or tx is in local store, tx data is synced by chunks after its meta
+func isDataLocal(id string, db *Store) bool {
+	if db.IsExistItemBinary(id) {
+		return true
+	}
+	txMeta, err := db.LoadTxMeta(id)
+	return err == nil && db.IsExistTxData(txMeta.DataRoot, txMeta.DataSize)
+}
+
+func manifestEntry(path, id string, isManifest bool, db *Store) schema.ManifestEntry {
+	entry := schema.ManifestEntry{
+		Path:     path,
+		Id:       id,
+		Local:    isDataLocal(id, db),
+		Manifest: isManifest,
+	}
+	if record, err := db.LoadIndexRecord(id); err == nil {
+		entry.Size = record.DataSize
+		entry.ContentType = record.ContentType
+	}
+	if entry.ContentType == "" {
+		if tags, err := getArTxOrItemTags(id, db); err == nil {
+			entry.ContentType = getTagValue(tags, schema.ContentType)
+		}
+	}
+	return entry
+}
+
+var manifestIndexTmpl = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Index of {{.Title}}</title></head>
+<body>
+<h1>Index of {{.Title}}</h1>
+<table>
+<tr><th>Path</th><th>Id</th><th>Size</th><th>Content-Type</th><th>Local</th></tr>
+{{range .Entries}}<tr><td><a href="{{.Href}}">{{.Path}}</a></td><td>{{.Id}}</td><td>{{.Size}}</td><td>{{.ContentType}}</td><td>{{.Local}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// manifestIndexHtml render entries as html page, link to the data of id if linkById, otherwise link to the path in manifest
+func manifestIndexHtml(c *gin.Context, title string, entries []schema.ManifestEntry, linkById bool) {
+	type htmlEntry struct {
+		schema.ManifestEntry
+		Href string
+	}
+	list := make([]htmlEntry, 0, len(entries))
+	for _, e := range entries {
+		href := "/" + e.Path
+		if linkById {
+			href = "/" + e.Id
+		}
+		list = append(list, htmlEntry{ManifestEntry: e, Href: href})
+	}
+	buf := &bytes.Buffer{}
+	if err := manifestIndexTmpl.Execute(buf, map[string]interface{}{"Title": title, "Entries": list}); err != nil {
+		internalErrorResponse(c, err.Error())
+		return
 	}
-	return tags, data, err
+	c.Data(http.StatusOK, "text/html; charset=utf-8", buf.Bytes())
 }
 
-func getArTxOrItemData(id string, db *Store) (decodeTags []types.Tag, binaryReader *os.File, data []byte, err error) {
+func getArTxOrItemData(id string, db *Store) (decodeTags []types.Tag, binaryReader io.ReadSeekCloser, data []byte, err error) {
 	// find bundle item
 	_, err = db.LoadItemMeta(id)
 	if err == nil {
@@ -72,7 +354,7 @@ func getArTxOrItemData(id string, db *Store) (decodeTags []types.Tag, binaryRead
 	return nil, nil, nil, schema.ErrLocalNotExist
 }
 
-func getArTxOrItemDataForManifest(id string, db *Store, s *Arseeding) (decodeTags []types.Tag, binaryReader *os.File, data []byte, err error) {
+func getArTxOrItemDataForManifest(id string, db *Store, s *Arseeding) (decodeTags []types.Tag, binaryReader io.ReadSeekCloser, data []byte, err error) {
 
 	//  find bundle item form local
 	decodeTags, binaryReader, data, err = getArTxOrItemData(id, db)
@@ -107,39 +389,29 @@ func getArTxOrItemTags(id string, db *Store) (decodeTags []types.Tag, err error)
 	return nil, schema.ErrLocalNotExist
 }
 
-func getBundleItemData(id string, db *Store) (decodeTags []types.Tag, dataReader *os.File, data []byte, err error) {
-	binaryReader, itemBinary, err := db.LoadItemBinary(id)
-	item := &types.BundleItem{}
-	if err == nil {
-		item, err = parseBundleItem(binaryReader, itemBinary)
-		if err != nil {
-			return nil, nil, nil, err
-		}
-		decodeTags = item.Tags
-		if binaryReader != nil {
-			binaryReader.Close()
-			os.Remove(binaryReader.Name())
-			dataReader = item.DataReader
-		} else {
-			data, err = utils.Base64Decode(item.Data)
-		}
+func getBundleItemData(id string, db *Store) (decodeTags []types.Tag, dataReader io.ReadSeekCloser, data []byte, err error) {
+	meta, err := db.LoadItemMeta(id)
+	if err != nil {
 		return
 	}
-	return
-}
-
-func parseBundleItem(binaryReader *os.File, itemBinary []byte) (item *types.BundleItem, err error) {
-	if binaryReader != nil {
-		item, err = utils.DecodeBundleItemStream(binaryReader)
-	} else {
-		item, err = utils.DecodeBundleItem(itemBinary)
+	binaryReader, err := db.KVDb.GetStream(schema.BundleItemBinary, id)
+	if err != nil {
+		return
 	}
-	return
+	// the verified stream is served, so the binary is not loaded again
+	if db.VerifyOnRead {
+		if err = verifyItemStream(id, binaryReader); err != nil {
+			binaryReader.Close()
+			return
+		}
+	}
+	dataReader, err = itemDataStream(binaryReader)
+	return meta.Tags, dataReader, nil, err
 }
 
 func syncManifestData(id string, s *Arseeding) (err error) {
 	//  get manifest  data
-	data, contentType, err := getRawById(id)
+	data, contentType, err := getRawById(s.gateways, id)
 	if err != nil {
 		return err
 	}
@@ -173,8 +445,6 @@ func syncManifestData(id string, s *Arseeding) (err error) {
 	}
 
 	// query itemIds from graphql
-	gq := argraphql.NewARGraphQL("https://arweave.net/graphql", http.Client{})
-
 	total := len(itemIds)
 
 	var txs []argraphql.BatchGetItemsBundleInTransactionsTransactionConnectionEdgesTransactionEdge
@@ -185,7 +455,11 @@ func syncManifestData(id string, s *Arseeding) (err error) {
 			end = total
 		}
 		log.Debug("BatchGetItemsBundleIn", "start", i, "end", end, "itemIds[i:end]", len(itemIds[i:end]))
-		resp, err := gq.BatchGetItemsBundleIn(context.Background(), itemIds[i:end], 90, "")
+		var resp *argraphql.BatchGetItemsBundleInResponse
+		err := s.gateways.Do(func(g *Gateway) (err error) {
+			resp, err = g.GraphQL().BatchGetItemsBundleIn(context.Background(), itemIds[i:end], 90, "")
+			return
+		})
 		if err != nil {
 			return errors.New("BatchGetItemsBundleIn error:" + err.Error())
 		}
@@ -210,24 +484,11 @@ func syncManifestData(id string, s *Arseeding) (err error) {
 	}
 	log.Debug("syncManifestData bundleInItemsMap", "bundleInItemsMap", len(bundleInItemsMap), "L1Artxs", len(L1Artxs))
 	// get bundle item  form goar
-	c := goar.NewClient("https://arweave.net")
 	for bundleId, itemIdss := range bundleInItemsMap {
 		log.Debug("syncManifestData GetBundleItems", "bundleId", bundleId, "itemIds", len(itemIdss))
-		var items []*types.BundleItem
-		// check bundleId is nestBundle
-		isNestBundle, dataSize, err := checkNestBundle(bundleId, gq)
-		if err != nil || !isNestBundle {
-			// GetBundleItems
-			items, err = c.GetBundleItems(bundleId, itemIdss)
-			if err != nil {
-				return errors.New("GetBundleItems error:" + err.Error())
-			}
-		} else {
-			log.Debug("nestBundle dataSize...", "size", dataSize)
-			items, err = getNestBundle(bundleId, itemIdss)
-			if err != nil {
-				return errors.New("getNestBundle error:" + err.Error())
-			}
+		items, err := getBundleItemsFromGateway(s.gateways, bundleId, itemIdss)
+		if err != nil {
+			return err
 		}
 
 		//  for each item
@@ -243,31 +504,43 @@ func syncManifestData(id string, s *Arseeding) (err error) {
 	return err
 }
 
-func getRawById(id string) (data []byte, contentType string, err error) {
-	var arGateway = "https://arweave.net"
-	client := gentleman.New().URL(arGateway)
-
-	res, err := client.Get().AddPath(fmt.Sprintf("/raw/%s", id)).Send()
+// getRawById get raw data of arTx or bundle item from upstream gateways
+func getRawById(gateways *Gateways, id string) (data []byte, contentType string, err error) {
+	err = gateways.Do(func(g *Gateway) error {
+		data, contentType, err = g.getRaw(id)
+		return err
+	})
+	return
+}
 
+func (g *Gateway) getRaw(id string) (data []byte, contentType string, err error) {
+	res, err := g.client.Get(fmt.Sprintf("%s/raw/%s", g.Url, id))
 	if err != nil {
 		return nil, "", err
 	}
+	defer res.Body.Close()
 
-	if !res.Ok {
+	if res.StatusCode == http.StatusNotFound {
+		return nil, "", fmt.Errorf("%w: get raw data statuscode: %d  id: %s", schema.ErrNotFound, res.StatusCode, id)
+	}
+	if res.StatusCode != http.StatusOK {
 		return nil, "", fmt.Errorf("get raw data statuscode: %d  id: %s", res.StatusCode, id)
 	}
 
 	contentType = res.Header.Get("Content-Type")
-	data = res.Bytes()
-
+	data, err = io.ReadAll(res.Body)
 	return
 }
 
-func getNestBundle(nestBundle string, itemIds []string) (items []*types.BundleItem, err error) {
-	data, _, err := getRawById(nestBundle)
+func getNestBundle(gateways *Gateways, nestBundle string, itemIds []string) (items []*types.BundleItem, err error) {
+	data, _, err := getRawById(gateways, nestBundle)
 	if err != nil {
 		return nil, err
 	}
+	return decodeNestBundle(data, itemIds)
+}
+
+func decodeNestBundle(data []byte, itemIds []string) (items []*types.BundleItem, err error) {
 	bundle, err := utils.DecodeBundle(data)
 	if err != nil {
 		return nil, err
@@ -5,14 +5,23 @@ import (
 	"encoding/json"
 	"github.com/everFinance/arseeding/argraphql"
 	"github.com/everFinance/arseeding/schema"
+	"github.com/everFinance/goar"
+	"github.com/everFinance/goar/types"
 	"github.com/everFinance/goar/utils"
+	"github.com/everFinance/goether"
+	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"io"
 	"net/http"
+	"net/http/httptest"
+	"os"
 	"testing"
 )
 
 func Test_getRawById(t *testing.T) {
-	data, contentType, err := getRawById("U1FqvR_xTuL2qxrJDw20oIghpGt1eTumJ9ZfCczc5_M")
+	gateways, err := NewGateways(nil)
+	assert.NoError(t, err)
+	data, contentType, err := getRawById(gateways, "U1FqvR_xTuL2qxrJDw20oIghpGt1eTumJ9ZfCczc5_M")
 
 	if err != nil {
 		t.Error(err)
@@ -33,7 +42,9 @@ func TestNewS3Store(t *testing.T) {
 }
 
 func Test_getRawById1(t *testing.T) {
-	data, contentType, err := getRawById("AjV6oRKHh5PPI8Ehu9hIyWEz3oFAm5K0I0UYkxjwLdE")
+	gateways, err := NewGateways(nil)
+	assert.NoError(t, err)
+	data, contentType, err := getRawById(gateways, "AjV6oRKHh5PPI8Ehu9hIyWEz3oFAm5K0I0UYkxjwLdE")
 	assert.NoError(t, err)
 	t.Log(contentType)
 	bundle, err := utils.DecodeBundle(data)
@@ -44,7 +55,9 @@ func Test_getRawById1(t *testing.T) {
 
 func Test_getNestBundle(t *testing.T) {
 	itemIds := []string{"lfjl4f5joOhNT_VNDb7aaOqbwafgdXYkqXtAN3u0SUM"}
-	items, err := getNestBundle("mKg9fvDQ_qFZE2sSPm3fG01jfV5HoA1YnauXwuAwmlw", itemIds)
+	gateways, err := NewGateways(nil)
+	assert.NoError(t, err)
+	items, err := getNestBundle(gateways, "mKg9fvDQ_qFZE2sSPm3fG01jfV5HoA1YnauXwuAwmlw", itemIds)
 	assert.NoError(t, err)
 	assert.Equal(t, 1, len(items))
 	t.Log(items[0].Id)
@@ -59,3 +72,212 @@ func TestNewCache(t *testing.T) {
 	t.Log(res.Transaction.Data.Size)
 	t.Log(res.Transaction.Id)
 }
+
+func TestHandleManifest(t *testing.T) {
+	dbPath := "./data/tmp.db"
+	eccSigner, err := goether.NewSigner("4c3f9a1e5b234ce8f1ab58d82f849c0f70a4d5ceaf2b6e2d9a6c58b1f897ef0a")
+	assert.NoError(t, err)
+	itemSigner, err := goar.NewItemSigner(eccSigner)
+	assert.NoError(t, err)
+	s, err := NewBoltStore(dbPath)
+	assert.NoError(t, err)
+	defer os.RemoveAll(dbPath)
+
+	saveItem := func(data []byte, contentType string) string {
+		item, err := itemSigner.CreateAndSignItem(data, "", "", []types.Tag{{Name: schema.ContentType, Value: contentType}})
+		assert.NoError(t, err)
+		assert.NoError(t, s.AtomicSaveItem(item))
+		return item.Id
+	}
+	saveManifest := func(mani schema.ManifestData) []byte {
+		mani.Manifest = "arweave/paths"
+		data, err := json.Marshal(mani)
+		assert.NoError(t, err)
+		return data
+	}
+
+	indexId := saveItem([]byte("index"), "text/html")
+	appId := saveItem([]byte("app"), "application/javascript")
+	docId := saveItem([]byte("doc"), "text/plain")
+	nestedId := saveItem(saveManifest(schema.ManifestData{
+		Version: "0.1.0",
+		Index:   schema.IndexPath{Path: "a.txt"},
+		Paths:   map[string]schema.Resource{"a.txt": {TxId: docId}},
+	}), schema.ManifestType)
+	mani := schema.ManifestData{
+		Version: "0.2.0",
+		Index:   schema.IndexPath{Path: "index.html"},
+		Paths: map[string]schema.Resource{
+			"index.html":     {TxId: indexId},
+			"js/app.js":      {TxId: appId},
+			"docs":           {TxId: nestedId},
+			"nav/index.html": {TxId: indexId},
+		},
+	}
+
+	resolve := func(maniData []byte, path string) (string, error) {
+		_, _, dataReader, data, err := handleManifest(maniData, path, s)
+		if dataReader != nil {
+			data, err = io.ReadAll(dataReader)
+			dataReader.Close()
+		}
+		return string(data), err
+	}
+
+	for path, expected := range map[string]string{
+		"/":             "index",
+		"/js/app.js":    "app",
+		"/nav":          "index",
+		"/docs":         "doc",
+		"/docs/a.txt":   "doc",
+		"/not/exist":    "index",
+		"/docs/missing": "index",
+	} {
+		mani.Fallback = &schema.Resource{TxId: indexId}
+		data, err := resolve(saveManifest(mani), path)
+		assert.NoError(t, err, path)
+		assert.Equal(t, expected, data, path)
+	}
+
+	// no fallback
+	mani.Fallback = nil
+	_, err = resolve(saveManifest(mani), "/not/exist")
+	assert.Equal(t, schema.ErrPageNotFound, err)
+
+	// index id take precedence over path
+	mani.Index = schema.IndexPath{Path: "index.html", TxId: appId}
+	data, err := resolve(saveManifest(mani), "/")
+	assert.NoError(t, err)
+	assert.Equal(t, "app", data)
+
+	// nested too deep
+	id := docId
+	for i := 0; i < schema.MaxManifestDepth; i++ {
+		id = saveItem(saveManifest(schema.ManifestData{Paths: map[string]schema.Resource{"n": {TxId: id}}}), schema.ManifestType)
+	}
+	_, err = resolve(saveManifest(schema.ManifestData{Paths: map[string]schema.Resource{"n": {TxId: id}}}), "/n/n/n/n/n/n")
+	assert.Equal(t, schema.ErrManifestDepth, err)
+
+	// path response is streamed with range and short cache time
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/js/app.js", nil)
+	c.Request.Header.Set("Range", "bytes=1-")
+	manifestPathResponse(c, saveManifest(mani), s)
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, "pp", w.Body.String())
+	assert.Equal(t, dataEtag(appId), w.Header().Get("ETag"))
+	assert.Equal(t, schema.ManifestCacheControl, w.Header().Get("Cache-Control"))
+	assert.Equal(t, "application/javascript", w.Header().Get("Content-Type"))
+
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/not/exist", nil)
+	manifestPathResponse(c, saveManifest(mani), s)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestListManifest(t *testing.T) {
+	dbPath := "./data/tmp.db"
+	eccSigner, err := goether.NewSigner("4c3f9a1e5b234ce8f1ab58d82f849c0f70a4d5ceaf2b6e2d9a6c58b1f897ef0a")
+	assert.NoError(t, err)
+	itemSigner, err := goar.NewItemSigner(eccSigner)
+	assert.NoError(t, err)
+	s, err := NewBoltStore(dbPath)
+	assert.NoError(t, err)
+	defer os.RemoveAll(dbPath)
+
+	saveItem := func(data []byte, contentType string) string {
+		item, err := itemSigner.CreateAndSignItem(data, "", "", []types.Tag{{Name: schema.ContentType, Value: contentType}})
+		assert.NoError(t, err)
+		assert.NoError(t, s.AtomicSaveItem(item))
+		return item.Id
+	}
+	saveManifest := func(mani schema.ManifestData) []byte {
+		mani.Manifest = "arweave/paths"
+		data, err := json.Marshal(mani)
+		assert.NoError(t, err)
+		return data
+	}
+
+	docId := saveItem([]byte("doc"), "text/plain")
+	nestedId := saveItem(saveManifest(schema.ManifestData{
+		Version: "0.1.0",
+		Paths:   map[string]schema.Resource{"a.txt": {TxId: docId}},
+	}), schema.ManifestType)
+	maniData := saveManifest(schema.ManifestData{
+		Version: "0.1.0",
+		Paths: map[string]schema.Resource{
+			"js/app.js": {TxId: docId},
+			"docs":      {TxId: nestedId},
+			"remote":    {TxId: "cG7Hdi_iTQPoEYgQJFqJ8NMpN4KoZ-vH_j7pG4iP7NI"},
+		},
+	})
+	maniId := saveItem(maniData, schema.ManifestType)
+
+	entries, nextCursor, err := listManifestEntries(maniData, "", "", schema.ManifestListMaxNum, s)
+	assert.NoError(t, err)
+	assert.Equal(t, "", nextCursor)
+	assert.Equal(t, []schema.ManifestEntry{
+		{Path: "docs", Id: nestedId, Size: int64(len(saveManifest(schema.ManifestData{Version: "0.1.0", Paths: map[string]schema.Resource{"a.txt": {TxId: docId}}}))), ContentType: schema.ManifestType, Local: true, Manifest: true},
+		{Path: "docs/a.txt", Id: docId, Size: 3, ContentType: "text/plain", Local: true},
+		{Path: "js/app.js", Id: docId, Size: 3, ContentType: "text/plain", Local: true},
+		{Path: "remote", Id: "cG7Hdi_iTQPoEYgQJFqJ8NMpN4KoZ-vH_j7pG4iP7NI"},
+	}, entries)
+
+	// prefix in nested manifest
+	entries, _, err = listManifestEntries(maniData, "docs/", "", schema.ManifestListMaxNum, s)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(entries))
+	assert.Equal(t, "docs/a.txt", entries[0].Path)
+
+	// paged by path cursor
+	entries, nextCursor, err = listManifestEntries(maniData, "", "", 2, s)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(entries))
+	assert.Equal(t, "docs/a.txt", nextCursor)
+	entries, nextCursor, err = listManifestEntries(maniData, "", nextCursor, 2, s)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(entries))
+	assert.Equal(t, "js/app.js", entries[0].Path)
+	assert.Equal(t, "", nextCursor)
+
+	// tx meta without data is not local
+	chunks, err := utils.GenerateChunks([]byte("tx data"))
+	assert.NoError(t, err)
+	assert.NoError(t, s.SaveTxMeta(types.Transaction{ID: "tx-id", DataRoot: utils.Base64Encode(chunks.DataRoot), DataSize: "7"}))
+	assert.False(t, isDataLocal("tx-id", s))
+	assert.True(t, isDataLocal(docId, s))
+
+	gin.SetMode(gin.TestMode)
+	arseed := &Arseeding{store: s}
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Params = gin.Params{{Key: "id", Value: maniId}}
+	c.Request = httptest.NewRequest(http.MethodGet, "/manifest/"+maniId+"/ls?prefix=js", nil)
+	arseed.listManifest(c)
+	assert.Equal(t, http.StatusOK, w.Code)
+	resp := schema.RespManifestLs{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, maniId, resp.ManifestId)
+	assert.Equal(t, 1, len(resp.Entries))
+	assert.Equal(t, "js/app.js", resp.Entries[0].Path)
+
+	// not a manifest
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Params = gin.Params{{Key: "id", Value: docId}}
+	c.Request = httptest.NewRequest(http.MethodGet, "/manifest/"+docId+"/ls", nil)
+	arseed.listManifest(c)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// manifest without index show html index at root
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	manifestPathResponse(c, maniData, s)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), `<a href="/docs/a.txt">docs/a.txt</a>`)
+}
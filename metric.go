@@ -1,6 +1,7 @@
 package arseeding
 
 import (
+	"github.com/everFinance/arseeding/schema"
 	"github.com/prometheus/client_golang/prometheus"
 	"math/big"
 )
@@ -18,11 +19,61 @@ var (
 		},
 		[]string{"bundler", "token"},
 	)
+
+	readThroughCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: MetricNameSpace,
+			Name:      "read_through_count",
+			Help:      "fetch gateway data into local store",
+		},
+		[]string{"result"},
+	)
+
+	dataCorruptedCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: MetricNameSpace,
+			Name:      "data_corrupted_count",
+			Help:      "local data verify failed on read",
+		},
+		[]string{"type"},
+	)
+
+	dataRepairCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: MetricNameSpace,
+			Name:      "data_repair_count",
+			Help:      "re-fetch corrupted data from peers",
+		},
+		[]string{"result"},
+	)
+
+	bundleResubmitCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: MetricNameSpace,
+			Name:      "bundle_resubmit_count",
+			Help:      "resubmit stuck bundle arTx, alert if attempts of bundle exceed alertAttempts, stuck after maxAttempts or maxSpend",
+		},
+		[]string{"result"},
+	)
+
+	itemLostCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: MetricNameSpace,
+			Name:      "item_lost_count",
+			Help:      "bundle items skipped or not included in posted bundle",
+		},
+		[]string{"reason"},
+	)
 )
 
 func init() {
 	prometheus.MustRegister(
 		bundlerBalance,
+		readThroughCount,
+		dataCorruptedCount,
+		dataRepairCount,
+		bundleResubmitCount,
+		itemLostCount,
 	)
 }
 
@@ -30,3 +81,35 @@ func metricBundlerBalance(bal *big.Float, addr string) {
 	amount, _ := bal.Float64()
 	bundlerBalance.WithLabelValues(addr, "AR").Set(amount)
 }
+
+func metricReadThrough(err error) {
+	switch err {
+	case nil:
+		readThroughCount.WithLabelValues("stored").Inc()
+	case schema.ErrReadThroughTooBig:
+		readThroughCount.WithLabelValues("too_big").Inc()
+	default:
+		readThroughCount.WithLabelValues("failed").Inc()
+	}
+}
+
+func metricDataCorrupted(dataType string) {
+	dataCorruptedCount.WithLabelValues(dataType).Inc()
+}
+
+func metricDataRepair(err error) {
+	if err != nil {
+		dataRepairCount.WithLabelValues("failed").Inc()
+		return
+	}
+	dataRepairCount.WithLabelValues("repaired").Inc()
+}
+
+// metricBundleResubmit result: "resubmitted", "send_failed", "alert", "stuck"
+func metricBundleResubmit(result string) {
+	bundleResubmitCount.WithLabelValues(result).Inc()
+}
+
+func metricItemLost(reason string) {
+	itemLostCount.WithLabelValues(reason).Inc()
+}
@@ -1,6 +1,7 @@
 package arseeding
 
 import (
+	"crypto/subtle"
 	"encoding/base32"
 	"errors"
 	"fmt"
@@ -12,8 +13,8 @@ import (
 	"github.com/ulule/limiter/v3/drivers/store/memory"
 	"io"
 	"net/http"
-	"os"
 	"regexp"
+	"strconv"
 	"strings"
 )
 
@@ -55,6 +56,18 @@ func LimiterMiddleware(limit int, period string, ipRateWhitelist *map[string]str
 	return middleware
 }
 
+// AdminAuthMiddleware check the X-ADMIN-KEY header of admin api
+func AdminAuthMiddleware(adminKey string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("X-ADMIN-KEY")
+		if adminKey == "" || subtle.ConstantTimeCompare([]byte(key), []byte(adminKey)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, schema.RespErr{Err: "Wrong X-ADMIN-KEY"})
+			return
+		}
+		c.Next()
+	}
+}
+
 func CORSMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
@@ -75,12 +88,12 @@ func ManifestMiddleware(s *Arseeding) gin.HandlerFunc {
 
 	wdb := s.wdb
 	store := s.store
-	localCache := s.localCache
 
 	return func(c *gin.Context) {
 		prefixUri := getRequestSandbox(c.Request.Host)
 		// https://{{arId}}.arseed.web3infra.dev
-		if len(prefixUri) > 0 && c.Request.Method == "GET" {
+		isGet := c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead
+		if len(prefixUri) > 0 && isGet {
 			// compatible url https://xxxxxxx.arseed.web3infra.dev/{{arId}}
 			txId := getTxIdFromPath(c.Request.RequestURI)
 			if txId != "" && prefixUri == expectedTxSandbox(txId) {
@@ -110,7 +123,6 @@ func ManifestMiddleware(s *Arseeding) gin.HandlerFunc {
 			defer func() {
 				if dataReader != nil {
 					dataReader.Close()
-					os.Remove(dataReader.Name())
 				}
 			}()
 			if err != nil {
@@ -126,131 +138,91 @@ func ManifestMiddleware(s *Arseeding) gin.HandlerFunc {
 					return
 				}
 			}
-			tags, data, err := handleManifest(mfData, c.Request.URL.Path, store)
-			if err != nil {
-				c.Abort()
-				internalErrorResponse(c, err.Error())
-				return
-			}
-			c.Abort()
-			c.Data(http.StatusOK, fmt.Sprintf("%s; charset=utf-8", getTagValue(tags, schema.ContentType)), data)
+			manifestPathResponse(c, mfData, store)
 			return
 		}
 
 		//  Gateway  logic
 		currentHost := c.Request.Host
-		domain := getSubDomain(c.Request.Host)
-		// default  true
-		notApiHost := true
-		apiHostList := []string{
-			"seed-dev.everpay.io",
-			"arseed.web3infra.dev",
-			"arseed.web3infura.io",
-			"arseed-dev.web3infra.dev",
-			"arseed-dev.web3infura.io",
-			"web3infra.dev",
-			"web3infura.io",
-			"arweave.world",
-			"arweave.asia",
-			"localhost",
-			"127.0.0.1",
-		}
-
 		log.Debug("middleware", "currentHost", currentHost)
-		for _, b := range apiHostList {
-
-			if strings.Split(currentHost, ":")[0] == b {
-				// if current host in apiHostList, notApiHost is false
-				notApiHost = false
-				break
-			}
+		if !isGet || s.isApiHost(currentHost) {
+			c.Next()
+			return
 		}
 
-		// if domain is not empty and method is get and not in apiHostList
-		if len(domain) > 0 && c.Request.Method == "GET" && notApiHost {
-
-			txId := ""
-			keyPrefix := "txId_"
-
-			// get txId by localCache
-			value, err := localCache.Cache.Get(keyPrefix + domain)
-			if err != nil {
-				log.Info("middleware get localCache ", "error:", err)
-			}
-
-			if value != nil {
-				txId = string(value)
-			}
-
-			// if txId is empty, get txId by sdk
-			if txId == "" {
-				txId, err = s.arnsCli.QueryDomainTxId(domain)
-				if err != nil {
-					c.Abort()
-					internalErrorResponse(c, err.Error())
-					return
-				}
-
-				// set cache
-				err = localCache.Cache.Set(keyPrefix+domain, []byte(txId))
+		// custom domain managed by admin api
+		if txId, ok := s.customDomains.get(normalizeHost(currentHost)); ok {
+			log.Debug(fmt.Sprintf("custom domain: %s txId: %s", currentHost, txId))
+			s.permawebResponse(c, txId)
+			return
+		}
 
-				if err != nil {
-					log.Error("middleware set localCache", "error", err)
-				}
+		// name resolvers in order, e.g. ArNS name, dns TXT record
+		res, err := s.resolveName(currentHost)
+		if err != nil {
+			c.Abort()
+			if errors.Is(err, schema.ErrNameNotFound) {
+				notFoundResponse(c, err.Error())
+			} else {
+				internalErrorResponse(c, err.Error())
 			}
+			return
+		}
+		if res.ArNs != nil {
+			setArNsHeaders(c, res.ArNs)
+		}
 
-			log.Debug(fmt.Sprintf("permaweb domian: %s txId: %s", domain, txId))
-			decodeTags, dataReader, mfData, err := getArTxOrItemDataForManifest(txId, store, s)
-			defer func() {
-				if dataReader != nil {
-					dataReader.Close()
-					os.Remove(dataReader.Name())
-				}
-			}()
-
-			// if err equal to schema.ErrLocalNotExist, return 200 and "syncing data please wait some minutes and fresh the page"
-			if err == schema.ErrLocalNotExist {
-				c.Abort()
-				c.JSON(http.StatusOK, gin.H{
-					"msg": "syncing data please wait some minutes and fresh the page",
-				})
-				return
-			}
+		log.Debug(fmt.Sprintf("permaweb domian: %s resolver: %s txId: %s", currentHost, res.Resolver, res.TxId))
+		s.permawebResponse(c, res.TxId)
+	}
+}
 
-			if err != nil {
-				c.Abort()
-				internalErrorResponse(c, err.Error())
-				return
-			}
-			if dataReader != nil {
-				mfData, err = io.ReadAll(dataReader)
-				if err != nil {
-					c.Abort()
-					internalErrorResponse(c, err.Error())
-					return
-				}
-			}
+// permawebResponse serve the path of manifest, or the data if txId is not a manifest
+func (s *Arseeding) permawebResponse(c *gin.Context, txId string) {
+	c.Abort()
+	decodeTags, dataReader, mfData, err := getArTxOrItemDataForManifest(txId, s.store, s)
+	defer func() {
+		if dataReader != nil {
+			dataReader.Close()
+		}
+	}()
+
+	// if err equal to schema.ErrLocalNotExist, return 200 and "syncing data please wait some minutes and fresh the page"
+	if err == schema.ErrLocalNotExist {
+		c.JSON(http.StatusOK, gin.H{
+			"msg": "syncing data please wait some minutes and fresh the page",
+		})
+		return
+	}
 
-			// if content type is text/html, return mfData
-			if getTagValue(decodeTags, schema.ContentType) == "text/html" {
-				c.Abort()
-				c.Data(http.StatusOK, fmt.Sprintf("%s; charset=utf-8", getTagValue(decodeTags, schema.ContentType)), mfData)
-				return
-			}
+	if err != nil {
+		internalErrorResponse(c, err.Error())
+		return
+	}
 
-			tags, data, err := handleManifest(mfData, c.Request.URL.Path, store)
-			if err != nil {
-				c.Abort()
-				internalErrorResponse(c, err.Error())
-				return
-			}
-			c.Abort()
-			c.Data(http.StatusOK, fmt.Sprintf("%s; charset=utf-8", getTagValue(tags, schema.ContentType)), data)
+	// if content type is not manifest, e.g. text/html, return mfData
+	if getTagValue(decodeTags, schema.ContentType) != schema.ManifestType {
+		c.Header("Cache-Control", schema.ManifestCacheControl)
+		dataResponse(c, dataReader, mfData, decodeTags, txId)
+		dataReader = nil // closed by dataResponse
+		return
+	}
+	if dataReader != nil {
+		mfData, err = io.ReadAll(dataReader)
+		if err != nil {
+			internalErrorResponse(c, err.Error())
 			return
-
 		}
-		c.Next()
 	}
+
+	manifestPathResponse(c, mfData, s.store)
+}
+
+func setArNsHeaders(c *gin.Context, res *schema.ArNsResolved) {
+	c.Header("X-ArNS-Name", res.Name)
+	c.Header("X-ArNS-Resolved-Id", res.TxId)
+	c.Header("X-ArNS-TTL-Seconds", strconv.FormatInt(res.TtlSeconds, 10))
+	c.Header("X-ArNS-Process-Id", res.ProcessId)
 }
 
 func getTxIdFromPath(path string) string {
@@ -1,8 +1,18 @@
 package arseeding
 
 import (
+	"encoding/json"
+	"github.com/everFinance/arseeding/schema"
+	"github.com/everFinance/goar"
 	goarns "github.com/everFinance/goar/arns"
+	"github.com/everFinance/goar/types"
+	"github.com/everFinance/goether"
+	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
 	"testing"
 	"time"
 )
@@ -33,3 +43,84 @@ func TestLimiterMiddleware(t *testing.T) {
 	assert.NoError(t, err)
 	t.Log(txId)
 }
+
+func TestCustomDomain(t *testing.T) {
+	dbPath := "./data/tmp.db"
+	sqliteDir := "./data/sqlite"
+	defer os.RemoveAll(dbPath)
+	defer os.RemoveAll(sqliteDir)
+	store, err := NewBoltStore(dbPath)
+	assert.NoError(t, err)
+	wdb := NewSqliteDb(sqliteDir)
+	assert.NoError(t, wdb.Migrate(true, true))
+
+	eccSigner, err := goether.NewSigner("4c3f9a1e5b234ce8f1ab58d82f849c0f70a4d5ceaf2b6e2d9a6c58b1f897ef0a")
+	assert.NoError(t, err)
+	itemSigner, err := goar.NewItemSigner(eccSigner)
+	assert.NoError(t, err)
+	saveItem := func(data []byte, contentType string) string {
+		item, err := itemSigner.CreateAndSignItem(data, "", "", []types.Tag{{Name: schema.ContentType, Value: contentType}})
+		assert.NoError(t, err)
+		assert.NoError(t, store.AtomicSaveItem(item))
+		return item.Id
+	}
+	pageId := saveItem([]byte("page"), "text/html")
+	maniData, err := json.Marshal(schema.ManifestData{
+		Manifest: "arweave/paths",
+		Version:  "0.1.0",
+		Paths:    map[string]schema.Resource{"a.txt": {TxId: pageId}},
+	})
+	assert.NoError(t, err)
+	maniId := saveItem(maniData, schema.ManifestType)
+
+	gin.SetMode(gin.TestMode)
+	s := &Arseeding{store: store, wdb: wdb, EnableManifest: true, apiHosts: newApiHosts(nil), adminKey: "key"}
+	r := gin.New()
+	r.Use(ManifestMiddleware(s))
+	admin := r.Group("/admin", AdminAuthMiddleware(s.adminKey))
+	admin.GET("/domains", s.getCustomDomains)
+	admin.PUT("/domains/:domain", s.setCustomDomain)
+	admin.DELETE("/domains/:domain", s.delCustomDomain)
+
+	request := func(method, url, adminKey, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		if adminKey != "" {
+			req.Header.Set("X-ADMIN-KEY", adminKey)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request(http.MethodPut, "http://localhost:8080/admin/domains/Docs.Example.com", "", `{"targetId":"`+pageId+`"}`)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w = request(http.MethodPut, "http://localhost:8080/admin/domains/localhost", "key", `{"targetId":"`+pageId+`"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = request(http.MethodPut, "http://localhost:8080/admin/domains/Docs.Example.com", "key", `{"targetId":"invalid"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// data of custom domain
+	w = request(http.MethodPut, "http://localhost:8080/admin/domains/Docs.Example.com", "key", `{"targetId":"`+pageId+`"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = request(http.MethodGet, "http://docs.example.com:8080/", "", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "page", w.Body.String())
+
+	// update to manifest
+	w = request(http.MethodPut, "http://localhost:8080/admin/domains/docs.example.com", "key", `{"targetId":"`+maniId+`"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = request(http.MethodGet, "http://docs.example.com/a.txt", "", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "page", w.Body.String())
+
+	w = request(http.MethodGet, "http://localhost:8080/admin/domains", "key", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	list := make([]schema.CustomDomain, 0)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	assert.Equal(t, []schema.CustomDomain{{Domain: "docs.example.com", TargetId: maniId}}, list)
+
+	w = request(http.MethodDelete, "http://localhost:8080/admin/domains/docs.example.com", "key", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	_, ok := s.customDomains.get("docs.example.com")
+	assert.False(t, ok)
+}
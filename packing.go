@@ -0,0 +1,132 @@
+package arseeding
+
+import (
+	"github.com/everFinance/arseeding/schema"
+	"math"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+)
+
+// newBundlePolicy fill default values, a disabled policy on chain all orders every bundleInterval
+func newBundlePolicy(policy schema.BundlePolicy) schema.BundlePolicy {
+	if !policy.Enable {
+		return schema.BundlePolicy{MaxBundleSize: schema.MaxPerOnChainSize, Priority: schema.BundlePriorityFifo}
+	}
+	if policy.MaxBundleSize <= 0 || policy.MaxBundleSize > schema.MaxPerOnChainSize {
+		policy.MaxBundleSize = schema.MaxPerOnChainSize
+	}
+	if policy.MinFillSize > policy.MaxBundleSize {
+		policy.MinFillSize = policy.MaxBundleSize
+	}
+	if policy.MaxWait <= 0 {
+		policy.MaxWait = schema.DefaultBundleMaxWait
+	}
+	if policy.Priority == "" {
+		policy.Priority = schema.BundlePriorityFifo
+	}
+	return policy
+}
+
+// groupOrds split orders of the apikeys that need own bundles (own tags or pinned bundler) from others,
+// so that every bundle is tagged and posted for one apikey
+func groupOrds(ords []schema.Order, separate func(apiKey string) bool) [][]schema.Order {
+	groups := make([][]schema.Order, 0, 1)
+	groupIdx := make(map[string]int)
+	for _, ord := range ords {
+		key := ""
+		if ord.ApiKey != "" && separate(ord.ApiKey) {
+			key = ord.ApiKey
+		}
+		idx, ok := groupIdx[key]
+		if !ok {
+			idx = len(groups)
+			groupIdx[key] = idx
+			groups = append(groups, make([]schema.Order, 0))
+		}
+		groups[idx] = append(groups[idx], ord)
+	}
+	return groups
+}
+
+// planBundles sort orders by priority and pack them into bundles, return the bundles need to on chain now.
+// prices is USD price of token symbol, used by fee priority
+func planBundles(ords []schema.Order, policy schema.BundlePolicy, prices map[string]float64, now time.Time) [][]schema.Order {
+	ords = append([]schema.Order(nil), ords...)
+	feePerByte := make(map[uint]float64, len(ords))
+	if policy.Priority == schema.BundlePriorityFee {
+		for _, ord := range ords {
+			feePerByte[ord.ID] = orderFeePerByte(ord, prices)
+		}
+	}
+	sort.SliceStable(ords, func(i, j int) bool {
+		ti, tj := policy.ApiKeyTiers[ords[i].ApiKey], policy.ApiKeyTiers[ords[j].ApiKey]
+		if ti != tj {
+			return ti > tj
+		}
+		return feePerByte[ords[i].ID] > feePerByte[ords[j].ID]
+	})
+
+	bundles := make([][]schema.Order, 0)
+	cur, curSize := make([]schema.Order, 0), int64(0)
+	for _, ord := range ords {
+		if ord.Size > policy.MaxBundleSize {
+			// too big item is on chain alone
+			if ord.Size <= schema.MaxPerOnChainSize {
+				bundles = append(bundles, []schema.Order{ord})
+			}
+			continue
+		}
+		if len(cur) > 0 && (curSize+ord.Size > policy.MaxBundleSize || (policy.MaxItems > 0 && len(cur) >= policy.MaxItems)) {
+			bundles = append(bundles, cur)
+			cur, curSize = make([]schema.Order, 0), 0
+		}
+		cur = append(cur, ord)
+		curSize += ord.Size
+	}
+	if len(cur) == 0 {
+		return bundles
+	}
+
+	// the last bundle may be not filled, wait for more orders unless it waited too long
+	filled := curSize >= policy.MinFillSize || (policy.MaxItems > 0 && len(cur) >= policy.MaxItems)
+	aged := false
+	for _, ord := range cur {
+		if policy.MaxWait > 0 && now.Sub(ord.CreatedAt) >= time.Duration(policy.MaxWait)*time.Second {
+			aged = true
+			break
+		}
+	}
+	if filled || aged {
+		bundles = append(bundles, cur)
+	}
+	return bundles
+}
+
+// orderFeePerByte return fee(USD) per byte of order, 0 if price of currency is unknown
+func orderFeePerByte(ord schema.Order, prices map[string]float64) float64 {
+	price := prices[strings.ToUpper(ord.Currency)]
+	fee, ok := new(big.Float).SetString(ord.Fee)
+	if !ok || price <= 0 || ord.Size <= 0 {
+		return 0
+	}
+	f, _ := fee.Float64()
+	return f / math.Pow10(ord.Decimals) * price / float64(ord.Size)
+}
+
+func (s *Arseeding) tokenPrices() map[string]float64 {
+	prices := make(map[string]float64)
+	if s.bundlePolicy.Priority != schema.BundlePriorityFee || s.NoFee {
+		return prices
+	}
+	tps, err := s.wdb.GetPrices()
+	if err != nil {
+		log.Error("s.wdb.GetPrices()", "err", err)
+		return prices
+	}
+	for _, tp := range tps {
+		prices[strings.ToUpper(tp.Symbol)] = tp.Price
+	}
+	return prices
+}
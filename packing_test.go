@@ -0,0 +1,52 @@
+package arseeding
+
+import (
+	"github.com/everFinance/arseeding/schema"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestPlanBundles(t *testing.T) {
+	now := time.Now()
+	ord := func(id uint, size int64, age time.Duration) schema.Order {
+		return schema.Order{ID: id, ItemId: string(rune('a' + id)), Size: size, CreatedAt: now.Add(-age), Currency: "AR", Decimals: 12, Fee: "1000000000000"}
+	}
+	ids := func(bundles [][]schema.Order) [][]uint {
+		res := make([][]uint, 0, len(bundles))
+		for _, b := range bundles {
+			bIds := make([]uint, 0, len(b))
+			for _, o := range b {
+				bIds = append(bIds, o.ID)
+			}
+			res = append(res, bIds)
+		}
+		return res
+	}
+
+	// disabled policy on chain all orders
+	ords := []schema.Order{ord(1, 10, 0), ord(2, 20, 0), ord(3, schema.MaxPerOnChainSize+1, 0)}
+	assert.Equal(t, [][]uint{{1, 2}}, ids(planBundles(ords, newBundlePolicy(schema.BundlePolicy{}), nil, now)))
+
+	policy := newBundlePolicy(schema.BundlePolicy{Enable: true, MaxBundleSize: 100, MaxItems: 3, MinFillSize: 50, MaxWait: 60})
+	assert.Equal(t, schema.BundlePriorityFifo, policy.Priority)
+
+	// big queue is split into filled bundles, the small tail wait for more orders
+	ords = []schema.Order{ord(1, 60, 0), ord(2, 50, 0), ord(3, 10, 0), ord(4, 10, 0), ord(5, 10, 0), ord(6, 200, 0), ord(7, 10, 0)}
+	assert.Equal(t, [][]uint{{1}, {2, 3, 4}, {6}}, ids(planBundles(ords, policy, nil, now)))
+
+	// the tail waited too long
+	ords[6] = ord(7, 10, 2*time.Minute)
+	assert.Equal(t, [][]uint{{1}, {2, 3, 4}, {6}, {5, 7}}, ids(planBundles(ords, policy, nil, now)))
+
+	// fee priority and apikey tier
+	policy.Priority = schema.BundlePriorityFee
+	policy.ApiKeyTiers = map[string]int{"vip": 1}
+	cheap, dear, vip := ord(1, 10, 0), ord(2, 10, 0), ord(3, 40, 0)
+	dear.Fee = "5000000000000"
+	vip.ApiKey = "vip"
+	bundles := planBundles([]schema.Order{cheap, dear, vip}, policy, map[string]float64{"AR": 10}, now)
+	assert.Equal(t, [][]uint{{3, 2, 1}}, ids(bundles))
+	assert.InDelta(t, 5.0, orderFeePerByte(dear, map[string]float64{"AR": 10}), 1e-9)
+	assert.Equal(t, 0.0, orderFeePerByte(dear, nil))
+}
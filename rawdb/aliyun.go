@@ -6,8 +6,9 @@ import (
 	"github.com/aliyun/aliyun-oss-go-sdk/oss"
 	"github.com/everFinance/arseeding/schema"
 	"io"
-	"os"
+	"net/http"
 	"reflect"
+	"strconv"
 )
 
 // refer https://help.aliyun.com/document_detail/32157.html?spm=a2c4g.11186623.0.0.1a4b32bcxaC4kR
@@ -78,8 +79,27 @@ func (a *AliyunDB) Get(bucket, key string) (data []byte, err error) {
 	return
 }
 
-func (a *AliyunDB) GetStream(bucket, key string) (data *os.File, err error) {
-	return nil, schema.ErrNotImplement
+// GetStream read the object by ranged GETs, nothing is spooled to local disk
+func (a *AliyunDB) GetStream(bucket, key string) (data io.ReadSeekCloser, err error) {
+	bkt, err := a.client.Bucket(getS3Bucket(a.bucketPrefix, bucket))
+	if err != nil {
+		return
+	}
+	meta, err := bkt.GetObjectDetailedMeta(key)
+	if err != nil {
+		// HEAD response has no error body, so check status code
+		if ossErr, ok := err.(oss.ServiceError); ok && ossErr.StatusCode == http.StatusNotFound {
+			return nil, schema.ErrNotExist
+		}
+		return nil, err
+	}
+	size, err := strconv.ParseInt(meta.Get(oss.HTTPHeaderContentLength), 10, 64)
+	if err != nil {
+		return
+	}
+	return newRangeReader(size, func(offset int64) (io.ReadCloser, error) {
+		return bkt.GetObject(key, oss.NormalizedRange(fmt.Sprintf("%d-", offset)))
+	}), nil
 }
 
 func (a *AliyunDB) GetAllKey(bucket string) (keys []string, err error) {
@@ -152,6 +172,10 @@ func createAliyunBucket(svc *oss.Client, prefix string) error {
 		schema.BundleWaitParseArIdBucket,
 		schema.BundleArIdToItemIdsBucket,
 		schema.StatisticBucket,
+		schema.TxIndexRecordBucket,
+		schema.TxIndexOwnerBucket,
+		schema.TxIndexTagBucket,
+		schema.TxIndexTargetBucket,
 	}
 
 	ownBuckets, err := getBucketWithPrefix(svc, prefix)
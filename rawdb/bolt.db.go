@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/everFinance/arseeding/schema"
 	bolt "go.etcd.io/bbolt"
+	"io"
 	"os"
 	"path"
 	"reflect"
@@ -53,6 +54,10 @@ func NewBoltDB(boltDirPath string) (*BoltDB, error) {
 			schema.BundleWaitParseArIdBucket,
 			schema.BundleArIdToItemIdsBucket,
 			schema.StatisticBucket,
+			schema.TxIndexRecordBucket,
+			schema.TxIndexOwnerBucket,
+			schema.TxIndexTagBucket,
+			schema.TxIndexTargetBucket,
 		}
 		return createBuckets(tx, bucketNames)
 	}); err != nil {
@@ -87,8 +92,18 @@ func (s *BoltDB) Get(bucket, key string) (data []byte, err error) {
 	return
 }
 
-func (s *BoltDB) GetStream(bucket, key string) (data *os.File, err error) {
-	return nil, schema.ErrNotImplement
+// GetStream the value is copied out of mmap in the read tx, because a long-lived read tx blocks the remap of db file growth,
+// and all writes that grow the db are stalled until the reader is closed
+func (s *BoltDB) GetStream(bucket, key string) (data io.ReadSeekCloser, err error) {
+	err = s.Db.View(func(tx *bolt.Tx) error {
+		val := tx.Bucket([]byte(bucket)).Get([]byte(key))
+		if val == nil {
+			return schema.ErrNotExist
+		}
+		data = newBytesReader(append([]byte(nil), val...), nil)
+		return nil
+	})
+	return
 }
 
 func (s *BoltDB) GetAllKey(bucket string) (keys []string, err error) {
@@ -2,7 +2,7 @@ package rawdb
 
 import (
 	"github.com/everFinance/go-everpay/common"
-	"os"
+	"io"
 )
 
 var log = common.NewLog("arseeding")
@@ -12,7 +12,7 @@ type KeyValueDB interface {
 
 	Get(bucket, key string) (data []byte, err error)
 
-	GetStream(bucket, key string) (data *os.File, err error)
+	GetStream(bucket, key string) (data io.ReadSeekCloser, err error)
 
 	GetAllKey(bucket string) (keys []string, err error)
 
@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"github.com/everFinance/arseeding/schema"
 	"github.com/stretchr/testify/assert"
+	"io"
 	"sort"
+	"strings"
 	"testing"
 )
 
@@ -46,6 +48,25 @@ func TestBoltDB(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, keys, allKeys)
 
+	// test GetStream
+	stream, err := boltDb.GetStream(bktName, keys[0])
+	assert.NoError(t, err)
+	val, err := io.ReadAll(stream)
+	assert.NoError(t, err)
+	assert.Equal(t, values[0], val)
+	assert.NoError(t, stream.Close())
+	_, err = boltDb.GetStream(bktName, "not-exist")
+	assert.Equal(t, schema.ErrNotExist, err)
+	// open stream does not block the db growth
+	stream, err = boltDb.GetStream(bktName, keys[0])
+	assert.NoError(t, err)
+	assert.NoError(t, boltDb.Put(bktName, "big", make([]byte, 16*1024*1024)))
+	val, err = io.ReadAll(stream)
+	assert.NoError(t, err)
+	assert.Equal(t, values[0], val)
+	assert.NoError(t, stream.Close())
+	assert.NoError(t, boltDb.Delete(bktName, "big"))
+
 	// test Delete
 	for i := 0; i < keyNum; i++ {
 		err = boltDb.Delete(bktName, keys[i])
@@ -57,6 +78,32 @@ func TestBoltDB(t *testing.T) {
 	}
 }
 
+func TestRangeReader(t *testing.T) {
+	data := "0123456789"
+	opened := 0
+	r := newRangeReader(int64(len(data)), func(offset int64) (io.ReadCloser, error) {
+		opened++
+		return io.NopCloser(strings.NewReader(data[offset:])), nil
+	})
+	buf := make([]byte, 3)
+	_, err := io.ReadFull(r, buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "012", string(buf))
+	_, err = io.ReadFull(r, buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "345", string(buf))
+	assert.Equal(t, 1, opened)
+
+	pos, err := r.Seek(-2, io.SeekEnd)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(8), pos)
+	rest, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "89", string(rest))
+	assert.Equal(t, 2, opened)
+	assert.NoError(t, r.Close())
+}
+
 // func TestS3DB(t *testing.T) {
 //
 // 	bktName := schema.ConstantsBucket // cne be replaced by any bucket in schema
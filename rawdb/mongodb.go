@@ -1,14 +1,16 @@
 package rawdb
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"github.com/everFinance/arseeding/schema"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
 	"go.mongodb.org/mongo-driver/mongo/options"
-	"os"
+	"io"
 	"reflect"
 )
 
@@ -46,6 +48,9 @@ func NewMongoDB(ctx context.Context, uri string) (*MongoDB, error) {
 }
 
 func (m *MongoDB) Put(bucket, key string, value interface{}) (err error) {
+	if reader, ok := value.(io.Reader); ok {
+		return m.putGridFS(bucket, key, reader)
+	}
 	if _, ok := value.([]byte); !ok {
 		return fmt.Errorf("unknown data type: %s, db: MongoDB", reflect.TypeOf(value))
 	}
@@ -53,7 +58,7 @@ func (m *MongoDB) Put(bucket, key string, value interface{}) (err error) {
 		ID:    key,
 		Value: value,
 	}
-	if m.Exist(bucket, key) {
+	if m.existDoc(bucket, key) {
 		filter := bson.D{{K, key}}
 		update := bson.D{
 			{"$set", bson.D{{V, value}}},
@@ -71,7 +76,17 @@ func (m *MongoDB) Get(bucket, key string) (data []byte, err error) {
 	doc := document{}
 	filter := bson.D{{K, key}}
 	err = m.database.Collection(bucket).FindOne(m.ctx, filter).Decode(&doc)
+	if err == mongo.ErrNoDocuments && m.existGridFS(bucket, key) {
+		buf := new(bytes.Buffer)
+		if _, err = m.gridFSBucket(bucket).DownloadToStream(key, buf); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
 	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			err = schema.ErrNotExist
+		}
 		return nil, err
 	}
 	return doc.Value.(primitive.Binary).Data, nil
@@ -96,7 +111,12 @@ func (m *MongoDB) GetAllKey(bucket string) (keys []string, err error) {
 
 func (m *MongoDB) Delete(bucket, key string) (err error) {
 	filter := bson.D{{K, key}}
-	_, err = m.database.Collection(bucket).DeleteMany(m.ctx, filter)
+	if _, err = m.database.Collection(bucket).DeleteMany(m.ctx, filter); err != nil {
+		return err
+	}
+	if err = m.gridFSBucket(bucket).Delete(key); err == gridfs.ErrFileNotFound {
+		err = nil
+	}
 	return err
 }
 
@@ -109,12 +129,65 @@ func (m *MongoDB) Type() string {
 }
 
 func (m *MongoDB) Exist(bucket, key string) bool {
+	return m.existDoc(bucket, key) || m.existGridFS(bucket, key)
+}
+
+func (m *MongoDB) existDoc(bucket, key string) bool {
 	filter := bson.D{{K, key}}
 	err := m.database.Collection(bucket).FindOne(m.ctx, filter).Decode(&document{})
 	//err == mongo.ErrNoDocuments {
 	return err == nil
 }
 
-func (m *MongoDB) GetStream(bucket, key string) (data *os.File, err error) {
-	return nil, schema.ErrNotImplement
+// GetStream values put by io.Reader are stored in GridFS and read by chunks, others are already in memory
+func (m *MongoDB) GetStream(bucket, key string) (data io.ReadSeekCloser, err error) {
+	doc := document{}
+	err = m.database.Collection(bucket).FindOne(m.ctx, bson.D{{K, key}}).Decode(&doc)
+	if err == nil {
+		return newBytesReader(doc.Value.(primitive.Binary).Data, nil), nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+
+	gfs := m.gridFSBucket(bucket)
+	stream, err := gfs.OpenDownloadStream(key)
+	if err != nil {
+		if err == gridfs.ErrFileNotFound {
+			err = schema.ErrNotExist
+		}
+		return nil, err
+	}
+	size := stream.GetFile().Length
+	stream.Close()
+	return newRangeReader(size, func(offset int64) (io.ReadCloser, error) {
+		stream, err := gfs.OpenDownloadStream(key)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = stream.Skip(offset); err != nil {
+			stream.Close()
+			return nil, err
+		}
+		return stream, nil
+	}), nil
+}
+
+// gridFSBucket GridFS collections are named bucket.files and bucket.chunks
+func (m *MongoDB) gridFSBucket(bucket string) *gridfs.Bucket {
+	gfs, _ := gridfs.NewBucket(m.database, options.GridFSBucket().SetName(bucket)) // only return error when options conflict
+	return gfs
+}
+
+func (m *MongoDB) putGridFS(bucket, key string, reader io.Reader) error {
+	gfs := m.gridFSBucket(bucket)
+	if err := gfs.Delete(key); err != nil && err != gridfs.ErrFileNotFound {
+		return err
+	}
+	return gfs.UploadFromStreamWithID(key, key, reader)
+}
+
+func (m *MongoDB) existGridFS(bucket, key string) bool {
+	err := m.gridFSBucket(bucket).GetFilesCollection().FindOne(m.ctx, bson.D{{K, key}}).Err()
+	return err == nil
 }
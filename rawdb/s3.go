@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
@@ -12,8 +13,8 @@ import (
 	"github.com/everFinance/arseeding/schema"
 	"io"
 	"net"
+	"net/http"
 	"net/url"
-	"os"
 	"reflect"
 	"strings"
 )
@@ -99,24 +100,30 @@ func (s *S3DB) Get(bucket, key string) (data []byte, err error) {
 	return
 }
 
-func (s *S3DB) GetStream(bucket, key string) (data *os.File, err error) {
+// GetStream read the object by ranged GETs, nothing is spooled to local disk
+func (s *S3DB) GetStream(bucket, key string) (data io.ReadSeekCloser, err error) {
 	bkt := getS3Bucket(s.bucketPrefix, bucket)
-	downloadInfo := &s3.GetObjectInput{
+	head, err := s.s3Api.HeadObject(&s3.HeadObjectInput{
 		Bucket: aws.String(bkt),
 		Key:    aws.String(key),
-	}
-	data, err = os.CreateTemp("./tmpFile", "s3-")
+	})
 	if err != nil {
-		return
-	}
-
-	n, err := s.downloader.Download(data, downloadInfo)
-	if n == 0 { // if key not exist, need delete temp file
-		data.Close()
-		os.Remove(data.Name())
-		return nil, schema.ErrNotExist
+		if reqErr, ok := err.(awserr.RequestFailure); ok && reqErr.StatusCode() == http.StatusNotFound {
+			return nil, schema.ErrNotExist
+		}
+		return nil, err
 	}
-	return
+	return newRangeReader(aws.Int64Value(head.ContentLength), func(offset int64) (io.ReadCloser, error) {
+		out, err := s.s3Api.GetObject(&s3.GetObjectInput{
+			Bucket: aws.String(bkt),
+			Key:    aws.String(key),
+			Range:  aws.String(fmt.Sprintf("bytes=%d-", offset)),
+		})
+		if err != nil {
+			return nil, err
+		}
+		return out.Body, nil
+	}), nil
 }
 
 func (s *S3DB) GetAllKey(bucket string) (keys []string, err error) {
@@ -167,6 +174,10 @@ func createS3Bucket(svc s3iface.S3API, prefix string) error {
 		schema.BundleWaitParseArIdBucket,
 		schema.BundleArIdToItemIdsBucket,
 		schema.StatisticBucket,
+		schema.TxIndexRecordBucket,
+		schema.TxIndexOwnerBucket,
+		schema.TxIndexTagBucket,
+		schema.TxIndexTargetBucket,
 	}
 	for _, bucketName := range bucketNames {
 		s3Bkt := getS3Bucket(prefix, bucketName) // s3 bucket name only accept lower case
@@ -0,0 +1,85 @@
+package rawdb
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+// rangeReader is a io.ReadSeekCloser over an object of remote storage,
+// the object body is opened from current offset at the first Read after Seek, so only the read range is transferred.
+type rangeReader struct {
+	size   int64
+	offset int64
+	open   func(offset int64) (io.ReadCloser, error)
+	body   io.ReadCloser
+}
+
+func newRangeReader(size int64, open func(offset int64) (io.ReadCloser, error)) *rangeReader {
+	return &rangeReader{size: size, open: open}
+}
+
+func (r *rangeReader) Read(p []byte) (n int, err error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+	if r.body == nil {
+		if r.body, err = r.open(r.offset); err != nil {
+			r.body = nil
+			return 0, err
+		}
+	}
+	n, err = r.body.Read(p)
+	r.offset += int64(n)
+	if err == io.EOF && r.offset < r.size {
+		err = io.ErrUnexpectedEOF
+	}
+	return
+}
+
+func (r *rangeReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, errors.New("rangeReader.Seek: invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("rangeReader.Seek: negative position")
+	}
+	if offset != r.offset && r.body != nil {
+		r.body.Close()
+		r.body = nil
+	}
+	r.offset = offset
+	return offset, nil
+}
+
+func (r *rangeReader) Close() error {
+	if r.body == nil {
+		return nil
+	}
+	err := r.body.Close()
+	r.body = nil
+	return err
+}
+
+// bytesReader wrap the value that already in memory
+type bytesReader struct {
+	*bytes.Reader
+	close func() error
+}
+
+func newBytesReader(data []byte, close func() error) *bytesReader {
+	return &bytesReader{Reader: bytes.NewReader(data), close: close}
+}
+
+func (b *bytesReader) Close() error {
+	if b.close == nil {
+		return nil
+	}
+	return b.close()
+}
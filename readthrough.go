@@ -0,0 +1,234 @@
+package arseeding
+
+import (
+	"context"
+	"fmt"
+	"github.com/everFinance/arseeding/argraphql"
+	"github.com/everFinance/arseeding/schema"
+	"github.com/everFinance/goar/types"
+	"github.com/everFinance/goar/utils"
+	"github.com/gin-gonic/gin"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ReadThrough persist upstream gateway data into local store after it was requested enough times,
+// so hot content becomes local and not be fetched from arweave.net again and again
+type ReadThrough struct {
+	maxDataSize int64
+	minHits     int
+	hitWindow   time.Duration
+
+	hits     map[string]*readHits // key: arId or itemId
+	fetching map[string]struct{}
+	lock     sync.Mutex
+}
+
+type readHits struct {
+	count int
+	start time.Time
+}
+
+func NewReadThrough(cfg schema.ReadThrough) *ReadThrough {
+	if !cfg.Enable {
+		return nil
+	}
+	r := &ReadThrough{
+		maxDataSize: cfg.MaxDataSize,
+		minHits:     cfg.MinHits,
+		hitWindow:   time.Duration(cfg.HitWindow) * time.Second,
+		hits:        make(map[string]*readHits),
+		fetching:    make(map[string]struct{}),
+	}
+	if r.maxDataSize <= 0 {
+		r.maxDataSize = schema.DefaultReadThroughMaxDataSize
+	}
+	if r.minHits <= 0 {
+		r.minHits = schema.DefaultReadThroughMinHits
+	}
+	if r.hitWindow <= 0 {
+		r.hitWindow = schema.DefaultReadThroughHitWindow * time.Second
+	}
+	return r
+}
+
+// Admit record a hit of id, return true if the id should be fetched into local store now
+func (r *ReadThrough) Admit(id string) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if _, ok := r.fetching[id]; ok {
+		return false
+	}
+	now := time.Now()
+	h, ok := r.hits[id]
+	if !ok || now.Sub(h.start) > r.hitWindow {
+		if !ok && len(r.hits) >= schema.ReadThroughMaxTrackedIds {
+			r.expireHits(now)
+			if len(r.hits) >= schema.ReadThroughMaxTrackedIds {
+				return false
+			}
+		}
+		h = &readHits{start: now}
+		r.hits[id] = h
+	}
+	h.count++
+	if h.count < r.minHits {
+		return false
+	}
+	delete(r.hits, id)
+	r.fetching[id] = struct{}{}
+	return true
+}
+
+// Done must be called after the admitted id fetch finished
+func (r *ReadThrough) Done(id string) {
+	r.lock.Lock()
+	delete(r.fetching, id)
+	r.lock.Unlock()
+}
+
+func (r *ReadThrough) expireHits(now time.Time) {
+	for id, h := range r.hits {
+		if now.Sub(h.start) > r.hitWindow {
+			delete(r.hits, id)
+		}
+	}
+}
+
+// proxyAndReadThrough proxy the request to arweave gateway, and fetch the data into local store in background if admitted
+func (s *Arseeding) proxyAndReadThrough(c *gin.Context, id string) {
+	if s.readThrough != nil && s.readThrough.Admit(id) {
+		go func() {
+			defer s.readThrough.Done(id)
+			err := s.readThroughData(id)
+			metricReadThrough(err)
+			if err != nil {
+				log.Warn("read through data failed", "err", err, "id", id)
+			}
+		}()
+	}
+	s.proxyArweaveGateway(c)
+}
+
+// readThroughData fetch arTx or bundle item from arweave gateway, verify and save it to local store
+func (s *Arseeding) readThroughData(id string) error {
+	if s.store.IsExistItemBinary(id) || s.store.IsExistTxMeta(id) {
+		return nil
+	}
+	var res *argraphql.GetTransactionResponse
+	err := s.gateways.Do(func(g *Gateway) (err error) {
+		res, err = g.GraphQL().QueryTransaction(context.Background(), id)
+		return
+	})
+	if err != nil {
+		return err
+	}
+	if res.Transaction.Id != id {
+		return schema.ErrNotExist
+	}
+	size, err := strconv.ParseInt(res.Transaction.Data.Size, 10, 64)
+	if err != nil {
+		return err
+	}
+	if size > s.readThrough.maxDataSize {
+		return schema.ErrReadThroughTooBig
+	}
+
+	if res.Transaction.BundledIn.Id == "" {
+		return s.readThroughTx(id)
+	}
+	return s.readThroughItem(id, res.Transaction.BundledIn.Id)
+}
+
+func (s *Arseeding) readThroughTx(arId string) error {
+	arTx, err := s.arCli.GetTransactionByID(arId)
+	if err != nil {
+		return err
+	}
+	if arTx.ID != arId {
+		return schema.ErrReadThroughInvalid
+	}
+	if err = utils.VerifyTransaction(*arTx); err != nil {
+		return fmt.Errorf("%v: %v", schema.ErrReadThroughInvalid, err)
+	}
+	size, err := strconv.ParseInt(arTx.DataSize, 10, 64)
+	if err != nil {
+		return err
+	}
+	if size > s.readThrough.maxDataSize {
+		return schema.ErrReadThroughTooBig
+	}
+
+	// data must be stored before txMeta, because local txMeta means local data exist
+	if size > 0 {
+		if err = s.syncAddTxDataEndOffset(arTx.DataRoot, arTx.DataSize); err != nil {
+			return err
+		}
+		data, err := s.arCli.GetTransactionDataByGateway(arId)
+		if err != nil {
+			return err
+		}
+		if int64(len(data)) != size {
+			return schema.ErrReadThroughInvalid
+		}
+		// chunks data_root is checked with arTx.DataRoot
+		if err = setTxDataChunks(*arTx, data, s.store); err != nil {
+			return err
+		}
+	}
+	arTx.Data = ""
+	if err = s.store.SaveTxMeta(*arTx); err != nil {
+		return err
+	}
+	if err = s.store.SaveTxIndex(*arTx); err != nil {
+		log.Error("s.store.SaveTxIndex(arTx)", "err", err, "arTx", arId)
+	}
+	return nil
+}
+
+func (s *Arseeding) readThroughItem(itemId, bundleId string) error {
+	items, err := getBundleItemsFromGateway(s.gateways, bundleId, []string{itemId})
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		if item.Id != itemId {
+			continue
+		}
+		if err = utils.VerifyBundleItem(*item); err != nil {
+			return fmt.Errorf("%v: %v", schema.ErrReadThroughInvalid, err)
+		}
+		if err = s.saveItem(*item); err != nil {
+			return err
+		}
+		return s.store.SaveItemsBundledIn(bundleId, []string{itemId})
+	}
+	return schema.ErrNotExist
+}
+
+// getBundleItemsFromGateway get items by chunks of bundle, nested bundle is downloaded and decoded
+func getBundleItemsFromGateway(gateways *Gateways, bundleId string, itemIds []string) (items []*types.BundleItem, err error) {
+	err = gateways.Do(func(g *Gateway) error {
+		isNestBundle, dataSize, err := checkNestBundle(bundleId, g.GraphQL())
+		if err != nil || !isNestBundle {
+			items, err = g.getBundleItems(bundleId, itemIds)
+			if err != nil {
+				return fmt.Errorf("GetBundleItems error: %v", err)
+			}
+			return nil
+		}
+		log.Debug("nestBundle dataSize...", "size", dataSize)
+		data, _, err := g.getRaw(bundleId)
+		if err != nil {
+			return fmt.Errorf("getNestBundle error: %w", err)
+		}
+		items, err = decodeNestBundle(data, itemIds)
+		if err != nil {
+			return fmt.Errorf("getNestBundle error: %v", err)
+		}
+		return nil
+	})
+	return
+}
@@ -0,0 +1,31 @@
+package arseeding
+
+import (
+	"github.com/everFinance/arseeding/schema"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestReadThroughAdmit(t *testing.T) {
+	assert.Nil(t, NewReadThrough(schema.ReadThrough{}))
+
+	r := NewReadThrough(schema.ReadThrough{Enable: true, MinHits: 2, HitWindow: 60})
+	assert.Equal(t, int64(schema.DefaultReadThroughMaxDataSize), r.maxDataSize)
+
+	assert.False(t, r.Admit("id-1"))
+	assert.True(t, r.Admit("id-1"))
+	// fetching, not admit again
+	assert.False(t, r.Admit("id-1"))
+	assert.False(t, r.Admit("id-1"))
+	r.Done("id-1")
+	assert.False(t, r.Admit("id-1"))
+	assert.True(t, r.Admit("id-1"))
+	r.Done("id-1")
+
+	// hits out of window are not counted
+	assert.False(t, r.Admit("id-2"))
+	r.hits["id-2"].start = time.Now().Add(-2 * time.Minute)
+	assert.False(t, r.Admit("id-2"))
+	assert.True(t, r.Admit("id-2"))
+}
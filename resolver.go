@@ -0,0 +1,201 @@
+package arseeding
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/everFinance/arseeding/schema"
+	"github.com/everFinance/goar/utils"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NameResolver resolve request host to the id of manifest or data,
+// schema.ErrNameNotFound is returned if the resolver has no record of the host
+type NameResolver interface {
+	Resolve(host string) (*schema.ResolvedName, error)
+}
+
+func newNameResolvers(names []string, arns *ArNs, dnsAddr string) ([]NameResolver, error) {
+	if len(names) == 0 {
+		names = []string{schema.NameResolverArNs}
+	}
+	resolvers := make([]NameResolver, 0, len(names))
+	for _, name := range names {
+		switch strings.ToLower(name) {
+		case schema.NameResolverArNs:
+			resolvers = append(resolvers, &ArNsResolver{arns: arns})
+		case schema.NameResolverDns:
+			resolvers = append(resolvers, NewDnsResolver(dnsAddr))
+		default:
+			return nil, fmt.Errorf("unknown name resolver: %s", name)
+		}
+	}
+	return resolvers, nil
+}
+
+// resolveName consult name resolvers in order, the first resolved is returned
+func (s *Arseeding) resolveName(host string) (*schema.ResolvedName, error) {
+	err := schema.ErrNameNotFound
+	for _, r := range s.nameResolvers {
+		res, e := r.Resolve(host)
+		if e == nil {
+			return res, nil
+		}
+		if !errors.Is(e, schema.ErrNameNotFound) {
+			log.Warn("resolve name failed", "host", host, "err", e)
+			err = e
+		}
+	}
+	return nil, err
+}
+
+// ArNsResolver resolve the sub domain of host as ArNS name, e.g. docs_ardrive.arweave.world
+type ArNsResolver struct {
+	arns *ArNs
+}
+
+func (r *ArNsResolver) Resolve(host string) (*schema.ResolvedName, error) {
+	res, err := r.arns.Resolve(getSubDomain(normalizeHost(host)))
+	if err != nil {
+		if errors.Is(err, schema.ErrArNsNotFound) {
+			return nil, schema.ErrNameNotFound
+		}
+		return nil, err
+	}
+	return &schema.ResolvedName{
+		Resolver:   schema.NameResolverArNs,
+		Name:       res.Name,
+		TxId:       res.TxId,
+		TtlSeconds: res.TtlSeconds,
+		ArNs:       res,
+	}, nil
+}
+
+// DnsResolver resolve host by TXT record of _arweave.<host>, DNSLink style "dnslink=/arweave/<id>" or "<id>"
+type DnsResolver struct {
+	resolver *net.Resolver
+	cache    *ttlCache
+}
+
+// NewDnsResolver use the dns server of addr(host:port), system resolver is used if addr is empty
+func NewDnsResolver(addr string) *DnsResolver {
+	resolver := net.DefaultResolver
+	if addr != "" {
+		resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				d := net.Dialer{Timeout: schema.DnsLookupTimeout * time.Second}
+				return d.DialContext(ctx, network, addr)
+			},
+		}
+	}
+	return &DnsResolver{resolver: resolver, cache: newTtlCache(schema.ArNsMaxCachedNames)}
+}
+
+func (r *DnsResolver) Resolve(host string) (*schema.ResolvedName, error) {
+	host = normalizeHost(host)
+	if val, err, ttl, ok := r.cache.get(host); ok {
+		if err != nil {
+			return nil, err
+		}
+		res := *val.(*schema.ResolvedName)
+		res.TtlSeconds = int64(ttl.Seconds())
+		return &res, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), schema.DnsLookupTimeout*time.Second)
+	defer cancel()
+	txts, err := r.resolver.LookupTXT(ctx, "_arweave."+host+".")
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		err = schema.ErrNameNotFound
+	}
+	if err == nil {
+		if txId := parseArweaveTxt(txts); txId != "" {
+			res := &schema.ResolvedName{
+				Resolver:   schema.NameResolverDns,
+				Name:       host,
+				TxId:       txId,
+				TtlSeconds: schema.DefaultDnsTtlSeconds,
+			}
+			r.cache.set(host, res, nil, res.TtlSeconds)
+			return res, nil
+		}
+		err = schema.ErrNameNotFound
+	}
+	if err == schema.ErrNameNotFound {
+		r.cache.set(host, nil, err, schema.ArNsNotFoundTtlSeconds)
+	}
+	return nil, err
+}
+
+// parseArweaveTxt return the first valid id in TXT records
+func parseArweaveTxt(txts []string) string {
+	for _, txt := range txts {
+		txt = strings.TrimSpace(txt)
+		txt = strings.TrimPrefix(txt, "dnslink=")
+		txt = strings.TrimPrefix(txt, "/arweave/")
+		if isArId(txt) {
+			return txt
+		}
+	}
+	return ""
+}
+
+func isArId(id string) bool {
+	by, err := utils.Base64Decode(id)
+	return err == nil && len(by) == 32
+}
+
+type ttlCacheEntry struct {
+	val    interface{}
+	err    error
+	expire time.Time
+}
+
+// ttlCache cache value or error of key until ttl expired
+type ttlCache struct {
+	entries    map[string]ttlCacheEntry
+	maxEntries int
+	lock       sync.Mutex
+}
+
+func newTtlCache(maxEntries int) *ttlCache {
+	return &ttlCache{entries: make(map[string]ttlCacheEntry), maxEntries: maxEntries}
+}
+
+// get return cached value or error and the remaining ttl
+func (c *ttlCache) get(key string) (val interface{}, err error, ttl time.Duration, ok bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return
+	}
+	ttl = time.Until(e.expire)
+	if ttl <= 0 {
+		delete(c.entries, key)
+		return nil, nil, 0, false
+	}
+	return e.val, e.err, ttl, true
+}
+
+func (c *ttlCache) set(key string, val interface{}, err error, ttlSeconds int64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	now := time.Now()
+	if len(c.entries) >= c.maxEntries {
+		for k, e := range c.entries {
+			if now.After(e.expire) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= c.maxEntries {
+			c.entries = make(map[string]ttlCacheEntry)
+		}
+	}
+	c.entries[key] = ttlCacheEntry{val: val, err: err, expire: now.Add(time.Duration(ttlSeconds) * time.Second)}
+}
@@ -0,0 +1,104 @@
+package arseeding
+
+import (
+	"encoding/binary"
+	"github.com/everFinance/arseeding/schema"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"strings"
+	"testing"
+)
+
+// serveTxtDns is a dns stand-in which answer TXT records of names, other names are NXDOMAIN
+func serveTxtDns(t *testing.T, records map[string][]string) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			query := buf[:n]
+			// question name
+			labels := make([]string, 0)
+			i := 12
+			for i < n && query[i] != 0 {
+				l := int(query[i])
+				labels = append(labels, string(query[i+1:i+1+l]))
+				i += l + 1
+			}
+			questionEnd := i + 5 // zero byte, type and class
+			txts, ok := records[strings.ToLower(strings.Join(labels, "."))]
+
+			resp := make([]byte, 12, 512)
+			copy(resp, query[:2])
+			flags := uint16(0x8180)
+			if !ok {
+				flags |= 3 // NXDOMAIN
+			}
+			binary.BigEndian.PutUint16(resp[2:], flags)
+			binary.BigEndian.PutUint16(resp[4:], 1)
+			binary.BigEndian.PutUint16(resp[6:], uint16(len(txts)))
+			resp = append(resp, query[12:questionEnd]...)
+			for _, txt := range txts {
+				resp = append(resp, 0xc0, 0x0c, 0, 16, 0, 1, 0, 0, 0, 60)
+				resp = binary.BigEndian.AppendUint16(resp, uint16(len(txt)+1))
+				resp = append(resp, byte(len(txt)))
+				resp = append(resp, txt...)
+			}
+			conn.WriteTo(resp, addr)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func TestDnsResolver(t *testing.T) {
+	txId := "cG7Hdi_iTQPoEYgQJFqJ8NMpN4KoZ-vH_j7pG4iP7NI"
+	addr := serveTxtDns(t, map[string][]string{
+		"_arweave.docs.example.com":  {"v=spf1 -all", "dnslink=/arweave/" + txId},
+		"_arweave.plain.example.com": {txId},
+		"_arweave.bad.example.com":   {"dnslink=/ipfs/abc"},
+	})
+	r := NewDnsResolver(addr)
+
+	res, err := r.Resolve("Docs.Example.com:8080")
+	assert.NoError(t, err)
+	assert.Equal(t, schema.ResolvedName{Resolver: schema.NameResolverDns, Name: "docs.example.com", TxId: txId, TtlSeconds: schema.DefaultDnsTtlSeconds}, *res)
+	res, err = r.Resolve("plain.example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, txId, res.TxId)
+
+	_, err = r.Resolve("bad.example.com")
+	assert.Equal(t, schema.ErrNameNotFound, err)
+	_, err = r.Resolve("missing.example.com")
+	assert.Equal(t, schema.ErrNameNotFound, err)
+	_, err, _, ok := r.cache.get("missing.example.com")
+	assert.True(t, ok)
+	assert.Equal(t, schema.ErrNameNotFound, err)
+
+	// consult resolvers in order
+	arns := &ArNs{cache: newTtlCache(schema.ArNsMaxCachedNames)}
+	arns.cache.set("ardrive", &schema.ArNsResolved{Name: "ardrive", TxId: "arns-tx", TtlSeconds: 60}, nil, 60)
+	arns.cache.set("docs", nil, schema.ErrArNsNotFound, 60)
+	arns.cache.set("missing", nil, schema.ErrArNsNotFound, 60)
+	resolvers, err := newNameResolvers([]string{"dns", "arns"}, arns, addr)
+	assert.NoError(t, err)
+	s := &Arseeding{nameResolvers: resolvers}
+
+	res, err = s.resolveName("docs.example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, txId, res.TxId)
+	res, err = s.resolveName("ardrive.arweave.world")
+	assert.NoError(t, err)
+	assert.Equal(t, schema.NameResolverArNs, res.Resolver)
+	assert.Equal(t, "arns-tx", res.ArNs.TxId)
+	_, err = s.resolveName("missing.example.com")
+	assert.Equal(t, schema.ErrNameNotFound, err)
+
+	_, err = newNameResolvers([]string{"unknown"}, arns, "")
+	assert.Error(t, err)
+}
@@ -0,0 +1,151 @@
+package arseeding
+
+import (
+	"encoding/json"
+	"github.com/everFinance/arseeding/schema"
+	"github.com/everFinance/goar/types"
+	"strconv"
+)
+
+func newResubmitPolicy(policy schema.ResubmitPolicy) schema.ResubmitPolicy {
+	if policy.ExpiredBlocks < schema.DefaultResubmitExpiredBlocks {
+		policy.ExpiredBlocks = schema.DefaultResubmitExpiredBlocks
+	}
+	if len(policy.SpeedSteps) == 0 {
+		policy.SpeedSteps = schema.DefaultResubmitSpeedSteps
+	}
+	return policy
+}
+
+// resubmitSpeedFactor return the speed factor of bundle arTx, attempt is the number of arTxs sent before, 0 for the first send
+func resubmitSpeedFactor(policy schema.ResubmitPolicy, price, speedFee int64, attempt int) int64 {
+	if price <= 0 {
+		return 0
+	}
+	if attempt > 0 && len(policy.SpeedSteps) > 0 {
+		step := policy.SpeedSteps[len(policy.SpeedSteps)-1]
+		if attempt <= len(policy.SpeedSteps) {
+			step = policy.SpeedSteps[attempt-1]
+		}
+		speedFee += price * step / 100
+	}
+	return calculateFactor(price, speedFee)
+}
+
+// bundleReward is the reward of arTx with speed factor, price is from arweave node. maxReward 0 means no cap
+func bundleReward(price, speedFactor, maxReward int64) (int64, error) {
+	reward := price * (100 + speedFactor) / 100
+	if maxReward > 0 && reward > maxReward {
+		return 0, schema.ErrRewardExceedCap
+	}
+	return reward, nil
+}
+
+// resubmitBudget is the max reward of next attempt, 0 means no cap. the rewards of all attempts sent are counted
+func resubmitBudget(policy schema.ResubmitPolicy, tx schema.OnChainTx, history []schema.ArTxAttempt) (int64, error) {
+	if policy.MaxSpend <= 0 {
+		return 0, nil
+	}
+	spent, _ := strconv.ParseInt(tx.Reward, 10, 64)
+	for _, h := range history {
+		reward, _ := strconv.ParseInt(h.Reward, 10, 64)
+		spent += reward
+	}
+	if spent >= policy.MaxSpend {
+		return 0, schema.ErrRewardExceedCap
+	}
+	return policy.MaxSpend - spent, nil
+}
+
+// resubmitArTx send the bundle of failed arTx again with escalated reward and fresh anchor, the failed arTx is kept in history
+func (s *Arseeding) resubmitArTx(tx schema.OnChainTx) error {
+	attempts := tx.Attempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	policy := s.resubmitPolicy
+	if policy.MaxAttempts > 0 && attempts >= policy.MaxAttempts {
+		log.Error("bundle arTx is stuck after max attempts, need manual check", "id", tx.ID, "arId", tx.ArId, "attempts", attempts)
+		metricBundleResubmit("stuck")
+		return s.wdb.UpdateArTxStatus(tx.ArId, schema.StuckOnChain, nil, nil)
+	}
+
+	itemIds := make([]string, 0)
+	if err := json.Unmarshal(tx.ItemIds, &itemIds); err != nil {
+		return err
+	}
+	history := make([]schema.ArTxAttempt, 0, attempts)
+	if len(tx.History) > 0 {
+		if err := json.Unmarshal(tx.History, &history); err != nil {
+			return err
+		}
+	}
+	apiKey := ""
+	if apiKeys, err := s.wdb.GetApiKeysByItemIds(itemIds); err == nil && len(apiKeys) == 1 {
+		apiKey = apiKeys[0]
+	}
+	var (
+		arTx           types.Transaction
+		onChainItemIds []string
+	)
+	budget, err := resubmitBudget(policy, tx, history)
+	if err == nil {
+		arTx, onChainItemIds, err = s.onChainBundleTx(itemIds, apiKey, attempts, budget)
+	}
+	if err == schema.ErrRewardExceedCap {
+		log.Error("bundle arTx is stuck, reward exceeds max spend, need manual check", "id", tx.ID, "arId", tx.ArId, "attempts", attempts)
+		metricBundleResubmit("stuck")
+		return s.wdb.UpdateArTxStatus(tx.ArId, schema.StuckOnChain, nil, nil)
+	}
+	if err != nil {
+		metricBundleResubmit("send_failed")
+		return err
+	}
+	metricBundleResubmit("resubmitted")
+
+	curHeight := s.cache.GetInfo().Height
+	history = append(history, schema.ArTxAttempt{
+		ArId:         tx.ArId,
+		Bundler:      tx.Bundler,
+		Reward:       tx.Reward,
+		CurHeight:    tx.CurHeight,
+		FailedHeight: curHeight,
+	})
+	historyJs, err := json.Marshal(history)
+	if err != nil {
+		return err
+	}
+	attempts++
+	if policy.AlertAttempts > 0 && attempts > policy.AlertAttempts {
+		log.Error("bundle arTx attempts exceed alert attempts", "id", tx.ID, "arId", arTx.ID, "attempts", attempts, "reward", arTx.Reward)
+		metricBundleResubmit("alert")
+	}
+
+	if err = s.store.SaveItemsBundledIn(arTx.ID, onChainItemIds); err != nil {
+		log.Error("s.store.SaveItemsBundledIn(arTx.ID,onChainItemIds)", "err", err, "arId", arTx.ID)
+	}
+	// items dropped from the new bundle are not updated when it is confirmed
+	onChainItemIdsJs, err := json.Marshal(onChainItemIds)
+	if err != nil {
+		return err
+	}
+	if err = s.wdb.UpdateArTx(tx.ID, arTx.ID, bundlerOfArTx(arTx), curHeight, arTx.DataSize, arTx.Reward, onChainItemIdsJs, len(onChainItemIds), attempts, historyJs, schema.PendingOnChain); err != nil {
+		return err
+	}
+	// dropped items failed by transient store errors are bundled again, lost items are kept until requeued by admin
+	return s.wdb.RequeuePendingOrders(droppedItemIds(itemIds, onChainItemIds))
+}
+
+func droppedItemIds(itemIds, onChainItemIds []string) []string {
+	onChain := make(map[string]struct{}, len(onChainItemIds))
+	for _, itemId := range onChainItemIds {
+		onChain[itemId] = struct{}{}
+	}
+	dropped := make([]string, 0)
+	for _, itemId := range itemIds {
+		if _, ok := onChain[itemId]; !ok {
+			dropped = append(dropped, itemId)
+		}
+	}
+	return dropped
+}
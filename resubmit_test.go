@@ -0,0 +1,81 @@
+package arseeding
+
+import (
+	"github.com/everFinance/arseeding/schema"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+)
+
+func TestResubmitSpeedFactor(t *testing.T) {
+	policy := newResubmitPolicy(schema.ResubmitPolicy{})
+	assert.Equal(t, schema.DefaultResubmitExpiredBlocks, policy.ExpiredBlocks)
+	assert.Equal(t, schema.DefaultResubmitSpeedSteps, policy.SpeedSteps)
+
+	price := int64(1000)
+	assert.Equal(t, calculateFactor(price, 50), resubmitSpeedFactor(policy, price, 50, 0))
+	assert.Equal(t, int64(0), resubmitSpeedFactor(policy, price, 0, 0))
+	// escalated by steps, the last step is reused
+	assert.Equal(t, int64(10), resubmitSpeedFactor(policy, price, 0, 1))
+	assert.Equal(t, int64(35), resubmitSpeedFactor(policy, price, 50, 2))
+	assert.Equal(t, int64(100), resubmitSpeedFactor(policy, price, 0, 4))
+	assert.Equal(t, int64(100), resubmitSpeedFactor(policy, price, 0, 9))
+	assert.Equal(t, int64(0), resubmitSpeedFactor(policy, 0, 50, 3))
+
+	// expired blocks less than 50 is not allowed
+	assert.Equal(t, schema.DefaultResubmitExpiredBlocks, newResubmitPolicy(schema.ResubmitPolicy{ExpiredBlocks: 20}).ExpiredBlocks)
+	assert.Equal(t, 80, newResubmitPolicy(schema.ResubmitPolicy{ExpiredBlocks: 80}).ExpiredBlocks)
+
+	// reward of node price is capped, the arTx is not sent if it exceeds the cap
+	reward, err := bundleReward(price, 30, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1300), reward)
+	reward, err = bundleReward(price, 30, 1300)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1300), reward)
+	_, err = bundleReward(price, 30, 1299)
+	assert.Equal(t, schema.ErrRewardExceedCap, err)
+
+	// max spend of all attempts
+	policy.MaxSpend = 3000
+	history := []schema.ArTxAttempt{{Reward: "1000"}}
+	budget, err := resubmitBudget(policy, schema.OnChainTx{Reward: "1100"}, history)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(900), budget)
+	_, err = resubmitBudget(policy, schema.OnChainTx{Reward: "2000"}, history)
+	assert.Equal(t, schema.ErrRewardExceedCap, err)
+	policy.MaxSpend = 0
+	budget, err = resubmitBudget(policy, schema.OnChainTx{Reward: "2000"}, history)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), budget)
+}
+
+func TestResubmitArTxStuck(t *testing.T) {
+	wdb := NewSqliteDb("./data/sqlite")
+	defer os.RemoveAll("./data/sqlite")
+	assert.NoError(t, wdb.Migrate(false, true))
+	s := &Arseeding{wdb: wdb, resubmitPolicy: newResubmitPolicy(schema.ResubmitPolicy{MaxAttempts: 3})}
+
+	assert.NoError(t, wdb.InsertArTx(schema.OnChainTx{ArId: "tx1", Status: schema.FailedOnChain, Attempts: 3, ItemIds: []byte(`["a"]`)}))
+	txs, err := wdb.GetArTxByStatus(schema.FailedOnChain)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(txs))
+	assert.NoError(t, s.resubmitArTx(txs[0]))
+
+	txs, err = wdb.GetArTxByStatus(schema.StuckOnChain)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(txs))
+	assert.Equal(t, "tx1", txs[0].ArId)
+
+	// max spend is reached
+	s.resubmitPolicy = newResubmitPolicy(schema.ResubmitPolicy{MaxSpend: 1000})
+	assert.NoError(t, wdb.InsertArTx(schema.OnChainTx{ArId: "tx2", Status: schema.FailedOnChain, Attempts: 2, Reward: "600",
+		History: []byte(`[{"arId":"tx0","reward":"500"}]`), ItemIds: []byte(`["b"]`)}))
+	txs, err = wdb.GetArTxByStatus(schema.FailedOnChain)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(txs))
+	assert.NoError(t, s.resubmitArTx(txs[0]))
+	txs, err = wdb.GetArTxByStatus(schema.StuckOnChain)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(txs))
+}
@@ -7,7 +7,13 @@ import (
 const (
 	AllowStreamMinItemSize = 5 * 1024 * 1024    // 5 MB
 	AllowMaxRespDataSize   = 50 * 1024 * 1024   // 50 MB
+	MaxVerifyItemSize      = 50 * 1024 * 1024   // 50 MB, larger items are not verified on read
 	SubmitMaxSize          = 1024 * 1024 * 1024 // 1 GB
+	ArchiveMaxFiles        = 10000
+	BatchMaxItems          = 10000
+
+	DataCacheControl     = "public, max-age=31536000, immutable"
+	ManifestCacheControl = "public, max-age=60" // manifest path can be pointed to other data by sandbox or ArNS domain
 )
 
 type RespReceiptEverTx struct {
@@ -44,6 +50,30 @@ type RespItemId struct {
 	Size   int64  `json:"size"`
 }
 
+type RespArchive struct {
+	ManifestId string            `json:"manifestId"`
+	Items      []RespArchiveItem `json:"items"`
+}
+
+type RespArchiveItem struct {
+	Path   string `json:"path"` // file path in archive, also the manifest path
+	ItemId string `json:"itemId"`
+	Size   int64  `json:"size"`
+}
+
+type ReqRequeue struct {
+	ItemIds []string `json:"itemIds"` // all lost items if empty
+}
+
+type RespRequeue struct {
+	Requeued int64 `json:"requeued"` // number of requeued orders
+}
+
+type RespBatch struct {
+	NestItemId string      `json:"nestItemId,omitempty"` // the item of whole bundle, it is posted on chain instead of inner items
+	Orders     []RespOrder `json:"orders"`
+}
+
 type Fee struct {
 	Currency string          `json:"currency"`
 	Decimals int             `json:"decimals"`
@@ -58,7 +88,15 @@ type RespFee struct {
 }
 
 type ResBundler struct {
-	Bundler string `json:"bundler"`
+	Bundler  string        `json:"bundler"` // fee receiver address
+	Bundlers []BundlerInfo `json:"bundlers"`
+}
+
+type BundlerInfo struct {
+	Address   string `json:"address"`
+	Balance   string `json:"balance"` // AR, "" if unknown
+	Pending   int    `json:"pending"` // bundle arTxs waiting for confirmation or uploading
+	Available bool   `json:"available"`
 }
 
 type RespApiKey struct {
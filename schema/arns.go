@@ -1,5 +1,11 @@
 package schema
 
+const (
+	DefaultArNsTtlSeconds  = 900 // used if ttlSeconds of record is not set
+	ArNsNotFoundTtlSeconds = 60  // cache time of not found names
+	ArNsMaxCachedNames     = 10000
+)
+
 type DomainRecord struct {
 	ProcessId      string `json:"processId"`
 	StartTimestamp int64  `json:"startTimestamp"`
@@ -25,3 +31,26 @@ type DomainState struct {
 	Balances       map[string]int64 `json:"Balances"`
 	SourceCodeTXID string           `json:"Source-Code-TX-ID"`
 }
+
+// ArNsResolved is the resolved record of ArNS name, e.g. "docs_v1_ardrive" is undername "docs_v1" of root name "ardrive"
+type ArNsResolved struct {
+	Name       string `json:"name"`
+	RootName   string `json:"rootName"`
+	Undername  string `json:"undername"` // "@" for root name
+	ProcessId  string `json:"processId"`
+	TxId       string `json:"txId"`
+	TtlSeconds int64  `json:"ttlSeconds"` // remaining ttl if cached
+	Cached     bool   `json:"cached"`
+}
+
+type RespArNsRecords struct {
+	RootName  string       `json:"rootName"`
+	ProcessId string       `json:"processId"`
+	Records   []ArNsRecord `json:"records"`
+}
+
+type ArNsRecord struct {
+	Undername  string `json:"undername"` // "@" for root name
+	TxId       string `json:"txId"`
+	TtlSeconds int64  `json:"ttlSeconds"`
+}
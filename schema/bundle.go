@@ -1,10 +1,115 @@
 package schema
 
+import "github.com/everFinance/goar/types"
+
 const (
 	DefaultPaymentExpiredRange = int64(2592000) // 30 days
 	DefaultExpectedRange       = 50             // block height range
+
+	BundlePolicyCheckInterval = 5   // seconds
+	DefaultBundleMaxWait      = 120 // seconds
+	BundlePriorityFifo        = "fifo"
+	BundlePriorityFee         = "fee" // higher fee(USD) per byte first
+	BundlePlanCandidates      = 2000  // orders loaded for one bundle plan
+	BundlePlanFeeCandidates   = 20000 // fee priority is sorted in memory, so more orders are loaded
+
+	BundlerStrategyRoundRobin   = "round_robin"
+	BundlerStrategyLeastPending = "least_pending" // fewest pending bundle arTxs first
+	BundlerStrategyApiKey       = "apikey"        // bundles of pinned apikey are posted by the pinned bundler
+	BundlerStrategyTag          = "tag"           // bundles with the tag of route are posted by the routed bundler
+	DefaultBundlerMinBalance    = 0.1             // AR
+
+	BundlerSignerKeyfile       = "keyfile"
+	BundlerSignerRemote        = "remote"
+	DefaultRemoteSignerTimeout = 10 // seconds
+
+	DefaultResubmitExpiredBlocks = 50 // anchor of arTx is expired after 50 blocks, so it can not be mined any more
+)
+
+var DefaultResubmitSpeedSteps = []int64{10, 30, 60, 100}
+
+// BundlePolicy decide how waiting orders are packed into bundles
+type BundlePolicy struct {
+	Enable        bool           `json:"enable" yaml:"enable"`               // check orders every BundlePolicyCheckInterval instead of bundleInterval
+	MaxBundleSize int64          `json:"maxBundleSize" yaml:"maxBundleSize"` // bytes, at most MaxPerOnChainSize
+	MaxItems      int            `json:"maxItems" yaml:"maxItems"`           // 0 means no limit
+	MaxWait       int            `json:"maxWait" yaml:"maxWait"`             // seconds, not filled bundle is on chain if the oldest order waited longer
+	MinFillSize   int64          `json:"minFillSize" yaml:"minFillSize"`     // bytes, not filled bundle wait for more orders
+	Priority      string         `json:"priority" yaml:"priority"`           // "fifo" or "fee"
+	ApiKeyTiers   map[string]int `json:"apiKeyTiers" yaml:"apiKeyTiers"`     // orders of higher tier apikey first
+}
+
+// BundlerWallets are the wallets posting bundle arTxs, besides the rollupKeyPath bundler which also receive fees and sign items.
+// round robin is used if apikey or tag strategy has no match
+type BundlerWallets struct {
+	KeyPaths   []string          `json:"keyPaths" yaml:"keyPaths"`     // keyfiles of wallets, same as keyfile signers
+	Signers    []BundlerSigner   `json:"signers" yaml:"signers"`       // keyfile or remote signers of wallets
+	Strategy   string            `json:"strategy" yaml:"strategy"`     // "round_robin", "least_pending", "apikey", "tag"
+	MinBalance float64           `json:"minBalance" yaml:"minBalance"` // AR, bundler with lower balance is skipped
+	ApiKeyPins map[string]string `json:"apiKeyPins" yaml:"apiKeyPins"` // apikey -> bundler address
+	TagRoutes  []BundlerTagRoute `json:"tagRoutes" yaml:"tagRoutes"`   // the first matched route is used
+}
+
+// ResubmitPolicy decide how stuck bundle arTxs are resubmitted.
+// every attempt is signed with a fresh anchor, and the reward of attempt n is escalated by SpeedSteps[n-1] percent of price
+type ResubmitPolicy struct {
+	ExpiredBlocks int     `json:"expiredBlocks" yaml:"expiredBlocks"` // blocks without confirmation before resubmit, at least 50, otherwise both arTxs may get mined
+	SpeedSteps    []int64 `json:"speedSteps" yaml:"speedSteps"`       // percent, the last step is used if attempts are more than steps
+	MaxSpend      int64   `json:"maxSpend" yaml:"maxSpend"`           // winston, total reward of all attempts of a bundle, the bundle is "stuck" instead of paying more. 0 means no cap
+	MaxAttempts   int     `json:"maxAttempts" yaml:"maxAttempts"`     // bundle is "stuck" after attempts, 0 means no limit
+	AlertAttempts int     `json:"alertAttempts" yaml:"alertAttempts"` // alert if attempts of bundle exceed, 0 means disabled
+}
+
+// ArTxAttempt is a superseded arTx of bundle
+type ArTxAttempt struct {
+	ArId         string `json:"arId"`
+	Bundler      string `json:"bundler"`
+	Reward       string `json:"reward"`
+	CurHeight    int64  `json:"curHeight"`    // height when arTx is sent
+	FailedHeight int64  `json:"failedHeight"` // height when arTx is resubmitted
+}
+
+// BundlerSigner signs arTxs and items of the rollupKeyPath bundler, or arTxs of a bundler wallet.
+// "remote" delegates signing to an external service, so the private key is never loaded into process
+type BundlerSigner struct {
+	Type    string `json:"type" yaml:"type"`       // "keyfile" or "remote", default "keyfile"
+	KeyPath string `json:"keyPath" yaml:"keyPath"` // keyfile of bundler wallet, the rollupKeyPath bundler use rollupKeyPath
+	Url     string `json:"url" yaml:"url"`         // url of remote signing service
+	Token   string `json:"token" yaml:"token"`     // bearer token of remote signing service
+	Timeout int    `json:"timeout" yaml:"timeout"` // seconds
+}
+
+type BundlerTagRoute struct {
+	Name    string `json:"name" yaml:"name"`
+	Value   string `json:"value" yaml:"value"`
+	Bundler string `json:"bundler" yaml:"bundler"` // bundler address
+}
+
+var (
+	DefaultBundleTags = []types.Tag{
+		{Name: "App-Name", Value: "arseeding"},
+		{Name: "App-Version", Value: "1.0.0"},
+		{Name: "Action", Value: "Bundle"},
+	}
+	// UMintBundleTags mint U by burning the arTx fee
+	UMintBundleTags = []types.Tag{
+		{Name: "Protocol-Name", Value: "U"},
+		{Name: "Action", Value: "Burn"},
+		{Name: "App-Name", Value: "SmartWeaveAction"},
+		{Name: "App-Version", Value: "0.3.0"},
+		{Name: "Input", Value: `{"function":"mint"}`},
+		{Name: "Contract", Value: "KTzTXT_ANmF84fWEKHzWURD1LWd9QaFR9yfYUwH2Lxw"},
+	}
 )
 
+// BundleTags are the tags of bundle arTx. tag value is text/template, {{.Size}} {{.ItemNum}} {{.NodeName}} are supported
+type BundleTags struct {
+	NodeName     string                 `json:"nodeName" yaml:"nodeName"`         // hostname if empty
+	Tags         []types.Tag            `json:"tags" yaml:"tags"`                 // DefaultBundleTags if empty
+	DisableUMint bool                   `json:"disableUMint" yaml:"disableUMint"` // not append UMintBundleTags
+	ApiKeyTags   map[string][]types.Tag `json:"apiKeyTags" yaml:"apiKeyTags"`     // replace Tags for the orders of apikey, they are bundled separately
+}
+
 type PaymentMeta struct {
 	AppName string   `json:"appName"`
 	Action  string   `json:"action"`
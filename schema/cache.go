@@ -25,6 +25,20 @@ const (
 	`
 )
 
+const (
+	DefaultReadThroughMaxDataSize = 50 * 1024 * 1024 // 50 MB
+	DefaultReadThroughMinHits     = 2
+	DefaultReadThroughHitWindow   = 600 // seconds
+	ReadThroughMaxTrackedIds      = 100000
+)
+
+const (
+	DefaultGateway        = "https://arweave.net"
+	DefaultGatewayTimeout = 30 // seconds
+
+	GatewayProxyMaxBufferSize = 1024 * 1024 // 1 MB, larger proxied request body is streamed without failover
+)
+
 type ArFee struct {
 	Base     int64
 	PerChunk int64
@@ -18,6 +18,24 @@ type Config struct {
 	MongoDBKV MongoDBKV `yaml:"mongoDBKV"`
 
 	Kafka Kafka `yaml:"kafka"`
+
+	ReadThrough  ReadThrough `yaml:"readThrough"`
+	VerifyOnRead bool        `yaml:"verifyOnRead"`
+
+	Gateways []Gateway `yaml:"gateways"`
+
+	ApiHosts []string `yaml:"apiHosts"` // hosts of api, others may be custom domains or ArNS names
+	AdminKey string   `yaml:"adminKey"` // X-ADMIN-KEY of admin api, admin api is disabled if empty
+
+	NameResolvers []string `yaml:"nameResolvers"` // "arns", "dns", consulted in order, default ["arns"]
+	DnsResolver   string   `yaml:"dnsResolver"`   // host:port of dns server, system resolver if empty
+
+	BundlePolicy BundlePolicy `yaml:"bundlePolicy"`
+	BundleTags   BundleTags   `yaml:"bundleTags"`
+
+	BundlerWallets BundlerWallets `yaml:"bundlerWallets"`
+	BundlerSigner  BundlerSigner  `yaml:"bundlerSigner"`
+	ResubmitPolicy ResubmitPolicy `yaml:"resubmitPolicy"`
 }
 
 type S3KV struct {
@@ -47,3 +65,15 @@ type Kafka struct {
 	Start bool   `yaml:"start"`
 	Uri   string `yaml:"uri"`
 }
+
+type ReadThrough struct {
+	Enable      bool  `yaml:"enable"`
+	MaxDataSize int64 `yaml:"maxDataSize"` // bytes, larger data is only proxied
+	MinHits     int   `yaml:"minHits"`     // persist after requested minHits times within hitWindow
+	HitWindow   int   `yaml:"hitWindow"`   // seconds
+}
+
+type Gateway struct {
+	Url     string `json:"url" yaml:"url"`
+	Timeout int    `json:"timeout" yaml:"timeout"` // seconds
+}
@@ -11,6 +11,19 @@ const (
 	PendingOnChain = "pending"
 	SuccOnChain    = "success"
 	FailedOnChain  = "failed"
+	StuckOnChain   = "stuck" // resubmitted max attempts, need manual check
+	LostOnChain    = "lost"  // item is not in bundle, requeue by admin api
+
+	// reason of lost item
+	LostReasonLoadFailed  = "load_failed"   // item binary can not be loaded from store
+	LostReasonCorrupt     = "corrupt"       // item binary can not be decoded or id not match
+	LostReasonNotInBundle = "not_in_bundle" // item is not in the header of posted bundle
+	LostReasonNotIndexed  = "not_indexed"   // gateway graphql has no bundledIn of item after InclusionIndexBlocks
+
+	// inclusion of bundle items, "" means bundle succeeded before inclusion check
+	InclusionUnchecked   = "unchecked"
+	InclusionChecked     = "checked"
+	InclusionIndexBlocks = 720 // about one day, wait for gateway indexing the bundle
 
 	// order payment status
 	UnPayment      = "unpaid"
@@ -24,7 +37,8 @@ const (
 	Refund    = "refunded"
 	RefundErr = "refundErr"
 
-	MaxPerOnChainSize = 2 * 1024 * 1024 * 1024 // 2 GB
+	MaxPerOnChainSize     = 2 * 1024 * 1024 * 1024 // 2 GB
+	MaxInMemoryBundleSize = 32 * 1024 * 1024       // 32 MB, larger bundle is assembled in spool file
 
 	TmpFileDir = "./tmpFile"
 )
@@ -48,7 +62,8 @@ type Order struct {
 	PaymentStatus string `gorm:"index:idx0" json:"paymentStatus"` // "unpaid", "paid", "expired"
 	PaymentId     string `json:"paymentId"`                       // everHash
 
-	OnChainStatus string `gorm:"index:idx5" json:"onChainStatus"` // "waiting","pending","success","failed"
+	OnChainStatus string `gorm:"index:idx5" json:"onChainStatus"` // "waiting","pending","success","failed","lost"
+	LostReason    string `json:"lostReason,omitempty"`
 	ApiKey        string `gorm:"index:idx2" json:"-"`
 	Sort          bool   `json:"sort"`                     // upload items to arweave by sequence
 	Kafka         bool   `gorm:"index:idx0"  json:"kafka"` // send to kafka
@@ -89,4 +104,8 @@ type OnChainTx struct {
 	ItemIds     datatypes.JSON // json.marshal(itemIds)
 	ItemNum     int
 	Kafka       bool
+	Bundler     string         `gorm:"index:idx3"` // address of the wallet posted the arTx
+	Attempts    int            // arTxs sent for the bundle, 0 if sent before attempts are counted
+	History     datatypes.JSON // json.marshal([]ArTxAttempt), superseded arTxs
+	Inclusion   string         // "", "unchecked", "checked"
 }
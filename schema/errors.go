@@ -17,5 +17,20 @@ var (
 	ErrNullData      = errors.New("null_data")
 	ErrLocalNotExist = errors.New("not_exist_local") // need to get data from gateway
 	ErrPageNotFound  = errors.New("page_not_found")  // e.g manifest data not contain index path
+	ErrManifestDepth = errors.New("manifest_nested_too_deep")
+	ErrManifestPaths = errors.New("manifest_too_many_paths")
 	ErrNotImplement  = errors.New("method not implement")
+
+	ErrNilIndexFilter = errors.New("need at least one of owner, target, tag")
+
+	ErrReadThroughTooBig  = errors.New("read_through_data_too_big")
+	ErrReadThroughInvalid = errors.New("read_through_data_invalid")
+
+	ErrDataCorrupted = errors.New("local_data_corrupted") // need to re-fetch data from peers
+
+	ErrArNsNotFound = errors.New("arns_name_not_found")
+	ErrNameNotFound = errors.New("name_not_found") // no resolver has record of the host
+
+	ErrNoBundlerAvailable = errors.New("no_bundler_available") // balance of all bundlers is too low
+	ErrRewardExceedCap    = errors.New("reward_exceed_max_spend")
 )
@@ -0,0 +1,54 @@
+package schema
+
+import "github.com/everFinance/goar/types"
+
+const (
+	IndexPageSize = 1000 // ids per index page
+
+	IndexQueryDefaultNum = 20
+	IndexQueryMaxNum     = 100
+)
+
+// IndexRecord is the per-id row of the local tx index, it holds the fields that not exist in tx/item meta
+type IndexRecord struct {
+	Id          string `json:"id"`
+	Owner       string `json:"owner"` // owner address
+	Target      string `json:"target"`
+	DataSize    int64  `json:"dataSize"`
+	ContentType string `json:"contentType"`
+	DataSha256  string `json:"dataSha256"` // hex, "" means not calculated yet
+	BundledIn   string `json:"bundledIn"`  // bundle arId, "" means L1 tx or not on chain yet
+	IsItem      bool   `json:"isItem"`
+
+	Positions []IndexPosition `json:"positions,omitempty"` // where the id is in index lists, so it can be removed without scan
+}
+
+// IndexPosition the position of id in the index list of bucket and key
+type IndexPosition struct {
+	Bucket string `json:"bucket"`
+	Key    string `json:"key"`
+	Pos    int64  `json:"pos"`
+}
+
+const (
+	GraphqlDefaultFirst = 10
+	GraphqlMaxFirst     = 100
+)
+
+type GraphqlRequest struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables"`
+	OperationName string                 `json:"operationName"`
+}
+
+// ItemIndexFilter all conditions must be matched, empty condition is ignored
+type ItemIndexFilter struct {
+	Owner  string
+	Target string
+	Tags   []types.Tag
+}
+
+type RespIndexedItems struct {
+	Items  []types.BundleItem `json:"items"`  // item meta, without data
+	Cursor string             `json:"cursor"` // "" means no more items
+}
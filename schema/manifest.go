@@ -1,6 +1,12 @@
 package schema
 
 const (
+	MaxManifestDepth = 5 // max nesting level when a manifest path point to another manifest
+
+	MaxManifestListPaths   = 10000 // max paths resolved by one listing, nested manifests included
+	ManifestListDefaultNum = 100
+	ManifestListMaxNum     = 1000
+
 	ManifestType = "application/x.arweave-manifest+json"
 	ContentType  = "Content-Type"
 	ManiData     = `{
@@ -34,21 +40,63 @@ const (
 
 type ManifestData struct {
 	Manifest string              `json:"manifest"` // must be "arweave/paths"
-	Version  string              `json:"version"`  // currently "0.1.0"
+	Version  string              `json:"version"`  // "0.1.0" or "0.2.0"
 	Index    IndexPath           `json:"index"`
+	Fallback *Resource           `json:"fallback,omitempty"` // v0.2.0, served when path not found
 	Paths    map[string]Resource `json:"paths"`
 }
 
 type IndexPath struct {
 	Path string `json:"path"`
+	TxId string `json:"id,omitempty"` // v0.2.0, takes precedence over path
 }
 
 type Resource struct {
 	TxId string `json:"id"`
 }
 
+// RespManifestLs is the path tree of manifest, paths of nested manifests are listed with full path
+type RespManifestLs struct {
+	ManifestId string          `json:"manifestId"`
+	Index      IndexPath       `json:"index"`
+	Fallback   *Resource       `json:"fallback,omitempty"`
+	Entries    []ManifestEntry `json:"entries"`
+	NextCursor string          `json:"nextCursor,omitempty"` // path of the last entry, "" if there are no more entries
+}
+
+type ManifestEntry struct {
+	Path        string `json:"path"`
+	Id          string `json:"id"`
+	Size        int64  `json:"size"`        // 0 if not indexed in local
+	ContentType string `json:"contentType"` // "" if not in local
+	Local       bool   `json:"local"`       // data is in local store
+	Manifest    bool   `json:"manifest"`    // nested manifest
+}
+
 type Manifest struct {
 	ID          uint   `gorm:"primarykey" json:"-"`
 	ManifestUrl string `gorm:"index:idxMani0,unique" json:"manifestUrl"`
 	ManifestId  string `json:"manifestId"` // arId
 }
+
+// CustomDomain map a custom host, e.g. docs.example.com, to a manifest or data id
+type CustomDomain struct {
+	ID       uint   `gorm:"primarykey" json:"-"`
+	Domain   string `gorm:"index:idxDomain0,unique" json:"domain"`
+	TargetId string `json:"targetId"` // manifest, arTx or bundle item id
+}
+
+// DefaultApiHosts are hosts of arseeding api, they are never resolved as permaweb domain
+var DefaultApiHosts = []string{
+	"seed-dev.everpay.io",
+	"arseed.web3infra.dev",
+	"arseed.web3infura.io",
+	"arseed-dev.web3infra.dev",
+	"arseed-dev.web3infura.io",
+	"web3infra.dev",
+	"web3infura.io",
+	"arweave.world",
+	"arweave.asia",
+	"localhost",
+	"127.0.0.1",
+}
@@ -0,0 +1,18 @@
+package schema
+
+const (
+	NameResolverArNs = "arns"
+	NameResolverDns  = "dns" // TXT record of _arweave.<host>
+
+	DefaultDnsTtlSeconds = 300
+	DnsLookupTimeout     = 5 // seconds
+)
+
+// ResolvedName is the manifest or data id of request host
+type ResolvedName struct {
+	Resolver   string        `json:"resolver"`
+	Name       string        `json:"name"`
+	TxId       string        `json:"txId"`
+	TtlSeconds int64         `json:"ttlSeconds"`
+	ArNs       *ArNsResolved `json:"arns,omitempty"` // set by ArNS resolver
+}
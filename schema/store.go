@@ -19,6 +19,12 @@ var (
 	BundleWaitParseArIdBucket = "bundle-wait-parse-arId-bucket" // key: arId, val: "0x01"
 	BundleArIdToItemIdsBucket = "bundle-arId-to-itemIds-bucket" // key: arId, val: json.marshal(itemIds)
 
+	// local tx index, value of the list bucket is paged: key+"-"+page -> json.marshal(ids), key -> pageNum
+	TxIndexRecordBucket = "tx-index-record-bucket" // key: id, val: json.marshal(IndexRecord)
+	TxIndexOwnerBucket  = "tx-index-owner-bucket"  // key: owner address
+	TxIndexTagBucket    = "tx-index-tag-bucket"    // key: sha256(tagName+tagValue)
+	TxIndexTargetBucket = "tx-index-target-bucket" // key: target address
+
 	//statistic
 	StatisticBucket = "order-statistic-bucket"
 )
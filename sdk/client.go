@@ -196,6 +196,36 @@ func (a *ArSeedCli) SubmitItemStream(itemBinary io.Reader, currency string, apik
 	return br, err
 }
 
+// SubmitBatch submit an ANS-104 bundle binary, or a bundle item whose data is a bundle. nest keeps the nesting on chain
+func (a *ArSeedCli) SubmitBatch(bundle io.Reader, currency string, apikey string, needSequence bool, nest bool) (*schema.RespBatch, error) {
+	req := a.SCli.Post()
+	req.Path(fmt.Sprintf("/bundle/batch/%s", currency))
+	req.SetHeader("Content-Type", "application/octet-stream")
+	if len(apikey) > 0 {
+		req.SetHeader("X-API-KEY", apikey)
+	}
+	if needSequence {
+		req.SetHeader("Sort", "true")
+	}
+	if nest {
+		req.AddQuery("nest", "true")
+	}
+
+	req.Body(bundle)
+
+	resp, err := req.Send()
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Close()
+	if !resp.Ok {
+		return nil, fmt.Errorf("send to bundler request failed; http code: %d, errMsg:%s", resp.StatusCode, resp.String())
+	}
+	br := &schema.RespBatch{}
+	err = resp.JSON(br)
+	return br, err
+}
+
 func (a *ArSeedCli) SubmitNativeData(apiKey string, currency string, data []byte, contentType string, tags map[string]string) (*schema.RespItemId, error) {
 	req := a.SCli.Post()
 	req.Path(fmt.Sprintf("/bundle/data/%s", currency))
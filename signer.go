@@ -0,0 +1,313 @@
+package arseeding
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/everFinance/arseeding/schema"
+	"github.com/everFinance/goar"
+	"github.com/everFinance/goar/types"
+	"github.com/everFinance/goar/utils"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// BundlerSigner holds the arweave RSA key of bundler.
+// SignMsg return the RSA-PSS(SHA-256) signature of msg, the same as goar.Signer
+type BundlerSigner interface {
+	Address() string
+	Owner() string // base64url of RSA public modulus
+	SignMsg(msg []byte) ([]byte, error)
+}
+
+// ItemSigner create and sign bundle items, implemented by bundlerItemSigner and goar.ItemSigner
+type ItemSigner interface {
+	CreateAndSignItem(data []byte, target string, anchor string, tags []types.Tag) (types.BundleItem, error)
+	CreateAndSignItemStream(data io.Reader, target string, anchor string, tags []types.Tag) (types.BundleItem, error)
+}
+
+func newBundlerSigner(cfg schema.BundlerSigner, keyPath string) (BundlerSigner, error) {
+	switch cfg.Type {
+	case "", schema.BundlerSignerKeyfile:
+		signer, err := goar.NewSignerFromPath(keyPath)
+		if err != nil {
+			return nil, err
+		}
+		return &keyfileSigner{signer: signer}, nil
+	case schema.BundlerSignerRemote:
+		return newRemoteSigner(cfg)
+	default:
+		return nil, fmt.Errorf("unknown bundler signer type: %s", cfg.Type)
+	}
+}
+
+// keyfileSigner the key is loaded from keyfile into process
+type keyfileSigner struct {
+	signer *goar.Signer
+}
+
+func (k *keyfileSigner) Address() string {
+	return k.signer.Address
+}
+
+func (k *keyfileSigner) Owner() string {
+	return k.signer.Owner()
+}
+
+func (k *keyfileSigner) SignMsg(msg []byte) ([]byte, error) {
+	return k.signer.SignMsg(msg)
+}
+
+// everpaySigner everpay sdk can only sign by the key in process, so everTx can not be sent if the key is remote
+func everpaySigner(signer BundlerSigner) (*goar.Signer, bool) {
+	if k, ok := signer.(*keyfileSigner); ok {
+		return k.signer, true
+	}
+	return &goar.Signer{Address: signer.Address()}, false
+}
+
+// remoteSigner delegates signing to an external service (KMS, HSM gateway) over HTTP:
+//
+//	GET  {url}/owner -> {"owner": "<base64url RSA public modulus>"}
+//	POST {url}/sign  {"message": "<base64url msg>"} -> {"signature": "<base64url RSA-PSS(SHA-256) signature>"}
+//
+// the signature is verified by owner before it is used
+type remoteSigner struct {
+	url     string
+	token   string
+	client  *http.Client
+	owner   string
+	address string
+	pubKey  *rsa.PublicKey
+}
+
+func newRemoteSigner(cfg schema.BundlerSigner) (*remoteSigner, error) {
+	signerUrl := strings.TrimSuffix(cfg.Url, "/")
+	if u, err := url.Parse(signerUrl); err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid remote signer url: %s", cfg.Url)
+	}
+	timeout := time.Duration(cfg.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = schema.DefaultRemoteSignerTimeout * time.Second
+	}
+	r := &remoteSigner{
+		url:    signerUrl,
+		token:  cfg.Token,
+		client: &http.Client{Timeout: timeout},
+	}
+
+	res := struct {
+		Owner string `json:"owner"`
+	}{}
+	if err := r.call(http.MethodGet, "/owner", nil, &res); err != nil {
+		return nil, fmt.Errorf("get owner of remote signer failed: %v", err)
+	}
+	ownerBy, err := utils.Base64Decode(res.Owner)
+	if err != nil || len(ownerBy) == 0 {
+		return nil, fmt.Errorf("invalid owner of remote signer: %s", res.Owner)
+	}
+	if r.pubKey, err = utils.OwnerToPubKey(res.Owner); err != nil {
+		return nil, err
+	}
+	addr := sha256.Sum256(ownerBy)
+	r.owner = res.Owner
+	r.address = utils.Base64Encode(addr[:])
+	return r, nil
+}
+
+func (r *remoteSigner) Address() string {
+	return r.address
+}
+
+func (r *remoteSigner) Owner() string {
+	return r.owner
+}
+
+func (r *remoteSigner) SignMsg(msg []byte) ([]byte, error) {
+	req := map[string]string{"message": utils.Base64Encode(msg)}
+	res := struct {
+		Signature string `json:"signature"`
+	}{}
+	if err := r.call(http.MethodPost, "/sign", req, &res); err != nil {
+		return nil, fmt.Errorf("remote sign failed: %v", err)
+	}
+	sig, err := utils.Base64Decode(res.Signature)
+	if err != nil {
+		return nil, err
+	}
+	if err = utils.Verify(msg, r.pubKey, sig); err != nil {
+		return nil, errors.New("invalid signature of remote signer")
+	}
+	return sig, nil
+}
+
+func (r *remoteSigner) call(method, path string, body, res interface{}) error {
+	var reqBody io.Reader
+	if body != nil {
+		by, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(by)
+	}
+	req, err := http.NewRequest(method, r.url+path, reqBody)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if r.token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.token)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status code: %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(res)
+}
+
+// Bundler posts arTxs signed by BundlerSigner
+type Bundler struct {
+	Client *goar.Client
+	Signer BundlerSigner
+}
+
+func NewBundler(signer BundlerSigner, arNode string) *Bundler {
+	return &Bundler{Client: goar.NewClient(arNode), Signer: signer}
+}
+
+func bundleTxTags(tags []types.Tag) []types.Tag {
+	return append([]types.Tag{
+		{Name: "Bundle-Format", Value: "binary"},
+		{Name: "Bundle-Version", Value: "2.0.0"},
+	}, tags...)
+}
+
+// checkBundleTxTags tags can not set the reserved tags of bundleTxTags
+func checkBundleTxTags(tags []types.Tag) error {
+	for _, tag := range tags {
+		if _, ok := reservedBundleTags[tag.Name]; ok {
+			return errors.New("tags can not set bundleTags")
+		}
+	}
+	return nil
+}
+
+// SendBundleTxSpeedUp maxReward 0 means no cap, schema.ErrRewardExceedCap is returned if the reward exceeds it
+func (b *Bundler) SendBundleTxSpeedUp(ctx context.Context, concurrentNum int, bundleBinary []byte, tags []types.Tag, speedFactor, maxReward int64) (types.Transaction, error) {
+	if err := checkBundleTxTags(tags); err != nil {
+		return types.Transaction{}, err
+	}
+	price, err := b.Client.GetTransactionPrice(len(bundleBinary), nil)
+	if err != nil {
+		return types.Transaction{}, err
+	}
+	reward, err := bundleReward(price, speedFactor, maxReward)
+	if err != nil {
+		return types.Transaction{}, err
+	}
+	tx := &types.Transaction{
+		Format:   2,
+		Target:   "",
+		Quantity: "0",
+		Tags:     utils.TagsEncode(bundleTxTags(tags)),
+		Data:     utils.Base64Encode(bundleBinary),
+		DataSize: fmt.Sprintf("%d", len(bundleBinary)),
+		Reward:   fmt.Sprintf("%d", reward),
+	}
+	return b.SendTransactionConcurrent(ctx, concurrentNum, tx)
+}
+
+func (b *Bundler) SendTransactionConcurrent(ctx context.Context, concurrentNum int, tx *types.Transaction) (types.Transaction, error) {
+	anchor, err := b.Client.GetTransactionAnchor()
+	if err != nil {
+		return types.Transaction{}, err
+	}
+	tx.LastTx = anchor
+	tx.Owner = b.Signer.Owner()
+	if err = signTx(b.Signer, tx); err != nil {
+		return types.Transaction{}, err
+	}
+	uploader, err := goar.CreateUploader(b.Client, tx, nil)
+	if err != nil {
+		return types.Transaction{}, err
+	}
+	err = uploader.ConcurrentOnce(ctx, concurrentNum)
+	return *tx, err
+}
+
+// signTx same as utils.SignTransaction, chunks are prepared if tx has no chunks
+func signTx(signer BundlerSigner, tx *types.Transaction) error {
+	signData, err := utils.GetSignatureData(tx)
+	if err != nil {
+		return err
+	}
+	sig, err := signer.SignMsg(signData)
+	if err != nil {
+		return err
+	}
+	txId := sha256.Sum256(sig)
+	tx.ID = utils.Base64Encode(txId[:])
+	tx.Signature = utils.Base64Encode(sig)
+	return nil
+}
+
+// bundlerItemSigner same as goar.ItemSigner of arweave signer
+type bundlerItemSigner struct {
+	signer BundlerSigner
+}
+
+func (i *bundlerItemSigner) CreateAndSignItem(data []byte, target string, anchor string, tags []types.Tag) (types.BundleItem, error) {
+	item, err := utils.NewBundleItem(i.signer.Owner(), types.ArweaveSignType, target, anchor, data, tags)
+	if err != nil {
+		return types.BundleItem{}, err
+	}
+	if err = signItem(i.signer, item); err != nil {
+		return types.BundleItem{}, err
+	}
+	if item.ItemBinary, err = utils.GenerateItemBinary(item); err != nil {
+		return types.BundleItem{}, err
+	}
+	return *item, nil
+}
+
+func (i *bundlerItemSigner) CreateAndSignItemStream(data io.Reader, target string, anchor string, tags []types.Tag) (types.BundleItem, error) {
+	item, err := utils.NewBundleItemStream(i.signer.Owner(), types.ArweaveSignType, target, anchor, data, tags)
+	if err != nil {
+		return types.BundleItem{}, err
+	}
+	if err = signItem(i.signer, item); err != nil {
+		return types.BundleItem{}, err
+	}
+	if _, err = item.DataReader.Seek(0, io.SeekStart); err != nil {
+		return types.BundleItem{}, err
+	}
+	return *item, nil
+}
+
+func signItem(signer BundlerSigner, item *types.BundleItem) error {
+	signMsg, err := utils.BundleItemSignData(*item)
+	if err != nil {
+		return err
+	}
+	sig, err := signer.SignMsg(signMsg)
+	if err != nil {
+		return err
+	}
+	id := sha256.Sum256(sig)
+	item.Id = utils.Base64Encode(id[:])
+	item.Signature = utils.Base64Encode(sig)
+	return nil
+}
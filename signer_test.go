@@ -0,0 +1,131 @@
+package arseeding
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"github.com/everFinance/arseeding/schema"
+	"github.com/everFinance/goar"
+	"github.com/everFinance/goar/types"
+	"github.com/everFinance/goar/utils"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+// newKmsServer is a stand-in of remote signing service, the key never leaves it
+func newKmsServer(t *testing.T, prvKey *rsa.PrivateKey, token string) *httptest.Server {
+	kms := goar.NewSignerByPrivateKey(prvKey)
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/owner":
+			json.NewEncoder(w).Encode(map[string]string{"owner": kms.Owner()})
+		case "/sign":
+			req := map[string]string{}
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			msg, err := utils.Base64Decode(req["message"])
+			assert.NoError(t, err)
+			sig, err := kms.SignMsg(msg)
+			assert.NoError(t, err)
+			json.NewEncoder(w).Encode(map[string]string{"signature": utils.Base64Encode(sig)})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestRemoteSigner(t *testing.T) {
+	assert.NoError(t, os.MkdirAll(schema.TmpFileDir, os.ModePerm))
+	defer os.RemoveAll(schema.TmpFileDir)
+	prvKey, err := rsa.GenerateKey(rand.Reader, 4096) // arweave key size of bundle item
+	assert.NoError(t, err)
+	keyfile := &keyfileSigner{signer: goar.NewSignerByPrivateKey(prvKey)}
+
+	server := newKmsServer(t, prvKey, "token1")
+	defer server.Close()
+	_, err = newBundlerSigner(schema.BundlerSigner{Type: schema.BundlerSignerRemote, Url: server.URL, Token: "wrong"}, "")
+	assert.Error(t, err)
+	signer, err := newBundlerSigner(schema.BundlerSigner{Type: schema.BundlerSignerRemote, Url: server.URL, Token: "token1"}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, keyfile.Address(), signer.Address())
+	assert.Equal(t, keyfile.Owner(), signer.Owner())
+	_, ok := everpaySigner(signer)
+	assert.False(t, ok)
+	_, ok = everpaySigner(keyfile)
+	assert.True(t, ok)
+
+	// items signed remotely
+	itemSigner := &bundlerItemSigner{signer: signer}
+	item, err := itemSigner.CreateAndSignItem([]byte("remote"), "", "", []types.Tag{{Name: "Content-Type", Value: "text/plain"}})
+	assert.NoError(t, err)
+	assert.NoError(t, utils.VerifyBundleItem(item))
+	decoded, err := utils.DecodeBundleItem(item.ItemBinary)
+	assert.NoError(t, err)
+	assert.Equal(t, item.Id, decoded.Id)
+	addr, err := utils.ItemSignerAddr(item)
+	assert.NoError(t, err)
+	assert.Equal(t, signer.Address(), addr)
+
+	dataFile, err := os.CreateTemp(schema.TmpFileDir, "remote-")
+	assert.NoError(t, err)
+	defer dataFile.Close()
+	_, err = dataFile.Write([]byte("remote stream"))
+	assert.NoError(t, err)
+	_, err = dataFile.Seek(0, io.SeekStart)
+	assert.NoError(t, err)
+	streamItem, err := itemSigner.CreateAndSignItemStream(dataFile, "", "", nil)
+	assert.NoError(t, err)
+	data, err := io.ReadAll(streamItem.DataReader)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("remote stream"), data)
+	_, err = streamItem.DataReader.Seek(0, io.SeekStart)
+	assert.NoError(t, err)
+	assert.NoError(t, utils.VerifyBundleItem(streamItem))
+
+	// arTx signed remotely
+	tx := &types.Transaction{
+		Format:   2,
+		Quantity: "0",
+		Reward:   "100",
+		LastTx:   utils.Base64Encode(make([]byte, 48)),
+		Owner:    signer.Owner(),
+		Tags:     utils.TagsEncode(bundleTxTags(nil)),
+		Data:     utils.Base64Encode([]byte("bundle")),
+		DataSize: "6",
+	}
+	assert.NoError(t, signTx(signer, tx))
+	assert.NoError(t, utils.VerifyTransaction(*tx))
+
+	// signature not by the owner is rejected
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	other := newKmsServer(t, otherKey, "token1")
+	defer other.Close()
+	remote := signer.(*remoteSigner)
+	remote.url = other.URL
+	_, err = remote.SignMsg([]byte("msg"))
+	assert.Error(t, err)
+
+	_, err = newBundlerSigner(schema.BundlerSigner{Type: "pkcs11"}, "")
+	assert.Error(t, err)
+
+	// bundler wallet signed remotely
+	pool, err := NewBundlerPool(NewBundler(keyfile, "http://127.0.0.1:1"), "http://127.0.0.1:1", schema.BundlerWallets{
+		Signers: []schema.BundlerSigner{{Type: schema.BundlerSignerRemote, Url: other.URL, Token: "token1"}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(pool.wallets))
+	assert.Equal(t, goar.NewSignerByPrivateKey(otherKey).Address, pool.wallets[1].Signer.Address())
+
+	// reserved tags are rejected before the arTx is sent
+	_, err = pool.wallets[1].SendBundleTxSpeedUp(context.Background(), 1, []byte("bundle"), []types.Tag{{Name: "Bundle-Version", Value: "1.0.0"}}, 0, 0)
+	assert.Error(t, err)
+}
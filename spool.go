@@ -0,0 +1,419 @@
+package arseeding
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"github.com/everFinance/arseeding/schema"
+	"github.com/everFinance/goar/types"
+	"github.com/everFinance/goar/utils"
+	"io"
+	"os"
+)
+
+// bundleItemInfo is a checked bundle item binary in store backend, the binary is opened again when the bundle is assembled
+type bundleItemInfo struct {
+	id       string
+	size     int64
+	dataSize int64
+}
+
+// checkBundleItems check the item binaries of all backends, invalid items are skipped with lost reason,
+// items failed by transient store errors are skipped without reason, so they are bundled again later.
+// the item streams are closed after checked, so no read tx or memory is held while the bundle is posted.
+// the end item data must not be empty, because viewblock decode the bundle failed in this case
+func (s *Arseeding) checkBundleItems(itemIds []string) (items []*bundleItemInfo, lost map[string]string, err error) {
+	items = make([]*bundleItemInfo, 0, len(itemIds))
+	lost = make(map[string]string)
+	for _, itemId := range itemIds {
+		item, reason, err := s.checkBundleItem(itemId)
+		if err != nil {
+			log.Error("s.checkBundleItem(itemId)", "err", err, "itemId", itemId, "reason", reason)
+			if reason != "" {
+				lost[itemId] = reason
+			}
+			continue
+		}
+		items = append(items, item)
+	}
+	if len(items) == 0 {
+		return nil, lost, errors.New("onChainItems is null")
+	}
+
+	idx, err := endItemIndex(len(items), func(i int) bool { return items[i].dataSize > 0 })
+	if err != nil {
+		return nil, lost, err
+	}
+	if idx >= 0 {
+		endItem := items[idx]
+		items = append(items[:idx], items[idx+1:]...)
+		items = append(items, endItem)
+	}
+	return items, lost, nil
+}
+
+// checkBundleItem reason is the lost reason if item is not exist or corrupted, "" if err is a transient store error
+func (s *Arseeding) checkBundleItem(itemId string) (item *bundleItemInfo, reason string, err error) {
+	stream, err := s.store.KVDb.GetStream(schema.BundleItemBinary, itemId)
+	if err != nil {
+		if err == schema.ErrNotExist {
+			reason = schema.LostReasonLoadFailed
+		}
+		return nil, reason, err
+	}
+	defer stream.Close()
+	reader := &readErrRecorder{r: stream}
+	defer func() {
+		if reader.err != nil {
+			err = reader.err
+		} else if err != nil {
+			// the binary is read completely but invalid
+			reason = schema.LostReasonCorrupt
+		}
+	}()
+
+	id, err := itemIdOfBinary(reader)
+	if err != nil {
+		return
+	}
+	if id != itemId {
+		return nil, "", fmt.Errorf("item binary id not match, id: %s", id)
+	}
+	if _, err = reader.Seek(0, io.SeekStart); err != nil {
+		return
+	}
+	dataStart, err := itemDataOffset(reader)
+	if err != nil {
+		return
+	}
+	size, err := reader.Seek(0, io.SeekEnd)
+	if err != nil {
+		return
+	}
+	if size < dataStart {
+		return nil, "", errors.New("item binary is incomplete")
+	}
+	return &bundleItemInfo{id: itemId, size: size, dataSize: size - dataStart}, "", nil
+}
+
+// readErrRecorder record the read error of store stream, EOF means the binary is shorter than expected, others are store errors.
+// store error is reported as EOF to reader, utils.DeepHash panics on read error
+type readErrRecorder struct {
+	r   io.ReadSeeker
+	err error
+}
+
+func (e *readErrRecorder) Read(p []byte) (n int, err error) {
+	n, err = e.r.Read(p)
+	if err != nil && err != io.EOF {
+		e.err = err
+		err = io.EOF
+	}
+	return
+}
+
+func (e *readErrRecorder) Seek(offset int64, whence int) (int64, error) {
+	n, err := e.r.Seek(offset, whence)
+	if err != nil {
+		e.err = err
+	}
+	return n, err
+}
+
+// endItemIndex return the index of item that should be moved to the end of bundle, -1 if the end item has data already
+func endItemIndex(n int, hasData func(i int) bool) (int, error) {
+	if hasData(n - 1) {
+		return -1, nil
+	}
+	for i := 0; i < n; i++ {
+		if hasData(i) {
+			return i, nil
+		}
+	}
+	return -1, errors.New("all bundle items data are null")
+}
+
+// itemIdOfBinary read the signature of item binary, item id is sha256 of signature
+func itemIdOfBinary(r io.Reader) (string, error) {
+	sigTypeBy := make([]byte, 2)
+	if _, err := io.ReadFull(r, sigTypeBy); err != nil {
+		return "", err
+	}
+	sigMeta, ok := types.SigConfigMap[utils.ByteArrayToLong(sigTypeBy)]
+	if !ok {
+		return "", fmt.Errorf("not support sigType:%d", utils.ByteArrayToLong(sigTypeBy))
+	}
+	sig := make([]byte, sigMeta.SigLength)
+	if _, err := io.ReadFull(r, sig); err != nil {
+		return "", err
+	}
+	id := sha256.Sum256(sig)
+	return utils.Base64Encode(id[:]), nil
+}
+
+// bundleSize is the size of ANS-104 bundle binary, include the item count and the headers of items
+func bundleSize(items []*bundleItemInfo) int64 {
+	size := int64(32 + 64*len(items))
+	for _, item := range items {
+		size += item.size
+	}
+	return size
+}
+
+func writeBundleHeader(w io.Writer, items []*bundleItemInfo) error {
+	header := make([]byte, 0, 32+64*len(items))
+	header = append(header, utils.LongTo32ByteArray(len(items))...)
+	for _, item := range items {
+		id, err := utils.Base64Decode(item.id)
+		if err != nil {
+			return err
+		}
+		header = append(header, utils.LongTo32ByteArray(int(item.size))...)
+		header = append(header, id...)
+	}
+	_, err := w.Write(header)
+	return err
+}
+
+// loadBundle assemble the bundle in memory and verify it, item binaries are read one by one
+func loadBundle(db *Store, items []*bundleItemInfo) ([]byte, error) {
+	onChainItems := make([]types.BundleItem, 0, len(items))
+	for _, it := range items {
+		itemBinary, err := db.KVDb.Get(schema.BundleItemBinary, it.id)
+		if err != nil {
+			return nil, err
+		}
+		item, err := utils.DecodeBundleItem(itemBinary)
+		if err != nil {
+			return nil, fmt.Errorf("utils.DecodeBundleItem(itemBinary) failed, itemId: %s, err: %v", it.id, err)
+		}
+		onChainItems = append(onChainItems, *item)
+	}
+	bundle, err := utils.NewBundle(onChainItems...)
+	if err != nil {
+		return nil, err
+	}
+	// verify bundle, ensure that the bundle is exactly right before sending
+	if _, err = utils.DecodeBundle(bundle.BundleBinary); err != nil {
+		return nil, fmt.Errorf("Verify bundle failed; err:%v", err)
+	}
+	return bundle.BundleBinary, nil
+}
+
+// bundleSpool is a bundle written to spool file, the chunks for data root are computed while writing
+type bundleSpool struct {
+	file   *os.File
+	size   int64
+	chunks *types.Chunks
+}
+
+func (b *bundleSpool) Close() {
+	b.file.Close()
+	os.Remove(b.file.Name())
+}
+
+// spoolBundle write the bundle to a spool file, item binaries are opened one by one and copied from store streams without decoding
+func spoolBundle(db *Store, items []*bundleItemInfo) (spool *bundleSpool, err error) {
+	file, err := os.CreateTemp(schema.TmpFileDir, "arseed-bundle-")
+	if err != nil {
+		return
+	}
+	spool = &bundleSpool{file: file, size: bundleSize(items)}
+	defer func() {
+		if err != nil {
+			spool.Close()
+			spool = nil
+		}
+	}()
+
+	cw := newChunkWriter(file, spool.size)
+	if err = writeBundleHeader(cw, items); err != nil {
+		return
+	}
+	for _, item := range items {
+		if err = copyItemBinary(cw, db, item); err != nil {
+			return
+		}
+	}
+	chunks, err := cw.finish()
+	if err != nil {
+		return
+	}
+	spool.chunks = &chunks
+	_, err = file.Seek(0, io.SeekStart)
+	return
+}
+
+func copyItemBinary(w io.Writer, db *Store, item *bundleItemInfo) error {
+	reader, err := db.KVDb.GetStream(schema.BundleItemBinary, item.id)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	n, err := io.Copy(w, reader)
+	if err != nil {
+		return err
+	}
+	if n != item.size {
+		return fmt.Errorf("item binary size changed, itemId: %s", item.id)
+	}
+	return nil
+}
+
+// sendBundleSpool send the spooled bundle with precomputed chunks, the data is not read again for data root
+func sendBundleSpool(bundler *Bundler, concurrentNum int, spool *bundleSpool, tags []types.Tag, speedFactor, maxReward int64) (types.Transaction, error) {
+	if err := checkBundleTxTags(tags); err != nil {
+		return types.Transaction{}, err
+	}
+	price, err := bundler.Client.GetTransactionPrice(int(spool.size), nil)
+	if err != nil {
+		return types.Transaction{}, err
+	}
+	reward, err := bundleReward(price, speedFactor, maxReward)
+	if err != nil {
+		return types.Transaction{}, err
+	}
+	tx := &types.Transaction{
+		Format:     2,
+		Target:     "",
+		Quantity:   "0",
+		Tags:       utils.TagsEncode(bundleTxTags(tags)),
+		DataReader: spool.file,
+		DataSize:   fmt.Sprintf("%d", spool.size),
+		DataRoot:   utils.Base64Encode(spool.chunks.DataRoot),
+		Chunks:     spool.chunks,
+		Reward:     fmt.Sprintf("%d", reward),
+	}
+	return bundler.SendTransactionConcurrent(context.TODO(), concurrentNum, tx)
+}
+
+// chunkWriter split the written data to arweave chunks, same as utils.GenerateChunks. total size must be known
+// because the last two chunks are balanced if the last one is less than MIN_CHUNK_SIZE
+type chunkWriter struct {
+	w      io.Writer
+	total  int64
+	cursor int64 // end of the last chunk
+	buf    []byte
+	chunks []types.Chunk
+	done   bool
+}
+
+func newChunkWriter(w io.Writer, total int64) *chunkWriter {
+	return &chunkWriter{w: w, total: total, buf: make([]byte, 0, 2*types.MAX_CHUNK_SIZE)}
+}
+
+func (c *chunkWriter) Write(p []byte) (n int, err error) {
+	if c.cursor+int64(len(c.buf)+len(p)) > c.total {
+		return 0, errors.New("write exceeds total size")
+	}
+	if n, err = c.w.Write(p); err != nil {
+		return
+	}
+	c.buf = append(c.buf, p...)
+	c.flush()
+	return
+}
+
+// nextChunkSize the size of next chunk, the rest is the last chunk if it is less than MAX_CHUNK_SIZE
+func (c *chunkWriter) nextChunkSize() int64 {
+	rest := c.total - c.cursor
+	if rest < types.MAX_CHUNK_SIZE {
+		return rest
+	}
+	if next := rest - types.MAX_CHUNK_SIZE; next > 0 && next < types.MIN_CHUNK_SIZE {
+		return rest / 2
+	}
+	return types.MAX_CHUNK_SIZE
+}
+
+func (c *chunkWriter) flush() {
+	for !c.done {
+		size := c.nextChunkSize()
+		if int64(len(c.buf)) < size {
+			return
+		}
+		c.done = size < types.MAX_CHUNK_SIZE && c.cursor+size == c.total
+		hash := sha256.Sum256(c.buf[:size])
+		c.cursor += size
+		c.chunks = append(c.chunks, types.Chunk{
+			DataHash:     hash[:],
+			MinByteRange: int(c.cursor - size),
+			MaxByteRange: int(c.cursor),
+		})
+		c.buf = append(c.buf[:0], c.buf[size:]...)
+	}
+}
+
+// finish return the data root, chunks and proofs, the zero length last chunk is discarded like utils.GenerateChunks
+func (c *chunkWriter) finish() (types.Chunks, error) {
+	c.flush()
+	if !c.done {
+		return types.Chunks{}, fmt.Errorf("written size is less than total size: %d", c.total)
+	}
+	leaves := make([]*types.Node, 0, len(c.chunks))
+	for _, chunk := range c.chunks {
+		leaves = append(leaves, &types.Node{
+			ID: utils.Hash([][]byte{
+				utils.Hash([][]byte{chunk.DataHash}),
+				utils.Hash([][]byte{noteBuffer(chunk.MaxByteRange)}),
+			}),
+			Type:         types.LeafNodeType,
+			DataHash:     chunk.DataHash,
+			MinByteRange: chunk.MinByteRange,
+			MaxByteRange: chunk.MaxByteRange,
+		})
+	}
+	root := buildMerkleRoot(leaves)
+	chunks, proofs := c.chunks, merkleProofs(root, []byte{})
+	if last := chunks[len(chunks)-1]; last.MaxByteRange == last.MinByteRange {
+		chunks, proofs = chunks[:len(chunks)-1], proofs[:len(proofs)-1]
+	}
+	return types.Chunks{DataRoot: root.ID, Chunks: chunks, Proofs: proofs}, nil
+}
+
+func buildMerkleRoot(nodes []*types.Node) *types.Node {
+	for len(nodes) > 1 {
+		next := make([]*types.Node, 0, (len(nodes)+1)/2)
+		for i := 0; i < len(nodes); i += 2 {
+			if i+1 == len(nodes) {
+				next = append(next, nodes[i])
+				continue
+			}
+			left, right := nodes[i], nodes[i+1]
+			hLeft, hRight := sha256.Sum256(left.ID), sha256.Sum256(right.ID)
+			hRange := sha256.Sum256(noteBuffer(left.MaxByteRange))
+			next = append(next, &types.Node{
+				ID:           utils.Hash([][]byte{hLeft[:], hRight[:], hRange[:]}),
+				Type:         types.BranchNodeType,
+				MaxByteRange: right.MaxByteRange,
+				ByteRange:    left.MaxByteRange,
+				LeftChild:    left,
+				RightChild:   right,
+			})
+		}
+		nodes = next
+	}
+	return nodes[0]
+}
+
+func merkleProofs(node *types.Node, proof []byte) []*types.Proof {
+	if node.Type == types.LeafNodeType {
+		return []*types.Proof{{
+			Offest: node.MaxByteRange - 1,
+			Proof:  utils.ConcatBuffer(proof, node.DataHash, noteBuffer(node.MaxByteRange)),
+		}}
+	}
+	partial := utils.ConcatBuffer(proof, node.LeftChild.ID, node.RightChild.ID, noteBuffer(node.ByteRange))
+	return append(merkleProofs(node.LeftChild, partial), merkleProofs(node.RightChild, partial)...)
+}
+
+// noteBuffer is big endian bytes of note in NOTE_SIZE
+func noteBuffer(note int) []byte {
+	buf := make([]byte, types.NOTE_SIZE)
+	for i := len(buf) - 1; i >= 0 && note > 0; i-- {
+		buf[i] = byte(note % 256)
+		note /= 256
+	}
+	return buf
+}
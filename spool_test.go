@@ -0,0 +1,141 @@
+package arseeding
+
+import (
+	"bytes"
+	"errors"
+	"github.com/everFinance/arseeding/rawdb"
+	"github.com/everFinance/arseeding/schema"
+	"github.com/everFinance/goar"
+	"github.com/everFinance/goar/types"
+	"github.com/everFinance/goar/utils"
+	"github.com/everFinance/goether"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"math/rand"
+	"os"
+	"testing"
+)
+
+func TestChunkWriter(t *testing.T) {
+	sizes := []int{1, types.MIN_CHUNK_SIZE, types.MAX_CHUNK_SIZE, types.MAX_CHUNK_SIZE + 1,
+		types.MAX_CHUNK_SIZE + types.MIN_CHUNK_SIZE - 1, 2 * types.MAX_CHUNK_SIZE, 3*types.MAX_CHUNK_SIZE + 12345}
+	for _, size := range sizes {
+		data := make([]byte, size)
+		rand.Read(data)
+		expected, err := utils.GenerateChunks(data)
+		assert.NoError(t, err)
+
+		out := &bytes.Buffer{}
+		cw := newChunkWriter(out, int64(size))
+		for rest := data; len(rest) > 0; {
+			n := rand.Intn(100000) + 1
+			if n > len(rest) {
+				n = len(rest)
+			}
+			_, err = cw.Write(rest[:n])
+			assert.NoError(t, err)
+			rest = rest[n:]
+		}
+		chunks, err := cw.finish()
+		assert.NoError(t, err)
+		assert.Equal(t, data, out.Bytes())
+		assert.Equal(t, expected.DataRoot, chunks.DataRoot, "size: %d", size)
+		assert.Equal(t, expected.Chunks, chunks.Chunks, "size: %d", size)
+		assert.Equal(t, expected.Proofs, chunks.Proofs, "size: %d", size)
+	}
+
+	cw := newChunkWriter(&bytes.Buffer{}, 10)
+	_, err := cw.Write(make([]byte, 11))
+	assert.Error(t, err)
+	_, err = cw.Write(make([]byte, 5))
+	assert.NoError(t, err)
+	_, err = cw.finish()
+	assert.Error(t, err)
+}
+
+func TestSpoolBundle(t *testing.T) {
+	dbPath := "./data/tmp.db"
+	store, err := NewBoltStore(dbPath)
+	assert.NoError(t, err)
+	defer os.RemoveAll(dbPath)
+	assert.NoError(t, os.MkdirAll(schema.TmpFileDir, os.ModePerm))
+	defer os.RemoveAll(schema.TmpFileDir)
+
+	eccSigner, err := goether.NewSigner("4c3f9a1e5b234ce8f1ab58d82f849c0f70a4d5ceaf2b6e2d9a6c58b1f897ef0a")
+	assert.NoError(t, err)
+	itemSigner, err := goar.NewItemSigner(eccSigner)
+	assert.NoError(t, err)
+
+	bigData := make([]byte, 2*types.MAX_CHUNK_SIZE+100)
+	rand.Read(bigData)
+	itemIds := make([]string, 0)
+	for _, data := range [][]byte{bigData, []byte("small"), {}} {
+		item, err := itemSigner.CreateAndSignItem(data, "", "", nil)
+		assert.NoError(t, err)
+		assert.NoError(t, store.SaveItemBinary(item))
+		itemIds = append(itemIds, item.Id)
+	}
+	s := &Arseeding{store: store}
+
+	// empty data item can not be the end item
+	items, skipped, err := s.checkBundleItems(append(itemIds, "not-exist"))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"not-exist": schema.LostReasonLoadFailed}, skipped)
+	assert.Equal(t, 3, len(items))
+	assert.Equal(t, []string{itemIds[1], itemIds[2], itemIds[0]}, []string{items[0].id, items[1].id, items[2].id})
+
+	spool, err := spoolBundle(store, items)
+	assert.NoError(t, err)
+	defer spool.Close()
+
+	bundleBinary, err := io.ReadAll(spool.file)
+	assert.NoError(t, err)
+	assert.Equal(t, spool.size, int64(len(bundleBinary)))
+	bundle, err := utils.DecodeBundle(bundleBinary)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(bundle.Items))
+	assert.Equal(t, itemIds[0], bundle.Items[2].Id)
+	for _, item := range bundle.Items {
+		assert.NoError(t, utils.VerifyBundleItem(item))
+	}
+	expected, err := utils.GenerateChunks(bundleBinary)
+	assert.NoError(t, err)
+	assert.Equal(t, expected.DataRoot, spool.chunks.DataRoot)
+
+	// in memory assembly is the same bundle
+	items, skipped, err = s.checkBundleItems(itemIds)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(skipped))
+	memBinary, err := loadBundle(store, items)
+	assert.NoError(t, err)
+	assert.Equal(t, bundleBinary, memBinary)
+
+	// all items have no data
+	emptyItem, err := itemSigner.CreateAndSignItem(nil, "", "", nil)
+	assert.NoError(t, err)
+	assert.NoError(t, store.SaveItemBinary(emptyItem))
+	_, _, err = s.checkBundleItems([]string{emptyItem.Id, itemIds[2]})
+	assert.Error(t, err)
+
+	// corrupted binary is lost, item failed by transient store error is retried later
+	corrupted := append([]byte{}, emptyItem.ItemBinary[:100]...)
+	assert.NoError(t, store.KVDb.Put(schema.BundleItemBinary, "corrupted", corrupted))
+	s.store = &Store{KVDb: &flakyKVDb{KeyValueDB: store.KVDb, failKey: itemIds[0]}}
+	items, skipped, err = s.checkBundleItems(append(itemIds, "corrupted"))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"corrupted": schema.LostReasonCorrupt}, skipped)
+	assert.Equal(t, 2, len(items))
+}
+
+// flakyKVDb fail GetStream of failKey like a timeout of remote store
+type flakyKVDb struct {
+	rawdb.KeyValueDB
+	failKey string
+}
+
+func (f *flakyKVDb) GetStream(bucket, key string) (io.ReadSeekCloser, error) {
+	if key == f.failKey {
+		return nil, errors.New("request timeout")
+	}
+	return f.KeyValueDB.GetStream(bucket, key)
+}
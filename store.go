@@ -5,15 +5,22 @@ import (
 	"crypto/sha256"
 	"encoding/binary"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/everFinance/arseeding/rawdb"
 	"github.com/everFinance/arseeding/schema"
 	"github.com/everFinance/goar/types"
 	"github.com/everFinance/goar/utils"
-	"os"
+	"io"
+	"strconv"
 )
 
 type Store struct {
-	KVDb rawdb.KeyValueDB
+	KVDb         rawdb.KeyValueDB
+	VerifyOnRead bool // verify item signature and chunk merkle proof when load data
+
+	recordLocks keyLocks // index record of id
+	listLocks   keyLocks // index list of bucket and key
 }
 
 func NewS3Store(accKey, secretKey, region, bucketPrefix, endpoint string) (*Store, error) {
@@ -147,6 +154,38 @@ func (s *Store) IsExistTxDataEndOffset(dataRoot, dataSize string) bool {
 	return true
 }
 
+// IsExistTxData the first and the last chunks of tx data are in local, the data is not loaded
+func (s *Store) IsExistTxData(dataRoot, dataSize string) bool {
+	size, err := strconv.ParseUint(dataSize, 10, 64)
+	if err != nil {
+		return false
+	}
+	txDataEndOffset, err := s.LoadTxDataEndOffSet(dataRoot, dataSize)
+	if err != nil {
+		return false
+	}
+	if size == 0 {
+		return true
+	}
+	startOffset := txDataEndOffset - size + 1
+	return s.KVDb.Exist(schema.ChunkBucket, itob(startOffset)) &&
+		s.KVDb.Exist(schema.ChunkBucket, itob(startOffset+lastChunkOffset(size)))
+}
+
+// lastChunkOffset the offset of the last chunk in tx data, the same split as utils.GenerateChunks
+func lastChunkOffset(size uint64) uint64 {
+	offset := uint64(0)
+	for rest := size; rest > types.MAX_CHUNK_SIZE; {
+		chunkSize := uint64(types.MAX_CHUNK_SIZE)
+		if rest-chunkSize < types.MIN_CHUNK_SIZE {
+			chunkSize = rest / 2
+		}
+		offset += chunkSize
+		rest -= chunkSize
+	}
+	return offset
+}
+
 func (s *Store) SaveChunk(chunkStartOffset uint64, chunk types.GetChunk) error {
 	chunkJs, err := chunk.Marshal()
 	if err != nil {
@@ -270,11 +309,20 @@ func (s *Store) AtomicSaveItem(item types.BundleItem) (err error) {
 	}
 	if err = s.SaveItemMeta(item); err != nil {
 		_ = s.DelItemBinary(item.Id)
+		return
+	}
+	if err = s.SaveItemIndex(item); err != nil {
+		_ = s.AtomicDelItem(item.Id)
 	}
 	return
 }
 
 func (s *Store) AtomicDelItem(itemId string) (err error) {
+	// index of old record need item meta, so delete it first.
+	// the failure of index must not keep the meta and binary of expired item
+	if err = s.DelItemIndex(itemId); err != nil {
+		log.Error("s.DelItemIndex(itemId)", "err", err, "itemId", itemId)
+	}
 	err = s.DelItemMeta(itemId)
 	if err != nil {
 		return
@@ -294,7 +342,7 @@ func (s *Store) SaveItemBinary(item types.BundleItem) (err error) {
 	}
 }
 
-func (s *Store) LoadItemBinary(itemId string) (binaryReader *os.File, itemBinary []byte, err error) {
+func (s *Store) LoadItemBinary(itemId string) (binaryReader io.ReadSeekCloser, itemBinary []byte, err error) {
 	itemBinary = make([]byte, 0)
 	// if store implement with s3, then get binary stream
 	if s.KVDb.Type() == rawdb.S3Type {
@@ -305,6 +353,142 @@ func (s *Store) LoadItemBinary(itemId string) (binaryReader *os.File, itemBinary
 	return
 }
 
+// LoadItemDataStream return the data part of item binary, it is read from the backend directly without spooling
+func (s *Store) LoadItemDataStream(itemId string) (dataReader io.ReadSeekCloser, err error) {
+	binaryReader, err := s.KVDb.GetStream(schema.BundleItemBinary, itemId)
+	if err != nil {
+		return
+	}
+	return itemDataStream(binaryReader)
+}
+
+// itemDataStream return the data part of item binary stream, binaryReader is closed if failed
+func itemDataStream(binaryReader io.ReadSeekCloser) (dataReader io.ReadSeekCloser, err error) {
+	if _, err = binaryReader.Seek(0, io.SeekStart); err != nil {
+		binaryReader.Close()
+		return
+	}
+	dataStart, err := itemDataOffset(binaryReader)
+	if err != nil {
+		binaryReader.Close()
+		return
+	}
+	dataReader, err = newSectionReadSeekCloser(binaryReader, dataStart)
+	if err != nil {
+		binaryReader.Close()
+	}
+	return
+}
+
+// itemDataOffset read the ANS-104 item header and return the start position of data
+func itemDataOffset(r io.Reader) (offset int64, err error) {
+	skip := func(n int64) error {
+		offset += n
+		_, err := io.CopyN(io.Discard, r, n)
+		return err
+	}
+	read := func(n int) ([]byte, error) {
+		buf := make([]byte, n)
+		offset += int64(n)
+		_, err := io.ReadFull(r, buf)
+		return buf, err
+	}
+
+	sigTypeBy, err := read(2)
+	if err != nil {
+		return
+	}
+	sigMeta, ok := types.SigConfigMap[utils.ByteArrayToLong(sigTypeBy)]
+	if !ok {
+		return 0, fmt.Errorf("not support sigType:%d", utils.ByteArrayToLong(sigTypeBy))
+	}
+	if err = skip(int64(sigMeta.SigLength + sigMeta.PubLength)); err != nil {
+		return
+	}
+	// target and anchor
+	for i := 0; i < 2; i++ {
+		present, err := read(1)
+		if err != nil {
+			return 0, err
+		}
+		if present[0] == 1 {
+			if err = skip(32); err != nil {
+				return 0, err
+			}
+		}
+	}
+	tagsMeta, err := read(16) // numOfTags and tagsBytesLength
+	if err != nil {
+		return
+	}
+	if utils.ByteArrayToLong(tagsMeta[:8]) > 0 {
+		err = skip(int64(utils.ByteArrayToLong(tagsMeta[8:])))
+	}
+	return
+}
+
+// sectionReadSeekCloser is the part of r from base to the end
+type sectionReadSeekCloser struct {
+	r      io.ReadSeekCloser
+	base   int64
+	size   int64
+	pos    int64
+	synced bool // position of r is base+pos
+}
+
+func newSectionReadSeekCloser(r io.ReadSeekCloser, base int64) (*sectionReadSeekCloser, error) {
+	end, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	if end < base {
+		return nil, errors.New("section base is out of range")
+	}
+	return &sectionReadSeekCloser{r: r, base: base, size: end - base}, nil
+}
+
+func (s *sectionReadSeekCloser) Read(p []byte) (n int, err error) {
+	if s.pos >= s.size {
+		return 0, io.EOF
+	}
+	if !s.synced {
+		if _, err = s.r.Seek(s.base+s.pos, io.SeekStart); err != nil {
+			return
+		}
+		s.synced = true
+	}
+	if max := s.size - s.pos; int64(len(p)) > max {
+		p = p[:max]
+	}
+	n, err = s.r.Read(p)
+	s.pos += int64(n)
+	return
+}
+
+func (s *sectionReadSeekCloser) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += s.pos
+	case io.SeekEnd:
+		offset += s.size
+	default:
+		return 0, errors.New("sectionReadSeekCloser.Seek: invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("sectionReadSeekCloser.Seek: negative position")
+	}
+	if offset != s.pos {
+		s.pos = offset
+		s.synced = false
+	}
+	return offset, nil
+}
+
+func (s *sectionReadSeekCloser) Close() error {
+	return s.r.Close()
+}
+
 func (s *Store) IsExistItemBinary(itemId string) bool {
 	return s.KVDb.Exist(schema.BundleItemBinary, itemId)
 }
@@ -358,7 +542,10 @@ func (s *Store) SaveArIdToItemIds(arId string, itemIds []string) error {
 	if err != nil {
 		return err
 	}
-	return s.KVDb.Put(schema.BundleArIdToItemIdsBucket, arId, itemIdsJs)
+	if err = s.KVDb.Put(schema.BundleArIdToItemIdsBucket, arId, itemIdsJs); err != nil {
+		return err
+	}
+	return s.SaveItemsBundledIn(arId, itemIds)
 }
 
 func (s *Store) LoadArIdToItemIds(arId string) (itemIds []string, err error) {
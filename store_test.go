@@ -3,8 +3,11 @@ package arseeding
 import (
 	"encoding/json"
 	"github.com/everFinance/arseeding/schema"
+	"github.com/everFinance/goar"
 	"github.com/everFinance/goar/types"
+	"github.com/everFinance/goether"
 	"github.com/stretchr/testify/assert"
+	"io"
 	"os"
 	"sort"
 	"testing"
@@ -219,3 +222,33 @@ func TestIntByte(t *testing.T) {
 	v2 := btoi(str)
 	assert.Equal(t, v, v2)
 }
+
+func TestLoadItemDataStream(t *testing.T) {
+	dbPath := "./data/tmp.db"
+	eccSigner, err := goether.NewSigner("4c3f9a1e5b234ce8f1ab58d82f849c0f70a4d5ceaf2b6e2d9a6c58b1f897ef0a")
+	assert.NoError(t, err)
+	itemSigner, err := goar.NewItemSigner(eccSigner)
+	assert.NoError(t, err)
+	s, err := NewBoltStore(dbPath)
+	assert.NoError(t, err)
+	defer os.RemoveAll(dbPath)
+
+	data := []byte("stream item data")
+	item, err := itemSigner.CreateAndSignItem(data, "Ii5wAMlLNz13n26nYY45mcZErwZLjICmYd46GZvn4ck", "", []types.Tag{{Name: "Content-Type", Value: "text/plain"}})
+	assert.NoError(t, err)
+	assert.NoError(t, s.AtomicSaveItem(item))
+
+	dataReader, err := s.LoadItemDataStream(item.Id)
+	assert.NoError(t, err)
+	defer dataReader.Close()
+	res, err := io.ReadAll(dataReader)
+	assert.NoError(t, err)
+	assert.Equal(t, data, res)
+
+	// seek in data
+	_, err = dataReader.Seek(7, io.SeekStart)
+	assert.NoError(t, err)
+	res, err = io.ReadAll(dataReader)
+	assert.NoError(t, err)
+	assert.Equal(t, data[7:], res)
+}
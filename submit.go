@@ -57,6 +57,9 @@ func (s *Arseeding) SaveSubmitTx(arTx types.Transaction) error {
 		log.Error("s.store.SaveTxMeta(arTx)", "err", err, "arTx", arTx.ID)
 		return err
 	}
+	if err := s.store.SaveTxIndex(arTx); err != nil {
+		log.Error("s.store.SaveTxIndex(arTx)", "err", err, "arTx", arTx.ID)
+	}
 
 	s.submitLocker.Lock()
 	defer s.submitLocker.Unlock()
@@ -116,6 +119,9 @@ func (s *Arseeding) FetchAndStoreTx(arId string) (err error) {
 			log.Error("s.store.SaveTxMeta(arTx)", "err", err, "arTx", arTxMeta.ID)
 			return err
 		}
+		if err := s.store.SaveTxIndex(*arTxMeta); err != nil {
+			log.Error("s.store.SaveTxIndex(arTx)", "err", err, "arTx", arTxMeta.ID)
+		}
 
 	}
 
@@ -0,0 +1,216 @@
+package arseeding
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/everFinance/arseeding/schema"
+	"github.com/everFinance/goar/types"
+	"github.com/everFinance/goar/utils"
+	"github.com/everFinance/goether"
+	"github.com/gin-gonic/gin"
+	"io"
+	"strconv"
+	"sync"
+)
+
+// verifyItemStream check the stored item binary still match its signature, the item data is hashed by streaming.
+// items larger than MaxVerifyItemSize are not verified, the size is checked before reading.
+// store errors are returned as they are, only an invalid binary is ErrDataCorrupted
+func verifyItemStream(itemId string, r io.ReadSeeker) error {
+	reader := &readErrRecorder{r: r}
+	size, err := reader.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	if size > schema.MaxVerifyItemSize {
+		return nil
+	}
+	if _, err = reader.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	err = verifyItemSignature(itemId, reader)
+	if reader.err != nil {
+		return reader.err
+	}
+	if err != nil {
+		metricDataCorrupted("item")
+		log.Error("stored item binary corrupted", "itemId", itemId, "err", err)
+		return schema.ErrDataCorrupted
+	}
+	return nil
+}
+
+// verifyItemSignature same as utils.VerifyBundleItem, but the data is read from r instead of memory or tmp file
+func verifyItemSignature(itemId string, r io.ReadSeeker) error {
+	dataStart, err := itemDataOffset(r)
+	if err != nil {
+		return err
+	}
+	header := make([]byte, dataStart)
+	if _, err = r.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err = io.ReadFull(r, header); err != nil {
+		return err
+	}
+	// decode the header as an item without data
+	item, err := utils.DecodeBundleItem(header)
+	if err != nil {
+		return err
+	}
+	if item.Id != itemId {
+		return fmt.Errorf("item id not match, id: %s", item.Id)
+	}
+	signMsg := utils.DeepHash([]interface{}{
+		utils.Base64Encode([]byte("dataitem")),
+		utils.Base64Encode([]byte("1")),
+		utils.Base64Encode([]byte(strconv.Itoa(item.SignatureType))),
+		item.Owner,
+		item.Target,
+		item.Anchor,
+		item.TagsBy,
+		r,
+	})
+	sig, err := utils.Base64Decode(item.Signature)
+	if err != nil {
+		return err
+	}
+
+	switch item.SignatureType {
+	case types.ArweaveSignType:
+		pubKey, err := utils.OwnerToPubKey(item.Owner)
+		if err != nil {
+			return err
+		}
+		return utils.Verify(signMsg[:], pubKey, sig)
+	case types.ED25519SignType, types.SolanaSignType:
+		pubKey, err := utils.Base64Decode(item.Owner)
+		if err != nil {
+			return err
+		}
+		if !ed25519.Verify(pubKey, signMsg[:], sig) {
+			return errors.New("verify ed25519 signature failed")
+		}
+		return nil
+	case types.EthereumSignType:
+		signer, err := utils.ItemSignerAddr(*item)
+		if err != nil {
+			return err
+		}
+		_, addr, err := goether.Ecrecover(accounts.TextHash(signMsg[:]), sig)
+		if err != nil {
+			return err
+		}
+		if signer != addr.String() {
+			return errors.New("verify ecc sign failed")
+		}
+		return nil
+	default:
+		return fmt.Errorf("not support sigType:%d", item.SignatureType)
+	}
+}
+
+// verifyChunkData check chunk data match the merkle proof of data_root
+func verifyChunkData(chunk types.GetChunk, chunkData []byte) error {
+	err, ok := verifyChunk(chunk)
+	if err != nil || !ok {
+		return schema.ErrDataCorrupted
+	}
+	// the leaf of data_path is sha256(chunkData) and chunk end offset note
+	path, err := utils.Base64Decode(chunk.DataPath)
+	if err != nil || len(path) < types.HASH_SIZE+types.NOTE_SIZE {
+		return schema.ErrDataCorrupted
+	}
+	leaf := path[len(path)-types.HASH_SIZE-types.NOTE_SIZE : len(path)-types.NOTE_SIZE]
+	dataHash := sha256.Sum256(chunkData)
+	if !bytes.Equal(leaf, dataHash[:]) {
+		return schema.ErrDataCorrupted
+	}
+	return nil
+}
+
+// dataRepairer avoid repairing the same id concurrently
+type dataRepairer struct {
+	repairing map[string]struct{}
+	lock      sync.Mutex
+}
+
+func (r *dataRepairer) begin(id string) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.repairing == nil {
+		r.repairing = make(map[string]struct{})
+	}
+	if _, ok := r.repairing[id]; ok {
+		return false
+	}
+	r.repairing[id] = struct{}{}
+	return true
+}
+
+func (r *dataRepairer) end(id string) {
+	r.lock.Lock()
+	delete(r.repairing, id)
+	r.lock.Unlock()
+}
+
+// repairAndProxy serve corrupted data from arweave gateway, and re-fetch it from peers in background
+func (s *Arseeding) repairAndProxy(c *gin.Context, id string) {
+	if s.repairer.begin(id) {
+		go func() {
+			defer s.repairer.end(id)
+			err := s.repairData(id)
+			metricDataRepair(err)
+			if err != nil {
+				log.Error("repair corrupted data failed", "err", err, "id", id)
+			}
+		}()
+	}
+	s.proxyArweaveGateway(c)
+}
+
+// repairData re-fetch data of arTx or bundle item from peers and overwrite local store
+func (s *Arseeding) repairData(id string) error {
+	if txMeta, err := s.store.LoadTxMeta(id); err == nil {
+		data, err := s.taskMg.GetTxDataFromPeers(id, schema.TaskTypeSync, s.cache.GetPeers())
+		if err != nil {
+			return err
+		}
+		// chunks data_root is checked with txMeta.DataRoot
+		return setTxDataChunks(*txMeta, data, s.store)
+	}
+
+	record, err := s.store.LoadIndexRecord(id)
+	if err != nil {
+		return err
+	}
+	if record.BundledIn == "" {
+		return fmt.Errorf("bundle of item not found, itemId: %s", id)
+	}
+	data, err := s.taskMg.GetTxDataFromPeers(record.BundledIn, schema.TaskTypeSync, s.cache.GetPeers())
+	if err != nil {
+		return err
+	}
+	bundle, err := utils.DecodeBundle(data)
+	if err != nil {
+		return err
+	}
+	for _, bItem := range bundle.Items {
+		if bItem.Id != id {
+			continue
+		}
+		item, err := utils.DecodeBundleItem(bItem.ItemBinary)
+		if err != nil {
+			return err
+		}
+		if err = utils.VerifyBundleItem(*item); err != nil {
+			return err
+		}
+		return s.store.SaveItemBinary(*item)
+	}
+	return fmt.Errorf("item not found in bundle, itemId: %s, bundle: %s", id, record.BundledIn)
+}
@@ -0,0 +1,114 @@
+package arseeding
+
+import (
+	"bytes"
+	crand "crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"github.com/everFinance/arseeding/schema"
+	"github.com/everFinance/goar"
+	"github.com/everFinance/goar/types"
+	"github.com/everFinance/goar/utils"
+	"github.com/everFinance/goether"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"math/rand"
+	"os"
+	"strconv"
+	"testing"
+)
+
+func TestVerifyOnRead(t *testing.T) {
+	dbPath := "./data/tmp.db"
+	eccSigner, err := goether.NewSigner("4c3f9a1e5b234ce8f1ab58d82f849c0f70a4d5ceaf2b6e2d9a6c58b1f897ef0a")
+	assert.NoError(t, err)
+	itemSigner, err := goar.NewItemSigner(eccSigner)
+	assert.NoError(t, err)
+	s, err := NewBoltStore(dbPath)
+	assert.NoError(t, err)
+	defer os.RemoveAll(dbPath)
+	s.VerifyOnRead = true
+
+	// bundle item
+	item, err := itemSigner.CreateAndSignItem([]byte("verify item data"), "", "", nil)
+	assert.NoError(t, err)
+	assert.NoError(t, s.AtomicSaveItem(item))
+	_, dataReader, _, err := getBundleItemData(item.Id, s)
+	assert.NoError(t, err)
+	data, err := io.ReadAll(dataReader)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("verify item data"), data)
+	dataReader.Close()
+
+	// arweave signed item
+	prvKey, err := rsa.GenerateKey(crand.Reader, 4096)
+	assert.NoError(t, err)
+	arItemSigner, err := goar.NewItemSigner(goar.NewSignerByPrivateKey(prvKey))
+	assert.NoError(t, err)
+	arItem, err := arItemSigner.CreateAndSignItem([]byte("arweave item"), "", "", []types.Tag{{Name: "Content-Type", Value: "text/plain"}})
+	assert.NoError(t, err)
+	assert.NoError(t, s.AtomicSaveItem(arItem))
+	_, dataReader, _, err = getBundleItemData(arItem.Id, s)
+	assert.NoError(t, err)
+	dataReader.Close()
+
+	corrupted := append([]byte{}, item.ItemBinary...)
+	corrupted[len(corrupted)-1] ^= 0xff
+	assert.NoError(t, s.KVDb.Put(schema.BundleItemBinary, item.Id, corrupted))
+	_, _, _, err = getBundleItemData(item.Id, s)
+	assert.Equal(t, schema.ErrDataCorrupted, err)
+
+	// store error in the middle of data is not corruption
+	errTimeout := errors.New("request timeout")
+	bigItem, err := itemSigner.CreateAndSignItem(make([]byte, 64*1024), "", "", nil)
+	assert.NoError(t, err)
+	for _, n := range []int64{10, int64(len(bigItem.ItemBinary)) - 100} {
+		err = verifyItemStream(bigItem.Id, &failingReader{ReadSeeker: bytes.NewReader(bigItem.ItemBinary), failAt: n, err: errTimeout})
+		assert.Equal(t, errTimeout, err)
+	}
+	assert.NoError(t, verifyItemStream(bigItem.Id, bytes.NewReader(bigItem.ItemBinary)))
+
+	// arTx data chunks
+	data = make([]byte, 600*1024)
+	rand.Read(data)
+	arTx := &types.Transaction{}
+	assert.NoError(t, utils.PrepareChunks(arTx, data, len(data)))
+	arTx.DataSize = strconv.Itoa(len(data))
+	arTx.Chunks = nil
+	assert.NoError(t, s.AtomicSyncDataEndOffset(0, uint64(len(data)), arTx.DataRoot, arTx.DataSize))
+	assert.NoError(t, setTxDataChunks(*arTx, data, s))
+	res, err := getArTxData(arTx.DataRoot, arTx.DataSize, s)
+	assert.NoError(t, err)
+	assert.Equal(t, data, res)
+
+	chunk, err := s.LoadChunk(1)
+	assert.NoError(t, err)
+	chunkData, err := utils.Base64Decode(chunk.Chunk)
+	assert.NoError(t, err)
+	chunkData[0] ^= 0xff
+	chunk.Chunk = utils.Base64Encode(chunkData)
+	assert.NoError(t, s.SaveChunk(1, *chunk))
+	_, err = getArTxData(arTx.DataRoot, arTx.DataSize, s)
+	assert.Equal(t, schema.ErrDataCorrupted, err)
+}
+
+// failingReader fail reads after failAt bytes like a broken connection of remote store
+type failingReader struct {
+	io.ReadSeeker
+	failAt int64
+	err    error
+}
+
+func (f *failingReader) Read(p []byte) (int, error) {
+	pos, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	if pos >= f.failAt {
+		return 0, f.err
+	}
+	if rest := f.failAt - pos; int64(len(p)) > rest {
+		p = p[:rest]
+	}
+	return f.ReadSeeker.Read(p)
+}
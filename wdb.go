@@ -67,7 +67,7 @@ func (w *Wdb) Migrate(noFee, enableManifest bool) error {
 		return err
 	}
 	if enableManifest {
-		err = w.Db.AutoMigrate(&schema.Manifest{})
+		err = w.Db.AutoMigrate(&schema.Manifest{}, &schema.CustomDomain{})
 	}
 	return err
 }
@@ -76,6 +76,13 @@ func (w *Wdb) InsertOrder(order schema.Order) error {
 	return w.Db.Create(&order).Error
 }
 
+// InsertOrders insert all orders in one db transaction
+func (w *Wdb) InsertOrders(orders []schema.Order) error {
+	return w.Db.Transaction(func(tx *gorm.DB) error {
+		return tx.CreateInBatches(&orders, 500).Error
+	})
+}
+
 func (w *Wdb) GetUnPaidOrder(itemId string) (schema.Order, error) {
 	res := schema.Order{}
 	err := w.Db.Model(&schema.Order{}).Where("item_id = ? and payment_status = ?", itemId, schema.UnPayment).Last(&res).Error
@@ -120,13 +127,38 @@ func (w *Wdb) UpdateOrderPay(id uint, everHash string, paymentStatus string, tx
 
 func (w *Wdb) GetNeedOnChainOrders() ([]schema.Order, error) {
 	res := make([]schema.Order, 0)
-	err := w.Db.Model(&schema.Order{}).Where("payment_status = ?  and on_chain_status = ? and sort = ?", schema.SuccPayment, schema.WaitOnChain, false).Limit(2000).Find(&res).Error
+	err := w.Db.Model(&schema.Order{}).Where("payment_status = ?  and on_chain_status = ? and sort = ?", schema.SuccPayment, schema.WaitOnChain, false).Order("id").Limit(2000).Find(&res).Error
+	return res, err
+}
+
+// GetNeedOnChainOrdersByTier return at most limit orders, orders created before waitedBefore first, so that they are not
+// starved by orders of higher tier, then orders of higher tier apikey, then the older ones. zero waitedBefore is ignored
+func (w *Wdb) GetNeedOnChainOrdersByTier(apiKeyTiers map[string]int, waitedBefore time.Time, limit int) ([]schema.Order, error) {
+	res := make([]schema.Order, 0)
+	sql := &strings.Builder{}
+	vars := make([]interface{}, 0, 2*len(apiKeyTiers)+1)
+	if !waitedBefore.IsZero() {
+		sql.WriteString("CASE WHEN created_at <= ? THEN 1 ELSE 0 END DESC, ")
+		vars = append(vars, waitedBefore)
+	}
+	if len(apiKeyTiers) > 0 {
+		sql.WriteString("CASE api_key")
+		for apiKey, tier := range apiKeyTiers {
+			sql.WriteString(" WHEN ? THEN ?")
+			vars = append(vars, apiKey, tier)
+		}
+		sql.WriteString(" ELSE 0 END DESC, ")
+	}
+	sql.WriteString("id")
+	err := w.Db.Model(&schema.Order{}).Where("payment_status = ?  and on_chain_status = ? and sort = ?", schema.SuccPayment, schema.WaitOnChain, false).
+		Clauses(clause.OrderBy{Expression: clause.Expr{SQL: sql.String(), Vars: vars, WithoutParentheses: true}}).
+		Limit(limit).Find(&res).Error
 	return res, err
 }
 
 func (w *Wdb) GetNeedOnChainOrdersSorted() ([]schema.Order, error) {
 	res := make([]schema.Order, 0)
-	err := w.Db.Model(&schema.Order{}).Where("payment_status = ?  and on_chain_status = ? and sort = ?", schema.SuccPayment, schema.WaitOnChain, true).Limit(2000).Find(&res).Error
+	err := w.Db.Model(&schema.Order{}).Where("payment_status = ?  and on_chain_status = ? and sort = ?", schema.SuccPayment, schema.WaitOnChain, true).Order("id").Limit(2000).Find(&res).Error
 	return res, err
 }
 
@@ -138,6 +170,40 @@ func (w *Wdb) UpdateOrdOnChainStatus(itemId, status string, tx *gorm.DB) error {
 	return db.Model(&schema.Order{}).Where("item_id = ?", itemId).Update("on_chain_status", status).Error
 }
 
+// UpdateOrdToLost the first reason is kept if item is lost already
+func (w *Wdb) UpdateOrdToLost(itemId, reason string) error {
+	return w.Db.Model(&schema.Order{}).Where("item_id = ? and on_chain_status != ?", itemId, schema.LostOnChain).
+		Updates(map[string]interface{}{"on_chain_status": schema.LostOnChain, "lost_reason": reason}).Error
+}
+
+func (w *Wdb) GetLostOrders(cursorId int64, num int) ([]schema.Order, error) {
+	if cursorId <= 0 {
+		cursorId = math.MaxInt64
+	}
+	records := make([]schema.Order, 0, num)
+	err := w.Db.Model(&schema.Order{}).Where("id < ? and on_chain_status = ?", cursorId, schema.LostOnChain).Order("id DESC").Limit(num).Find(&records).Error
+	return records, err
+}
+
+// RequeueLostOrders set lost orders to waiting, so they are bundled again. all lost orders if itemIds is empty
+func (w *Wdb) RequeueLostOrders(itemIds []string) (int64, error) {
+	db := w.Db.Model(&schema.Order{}).Where("on_chain_status = ?", schema.LostOnChain)
+	if len(itemIds) > 0 {
+		db = db.Where("item_id in ?", itemIds)
+	}
+	res := db.Updates(map[string]interface{}{"on_chain_status": schema.WaitOnChain, "lost_reason": ""})
+	return res.RowsAffected, res.Error
+}
+
+// RequeuePendingOrders set pending orders to waiting, so they are bundled again
+func (w *Wdb) RequeuePendingOrders(itemIds []string) error {
+	if len(itemIds) == 0 {
+		return nil
+	}
+	return w.Db.Model(&schema.Order{}).Where("item_id in ? and on_chain_status = ?", itemIds, schema.PendingOnChain).
+		Update("on_chain_status", schema.WaitOnChain).Error
+}
+
 func (w *Wdb) GetOrdersBySigner(signer string, cursorId int64, num int) ([]schema.Order, error) {
 	if cursorId <= 0 {
 		cursorId = math.MaxInt64
@@ -164,6 +230,13 @@ func (w *Wdb) GetOrdersByApiKey(apiKey string, cursorId int64, pageSize int, sor
 	return records, err
 }
 
+// GetApiKeysByItemIds return the distinct apikeys of the orders of items
+func (w *Wdb) GetApiKeysByItemIds(itemIds []string) ([]string, error) {
+	res := make([]string, 0)
+	err := w.Db.Model(&schema.Order{}).Where("item_id in ?", itemIds).Distinct("api_key").Pluck("api_key", &res).Error
+	return res, err
+}
+
 func (w *Wdb) ExistProcessedOrderItem(itemId string) (res schema.Order, exist bool) {
 	err := w.Db.Model(&schema.Order{}).Where("item_id = ? and (on_chain_status = ? or on_chain_status = ?)", itemId, schema.PendingOnChain, schema.SuccOnChain).First(&res).Error
 	if err == nil {
@@ -251,16 +324,52 @@ func (w *Wdb) UpdateArTxStatus(arId, status string, arTxStatus *types.TxStatus,
 	return db.Model(&schema.OnChainTx{}).Where("ar_id = ?", arId).Updates(data).Error
 }
 
-func (w *Wdb) UpdateArTx(id uint, arId string, curHeight int64, dataSize, reward string, status string) error {
+func (w *Wdb) UpdateArTxInclusion(arId, inclusion string, tx *gorm.DB) error {
+	db := w.Db
+	if tx != nil {
+		db = tx
+	}
+	return db.Model(&schema.OnChainTx{}).Where("ar_id = ?", arId).Update("inclusion", inclusion).Error
+}
+
+func (w *Wdb) GetArTxByInclusion(inclusion string) ([]schema.OnChainTx, error) {
+	res := make([]schema.OnChainTx, 0)
+	err := w.Db.Model(schema.OnChainTx{}).Where("status = ? and inclusion = ?", schema.SuccOnChain, inclusion).Find(&res).Error
+	return res, err
+}
+
+func (w *Wdb) UpdateArTx(id uint, arId, bundler string, curHeight int64, dataSize, reward string, itemIds datatypes.JSON, itemNum, attempts int, history datatypes.JSON, status string) error {
 	data := make(map[string]interface{})
 	data["ar_id"] = arId
+	data["bundler"] = bundler
 	data["cur_height"] = curHeight
 	data["data_size"] = dataSize
 	data["reward"] = reward
+	data["item_ids"] = itemIds
+	data["item_num"] = itemNum
+	data["attempts"] = attempts
+	data["history"] = history
 	data["status"] = status
 	return w.Db.Model(&schema.OnChainTx{}).Where("id = ?", id).Updates(data).Error
 }
 
+// GetPendingArTxNumByBundler key: bundler address, arTxs posted before bundler column is added have "" bundler
+func (w *Wdb) GetPendingArTxNumByBundler() (map[string]int, error) {
+	rows := make([]struct {
+		Bundler string
+		Num     int
+	}, 0)
+	err := w.Db.Model(&schema.OnChainTx{}).Select("bundler, count(*) as num").Where("status = ?", schema.PendingOnChain).Group("bundler").Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]int, len(rows))
+	for _, row := range rows {
+		res[row.Bundler] = row.Num
+	}
+	return res, nil
+}
+
 func (w *Wdb) GetKafkaOnChains() ([]schema.OnChainTx, error) {
 	results := make([]schema.OnChainTx, 0)
 	err := w.Db.Model(&schema.OnChainTx{}).Where("block_height > ? and kafka = ? and status = ?", 1188855, false, schema.SuccOnChain).Limit(10).Find(&results).Error
@@ -285,6 +394,24 @@ func (w *Wdb) DelManifest(id string) error {
 	return w.Db.Where("manifest_id = ?", id).Delete(&schema.Manifest{}).Error
 }
 
+// SetCustomDomain insert or update target of the domain
+func (w *Wdb) SetCustomDomain(cd schema.CustomDomain) error {
+	return w.Db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "domain"}},
+		DoUpdates: clause.AssignmentColumns([]string{"target_id"}),
+	}).Create(&cd).Error
+}
+
+func (w *Wdb) GetCustomDomains() ([]schema.CustomDomain, error) {
+	res := make([]schema.CustomDomain, 0)
+	err := w.Db.Model(&schema.CustomDomain{}).Order("domain").Find(&res).Error
+	return res, err
+}
+
+func (w *Wdb) DelCustomDomain(domain string) error {
+	return w.Db.Where("domain = ?", domain).Delete(&schema.CustomDomain{}).Error
+}
+
 func (w *Wdb) InsertApiKey(ak schema.AutoApiKey) error {
 	return w.Db.Create(&ak).Error
 }
@@ -1,9 +1,12 @@
 package arseeding
 
 import (
+	"fmt"
 	"github.com/everFinance/arseeding/schema"
 	"github.com/stretchr/testify/assert"
+	"os"
 	"testing"
+	"time"
 )
 
 func TestNewWdb(t *testing.T) {
@@ -36,3 +39,140 @@ func TestNew(t *testing.T) {
 	err := db.Migrate(false, true)
 	assert.NoError(t, err)
 }
+
+func TestGetApiKeysByItemIds(t *testing.T) {
+	db := NewSqliteDb("./data/sqlite")
+	defer os.RemoveAll("./data/sqlite")
+	assert.NoError(t, db.Migrate(false, true))
+	assert.NoError(t, db.InsertOrder(schema.Order{ItemId: "a", ApiKey: "key1"}))
+	assert.NoError(t, db.InsertOrder(schema.Order{ItemId: "b", ApiKey: "key1"}))
+	assert.NoError(t, db.InsertOrder(schema.Order{ItemId: "c", ApiKey: "key2"}))
+
+	apiKeys, err := db.GetApiKeysByItemIds([]string{"a", "b"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"key1"}, apiKeys)
+	apiKeys, err = db.GetApiKeysByItemIds([]string{"a", "c"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(apiKeys))
+}
+
+func TestGetPendingArTxNumByBundler(t *testing.T) {
+	db := NewSqliteDb("./data/sqlite")
+	defer os.RemoveAll("./data/sqlite")
+	assert.NoError(t, db.Migrate(false, true))
+	assert.NoError(t, db.InsertArTx(schema.OnChainTx{ArId: "tx1", Bundler: "addr1", Status: schema.PendingOnChain}))
+	assert.NoError(t, db.InsertArTx(schema.OnChainTx{ArId: "tx2", Bundler: "addr1", Status: schema.PendingOnChain}))
+	assert.NoError(t, db.InsertArTx(schema.OnChainTx{ArId: "tx3", Bundler: "addr2", Status: schema.SuccOnChain}))
+	assert.NoError(t, db.InsertArTx(schema.OnChainTx{ArId: "tx4", Status: schema.PendingOnChain}))
+
+	pending, err := db.GetPendingArTxNumByBundler()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{"addr1": 2, "": 1}, pending)
+}
+
+func TestUpdateArTx(t *testing.T) {
+	db := NewSqliteDb("./data/sqlite")
+	defer os.RemoveAll("./data/sqlite")
+	assert.NoError(t, db.Migrate(false, true))
+	assert.NoError(t, db.InsertArTx(schema.OnChainTx{ArId: "tx1", Status: schema.FailedOnChain, Attempts: 1}))
+	txs, err := db.GetArTxByStatus(schema.FailedOnChain)
+	assert.NoError(t, err)
+
+	history := []byte(`[{"arId":"tx1","bundler":"addr1","reward":"100","curHeight":1,"failedHeight":60}]`)
+	assert.NoError(t, db.UpdateArTx(txs[0].ID, "tx2", "addr2", 60, "1024", "110", []byte(`["a"]`), 1, 2, history, schema.PendingOnChain))
+	txs, err = db.GetArTxByStatus(schema.PendingOnChain)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(txs))
+	assert.Equal(t, "tx2", txs[0].ArId)
+	assert.Equal(t, "addr2", txs[0].Bundler)
+	assert.Equal(t, 2, txs[0].Attempts)
+	assert.Equal(t, 1, txs[0].ItemNum)
+	assert.JSONEq(t, `["a"]`, string(txs[0].ItemIds))
+	assert.JSONEq(t, string(history), string(txs[0].History))
+}
+
+func TestLostOrders(t *testing.T) {
+	db := NewSqliteDb("./data/sqlite")
+	defer os.RemoveAll("./data/sqlite")
+	assert.NoError(t, db.Migrate(false, true))
+	for _, itemId := range []string{"item1", "item2", "item3"} {
+		assert.NoError(t, db.InsertOrder(schema.Order{ItemId: itemId, PaymentStatus: schema.SuccPayment, OnChainStatus: schema.PendingOnChain}))
+	}
+	assert.NoError(t, db.UpdateOrdToLost("item1", schema.LostReasonLoadFailed))
+	assert.NoError(t, db.UpdateOrdToLost("item1", schema.LostReasonNotInBundle)) // the first reason is kept
+	assert.NoError(t, db.UpdateOrdToLost("item2", schema.LostReasonCorrupt))
+
+	ords, err := db.GetLostOrders(0, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(ords))
+	assert.Equal(t, "item2", ords[0].ItemId)
+	assert.Equal(t, schema.LostReasonLoadFailed, ords[1].LostReason)
+	ords, err = db.GetLostOrders(int64(ords[0].ID), 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(ords))
+
+	num, err := db.RequeueLostOrders([]string{"item1", "item3"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), num)
+	num, err = db.RequeueLostOrders(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), num)
+	ords, err = db.GetNeedOnChainOrders()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(ords))
+	assert.Equal(t, "", ords[0].LostReason)
+}
+
+func TestRequeuePendingOrders(t *testing.T) {
+	db := NewSqliteDb("./data/sqlite")
+	defer os.RemoveAll("./data/sqlite")
+	assert.NoError(t, db.Migrate(false, true))
+	assert.NoError(t, db.InsertOrder(schema.Order{ItemId: "item1", PaymentStatus: schema.SuccPayment, OnChainStatus: schema.PendingOnChain}))
+	assert.NoError(t, db.InsertOrder(schema.Order{ItemId: "item2", PaymentStatus: schema.SuccPayment, OnChainStatus: schema.LostOnChain}))
+
+	dropped := droppedItemIds([]string{"item1", "item2", "item3"}, []string{"item3"})
+	assert.Equal(t, []string{"item1", "item2"}, dropped)
+	assert.NoError(t, db.RequeuePendingOrders(dropped))
+	ords, err := db.GetNeedOnChainOrders()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(ords))
+	assert.Equal(t, "item1", ords[0].ItemId)
+	ords, err = db.GetLostOrders(0, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(ords))
+}
+
+func TestGetNeedOnChainOrdersByTier(t *testing.T) {
+	db := NewSqliteDb("./data/sqlite")
+	defer os.RemoveAll("./data/sqlite")
+	assert.NoError(t, db.Migrate(false, true))
+	for i, apiKey := range []string{"", "key1", "key2", "", "key2"} {
+		assert.NoError(t, db.InsertOrder(schema.Order{ItemId: fmt.Sprintf("item%d", i), ApiKey: apiKey, PaymentStatus: schema.SuccPayment, OnChainStatus: schema.WaitOnChain}))
+	}
+
+	ords, err := db.GetNeedOnChainOrdersByTier(map[string]int{"key1": 1, "key2": 2}, time.Time{}, 4)
+	assert.NoError(t, err)
+	ids := make([]string, 0, len(ords))
+	for _, ord := range ords {
+		ids = append(ids, ord.ItemId)
+	}
+	assert.Equal(t, []string{"item2", "item4", "item1", "item0"}, ids)
+
+	ords, err = db.GetNeedOnChainOrdersByTier(nil, time.Time{}, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(ords))
+	assert.Equal(t, "item0", ords[0].ItemId)
+
+	// high tier orders are more than limit, the order waited longer is still loaded
+	for i := 5; i < 10; i++ {
+		assert.NoError(t, db.InsertOrder(schema.Order{ItemId: fmt.Sprintf("item%d", i), ApiKey: "key2", PaymentStatus: schema.SuccPayment, OnChainStatus: schema.WaitOnChain}))
+	}
+	assert.NoError(t, db.Db.Model(&schema.Order{}).Where("item_id = ?", "item3").Update("created_at", time.Now().Add(-time.Hour)).Error)
+	ords, err = db.GetNeedOnChainOrdersByTier(map[string]int{"key1": 1, "key2": 2}, time.Now().Add(-time.Minute), 4)
+	assert.NoError(t, err)
+	ids = ids[:0]
+	for _, ord := range ords {
+		ids = append(ids, ord.ItemId)
+	}
+	assert.Equal(t, []string{"item3", "item2", "item4", "item5"}, ids)
+}